@@ -38,6 +38,11 @@ func (e *withTag) Error() string {
 	return e.wrapErr.Error()
 }
 
+// Unwrap returns the tagged error
+func (e *withTag) Unwrap() error {
+	return e.wrapErr
+}
+
 func WrapTag(tag TagKind) External {
 	return func(err error) error {
 		return &withTag{
@@ -2,8 +2,12 @@ package zfile
 
 import (
 	"errors"
+	"hash/fnv"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 )
 
 var (
@@ -94,3 +98,58 @@ func (l *FileLock) Clean() error {
 	}
 	return Remove(l.path)
 }
+
+// ShardedFileLock spreads keys over a fixed set of file locks in a directory,
+// each shard is also guarded by a mutex so goroutines of the process take turns.
+type ShardedFileLock struct {
+	locks   []*FileLock
+	mus     []sync.Mutex
+	timeout time.Duration
+}
+
+// NewShardedFileLock creates shards lock files named shard-N.lock in dir,
+// Lock gives up with ErrLocked after timeout, zero tries only once.
+func NewShardedFileLock(dir string, shards int, timeout time.Duration) *ShardedFileLock {
+	if shards <= 0 {
+		shards = 1
+	}
+	l := &ShardedFileLock{
+		locks:   make([]*FileLock, shards),
+		mus:     make([]sync.Mutex, shards),
+		timeout: timeout,
+	}
+	for i := range l.locks {
+		l.locks[i] = NewFileLock(filepath.Join(dir, "shard-"+strconv.Itoa(i)+".lock"))
+	}
+	return l
+}
+
+// Shard returns the shard of key
+func (l *ShardedFileLock) Shard(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(l.locks)))
+}
+
+// Lock acquires the shard, waiting for other processes until the timeout
+func (l *ShardedFileLock) Lock(shard int) error {
+	l.mus[shard].Lock()
+	deadline := time.Now().Add(l.timeout)
+	for {
+		err := l.locks[shard].Lock()
+		if err == nil {
+			return nil
+		}
+		if err != ErrLocked || time.Now().After(deadline) {
+			l.mus[shard].Unlock()
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Unlock releases the shard
+func (l *ShardedFileLock) Unlock(shard int) {
+	_ = l.locks[shard].Unlock()
+	l.mus[shard].Unlock()
+}
@@ -108,3 +108,34 @@ func TestFileLockConcurrent(t *testing.T) {
 		tt.NoError(err)
 	}
 }
+
+func TestShardedFileLock(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	dir := t.TempDir()
+
+	a := NewShardedFileLock(dir, 4, 20*time.Millisecond)
+	b := NewShardedFileLock(dir, 4, 20*time.Millisecond)
+	shard := a.Shard("key")
+	tt.Equal(shard, b.Shard("key"))
+	tt.EqualTrue(shard >= 0 && shard < 4)
+
+	tt.NoError(a.Lock(shard), true)
+	tt.Equal(ErrLocked, b.Lock(shard))
+
+	locked := make(chan struct{})
+	go func() {
+		_ = a.Lock(shard)
+		close(locked)
+		a.Unlock(shard)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("shard locked twice in the same process")
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.Unlock(shard)
+	<-locked
+
+	tt.NoError(b.Lock(shard))
+	b.Unlock(shard)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
@@ -58,6 +59,55 @@ func (e *Engine) SetClient(client *http.Client) {
 	e.client.Store(unsafe.Pointer(client))
 }
 
+// BeforeRequest registers hooks called with every outgoing request right before it is sent,
+// such as propagating tracing headers from the request context
+func (e *Engine) BeforeRequest(fn ...func(req *http.Request)) {
+	e.beforeRequest = append(e.beforeRequest, fn...)
+}
+
+// SetCircuitBreaker enables a circuit breaker per host, requests to a host whose breaker
+// is open fail with zutil.ErrBreakerOpen, errors and 5xx responses count as failures,
+// the returned breakers can be queried for metrics
+func (e *Engine) SetCircuitBreaker(opt ...func(*zutil.BreakerConf)) *zutil.Breakers {
+	conf := zutil.Optional(zutil.DefaultBreakerConf(), opt...)
+	conf.OnStateChange = zutil.LogBreakerStateChange(stdLogger{}, conf.OnStateChange)
+	breakers := zutil.NewBreakers(func(c *zutil.BreakerConf) {
+		*c = conf
+	})
+	e.breakers.Store(unsafe.Pointer(breakers))
+	return breakers
+}
+
+// send sends the request through the circuit breaker of its host
+func (e *Engine) send(client *http.Client, req *http.Request) (*http.Response, error) {
+	breakers := (*zutil.Breakers)(e.breakers.Load())
+	if breakers == nil {
+		return client.Do(req)
+	}
+	done, err := breakers.Get(req.URL.Host).Allow()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, req.URL.Host)
+	}
+	resp, err := client.Do(req)
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		done(errors.New(resp.Status))
+	} else {
+		done(err)
+	}
+	return resp, err
+}
+
+// stdLogger logs through the default zlog logger
+type stdLogger struct{}
+
+func (stdLogger) Infof(format string, v ...interface{}) {
+	zlog.Infof(format, v...)
+}
+
+func (stdLogger) Warnf(format string, v ...interface{}) {
+	zlog.Warnf(format, v...)
+}
+
 func (e *Engine) DisableChunked(enable ...bool) {
 	state := true
 	if len(enable) > 0 && enable[0] {
@@ -105,6 +155,10 @@ func (e *Engine) Connect(url string, v ...interface{}) (*Res, error) {
 func (e *Engine) DoRetry(attempt int, sleep time.Duration, fn func() (*Res, error)) (res *Res, err error) {
 	zutil.DoRetry(attempt, func() error {
 		res, err = fn()
+		if errors.Is(err, zutil.ErrBreakerOpen) {
+			// retrying can not succeed before the breaker probes the host again
+			return nil
+		}
 		return err
 	}, func(rc *zutil.RetryConf) {
 		if sleep == 0 {
@@ -58,6 +58,8 @@ type (
 		xmlEncOpts     *xmlEncOpts
 		getUserAgent   func() string
 		urlCache       *fast.FastCache
+		breakers       *zutil.Pointer
+		beforeRequest  []func(req *http.Request)
 		flag           int
 		debug          bool
 		disableChunked bool
@@ -169,7 +171,8 @@ func New() *Engine {
 			o.Expiration = 0
 			o.AutoCleaner = false
 		}),
-		client: zutil.NewPointer(nil),
+		client:   zutil.NewPointer(nil),
+		breakers: zutil.NewPointer(nil),
 	}
 	e.SetClient(newClient())
 	return e
@@ -436,6 +439,10 @@ func (e *Engine) Do(method, rawurl string, vs ...interface{}) (resp *Res, err er
 		fn()
 	}
 
+	for _, fn := range e.beforeRequest {
+		fn(req)
+	}
+
 	if resp.client == nil {
 		resp.client = e.Client()
 	}
@@ -444,11 +451,11 @@ func (e *Engine) Do(method, rawurl string, vs ...interface{}) (resp *Res, err er
 
 	if e.flag&BitTime != 0 {
 		before := time.Now()
-		response, err = resp.client.Do(req)
+		response, err = e.send(resp.client, req)
 		after := time.Now()
 		resp.cost = after.Sub(before)
 	} else {
-		response, err = resp.client.Do(req)
+		response, err = e.send(resp.client, req)
 	}
 
 	if err != nil {
@@ -1027,6 +1034,10 @@ func (e *Engine) DoWithArgs(method, rawurl string, args *RequestArgs) (*Res, err
 		fn()
 	}
 
+	for _, fn := range e.beforeRequest {
+		fn(req)
+	}
+
 	if resp.client == nil {
 		resp.client = e.Client()
 	}
@@ -1035,11 +1046,11 @@ func (e *Engine) DoWithArgs(method, rawurl string, args *RequestArgs) (*Res, err
 
 	if e.flag&BitTime != 0 {
 		before := time.Now()
-		response, err = resp.client.Do(req)
+		response, err = e.send(resp.client, req)
 		after := time.Now()
 		resp.cost = after.Sub(before)
 	} else {
-		response, err = resp.client.Do(req)
+		response, err = e.send(resp.client, req)
 	}
 
 	if err != nil {
@@ -2,6 +2,7 @@ package zhttp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"github.com/sohaha/zlsgo/zfile"
 	"github.com/sohaha/zlsgo/znet"
 	"github.com/sohaha/zlsgo/ztype"
+	"github.com/sohaha/zlsgo/zutil"
 )
 
 var r *znet.Engine
@@ -463,3 +465,40 @@ func TestTlsCertificate(tt *testing.T) {
 	t.EqualNil(err)
 	tt.Log(r.HTML().Find("title").Text(true))
 }
+
+func TestCircuitBreaker(tt *testing.T) {
+	t := zls.NewTest(tt)
+
+	hits := zutil.NewInt64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	e := New()
+	breakers := e.SetCircuitBreaker(func(c *zutil.BreakerConf) {
+		c.ConsecutiveFailures = 2
+		c.OpenTimeout = time.Minute
+	})
+
+	_, err := e.DoRetry(5, time.Millisecond, func() (*Res, error) {
+		res, err := e.Get(ts.URL)
+		if err == nil && res.StatusCode() >= 500 {
+			return nil, errors.New(res.String())
+		}
+		return res, err
+	})
+	t.Equal(true, errors.Is(err, zutil.ErrBreakerOpen))
+	t.Equal(int64(2), hits.Load())
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	t.Equal(zutil.BreakerOpen, breakers.States()[host])
+	t.Equal(int64(0), breakers.Get(host).Counts().Requests)
+
+	breakers.Get(host).Reset()
+	res, err := e.Get(ts.URL)
+	t.NoError(err)
+	t.Equal(http.StatusBadGateway, res.StatusCode())
+	t.Equal(int64(3), hits.Load())
+}
@@ -1,13 +1,20 @@
 package zhttp
 
 import (
+	"net/http"
 	"time"
+
+	"github.com/sohaha/zlsgo/zutil"
 )
 
 func DisableChunked(enable ...bool) {
 	std.DisableChunked(enable...)
 }
 
+func BeforeRequest(fn ...func(req *http.Request)) {
+	std.BeforeRequest(fn...)
+}
+
 func Get(url string, v ...interface{}) (*Res, error) {
 	return std.Get(url, v...)
 }
@@ -48,6 +55,10 @@ func Do(method, rawurl string, v ...interface{}) (resp *Res, err error) {
 	return std.Do(method, rawurl, v...)
 }
 
+func SetCircuitBreaker(opt ...func(*zutil.BreakerConf)) *zutil.Breakers {
+	return std.SetCircuitBreaker(opt...)
+}
+
 func DoRetry(attempt int, sleep time.Duration, fn func() (*Res, error)) (*Res, error) {
 	return std.DoRetry(attempt, sleep, fn)
 }
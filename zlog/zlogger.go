@@ -598,6 +598,27 @@ func (log *Logger) SetPrefix(prefix string) {
 	log.prefix = prefix
 }
 
+// WithPrefix returns a child logger that shares the outputs and settings of the logger,
+// with the prefix appended to the current one.
+// The child does not own the log files, closing them is left to the parent.
+func (log *Logger) WithPrefix(prefix string) *Logger {
+	log.mu.RLock()
+	defer log.mu.RUnlock()
+	return &Logger{
+		out:           log.out,
+		levelFiles:    log.levelFiles,
+		prefix:        log.prefix + prefix,
+		fileDir:       log.fileDir,
+		fileName:      log.fileName,
+		writeBefore:   log.writeBefore,
+		calldDepth:    log.calldDepth,
+		level:         log.level,
+		flag:          log.flag,
+		color:         log.color,
+		fileAndStdout: log.fileAndStdout,
+	}
+}
+
 func (log *Logger) GetPrefix() string {
 	return log.prefix
 }
@@ -1,6 +1,7 @@
 package zlog_test
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -41,3 +42,16 @@ func TestCustomWriter(t *testing.T) {
 	l3.Writer().Reset(l2)
 	l3.Info("Test")
 }
+
+func TestWithPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	parent := zlog.NewZLog(&buf, "[P] ", 0, zlog.LogDump, false, 3)
+	child := parent.WithPrefix("[C] ")
+	child.Info("hello")
+	if buf.String() != "[P] [C] hello\n" {
+		t.Fatal(buf.String())
+	}
+	if parent.GetPrefix() != "[P] " {
+		t.Fatal(parent.GetPrefix())
+	}
+}
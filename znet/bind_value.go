@@ -74,14 +74,19 @@ func (c *Context) BindQuery(obj interface{}) (err error) {
 
 // BindForm binds form data from the request to the provided object.
 // It handles both regular form data and multipart form data, mapping form fields
-// to struct fields based on field tags.
+// to struct fields based on field tags. Fields of type *multipart.FileHeader and
+// []*multipart.FileHeader receive the uploaded files, *UploadedFile and
+// []*UploadedFile receive the files stored by Upload.
 func (c *Context) BindForm(obj interface{}) error {
 	q := c.GetPostFormAll()
 	typ := zreflect.TypeOf(obj)
 	m := make(map[string]interface{}, len(q))
+	files := make(map[int]string)
 	err := zreflect.ForEach(typ, func(parent []string, index int, tag string, field reflect.StructField) error {
 		kind := field.Type.Kind()
-		if kind == reflect.Struct {
+		if len(parent) == 0 && isFileField(field.Type) {
+			files[index] = tag
+		} else if kind == reflect.Struct {
 			m[tag] = c.PostFormMap(tag)
 		} else if kind == reflect.Slice {
 			sliceTyp := field.Type.Elem().Kind()
@@ -102,5 +107,9 @@ func (c *Context) BindForm(obj interface{}) error {
 	if err != nil {
 		return err
 	}
-	return ztype.ToStruct(m, obj)
+	if err = ztype.ToStruct(m, obj); err != nil {
+		return err
+	}
+	c.bindFiles(obj, files)
+	return nil
 }
@@ -0,0 +1,117 @@
+// Package breaker provides a circuit breaker middleware for routes that depend
+// on a downstream service, failing fast while the dependency is unhealthy
+package breaker
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/sohaha/zlsgo/zlog"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// Config configuration
+	Config struct {
+		// Key returns the name of the breaker of the request, default is the route path,
+		// return a constant to share one breaker by a route group
+		Key func(c *znet.Context) string
+		// IsFailure reports whether the response counts as a failure, default is a 5xx status
+		IsFailure func(c *znet.Context) bool
+		// Fallback responds the requests rejected by an open breaker, default is 503
+		Fallback func(c *znet.Context)
+		// Log receives the state changes, default is znet.Log, nil disables it
+		Log *zlog.Logger
+		zutil.BreakerConf
+	}
+	// Breaker keeps a circuit breaker per key for the handlers after the middleware
+	Breaker struct {
+		breakers *zutil.Breakers
+		conf     Config
+	}
+)
+
+// errFailed is recorded for the failed requests, a panic counts as a failure as well
+var errFailed = errors.New("breaker: request failed")
+
+// New returns a circuit breaker middleware
+func New(opt ...func(conf *Config)) znet.HandlerFunc {
+	return NewBreaker(opt...).Handler()
+}
+
+// NewBreaker creates the circuit breakers, use Handler as the middleware
+// and States to query them for metrics
+func NewBreaker(opt ...func(conf *Config)) *Breaker {
+	b := &Breaker{conf: Config{
+		Key: func(c *znet.Context) string {
+			return c.RoutePath()
+		},
+		IsFailure: func(c *znet.Context) bool {
+			return c.PrevContent().Code.Load() >= http.StatusInternalServerError
+		},
+		Log:         znet.Log,
+		BreakerConf: zutil.DefaultBreakerConf(),
+	}}
+	for _, f := range opt {
+		f(&b.conf)
+	}
+
+	bc := b.conf.BreakerConf
+	var log zutil.BreakerLogger
+	if b.conf.Log != nil {
+		log = b.conf.Log
+	}
+	bc.OnStateChange = zutil.LogBreakerStateChange(log, bc.OnStateChange)
+	b.breakers = zutil.NewBreakers(func(conf *zutil.BreakerConf) {
+		*conf = bc
+	})
+	return b
+}
+
+// Handler returns the circuit breaker middleware
+func (b *Breaker) Handler() znet.HandlerFunc {
+	return func(c *znet.Context) {
+		cb := b.breakers.Get(b.conf.Key(c))
+		done, err := cb.Allow()
+		if err != nil {
+			b.reject(c)
+			return
+		}
+
+		failed := true
+		defer func() {
+			if failed {
+				done(errFailed)
+			} else {
+				done(nil)
+			}
+		}()
+		c.Next()
+		failed = b.conf.IsFailure(c)
+	}
+}
+
+// Get returns the breaker of key
+func (b *Breaker) Get(key string) *zutil.Breaker {
+	return b.breakers.Get(key)
+}
+
+// States returns the state of every breaker by key
+func (b *Breaker) States() map[string]zutil.BreakerState {
+	return b.breakers.States()
+}
+
+func (b *Breaker) reject(c *znet.Context) {
+	defer c.Abort()
+	if b.conf.Fallback != nil {
+		b.conf.Fallback(c)
+		return
+	}
+	if b.conf.OpenTimeout > 0 {
+		c.SetHeader("Retry-After", strconv.Itoa(int(math.Ceil(b.conf.OpenTimeout.Seconds()))))
+	}
+	c.String(http.StatusServiceUnavailable, zutil.ErrBreakerOpen.Error())
+}
@@ -0,0 +1,82 @@
+package breaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/breaker"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+func TestBreaker(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	var changes []string
+	b := breaker.NewBreaker(func(conf *breaker.Config) {
+		conf.ConsecutiveFailures = 2
+		conf.OpenTimeout = 50 * time.Millisecond
+		conf.Log = nil
+		conf.OnStateChange = func(name string, from, to zutil.BreakerState) {
+			changes = append(changes, name+":"+to.String())
+		}
+	})
+
+	r := znet.New("breaker-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(znet.Recovery(func(c *znet.Context, err error) {
+		c.String(500, err.Error())
+	}))
+	healthy := false
+	g := r.Group("/api", func(e *znet.Engine) {
+		e.Use(b.Handler())
+	})
+	g.GET("/users", func(c *znet.Context) {
+		if !healthy {
+			c.String(502, "bad gateway")
+			return
+		}
+		c.String(200, "ok")
+	})
+	g.GET("/panic", func(c *znet.Context) {
+		panic("boom")
+	})
+	g.GET("/other", func(c *znet.Context) {
+		c.String(200, "other")
+	})
+
+	client := r.Test(tt)
+	client.GET("/api/users").Do().ExpectStatus(502)
+	client.GET("/api/users").Do().ExpectStatus(502)
+	client.GET("/api/users").Do().
+		ExpectStatus(503).
+		ExpectHeader("Retry-After", "1").
+		ExpectBody(zutil.ErrBreakerOpen.Error())
+	client.GET("/api/other").Do().ExpectStatus(200)
+	tt.Equal(zutil.BreakerOpen, b.States()["/api/users"])
+	tt.Equal(zutil.BreakerClosed, b.Get("/api/other").State())
+
+	time.Sleep(60 * time.Millisecond)
+	healthy = true
+	client.GET("/api/users").Do().ExpectStatus(200)
+	tt.Equal(zutil.BreakerClosed, b.Get("/api/users").State())
+	tt.Equal([]string{"/api/users:open", "/api/users:half-open", "/api/users:closed"}, changes)
+
+	client.GET("/api/panic").Do().ExpectStatus(500)
+	tt.Equal(int64(1), b.Get("/api/panic").Counts().Failures)
+
+	fallback := breaker.New(func(conf *breaker.Config) {
+		conf.ConsecutiveFailures = 1
+		conf.Log = nil
+		conf.Key = func(c *znet.Context) string { return "shared" }
+		conf.Fallback = func(c *znet.Context) {
+			c.String(200, "cached")
+		}
+	})
+	r.GET("/fallback", func(c *znet.Context) {
+		c.String(500, "error")
+	}, fallback)
+	client.GET("/fallback").Do().ExpectStatus(500)
+	client.GET("/fallback").Do().ExpectStatus(200).ExpectBody("cached")
+}
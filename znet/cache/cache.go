@@ -1,78 +1,319 @@
 package cache
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sohaha/zlsgo/zcache"
 	"github.com/sohaha/zlsgo/znet"
 	"github.com/sohaha/zlsgo/zstring"
+	"golang.org/x/sync/singleflight"
 )
 
 type (
 	// Config configuration
 	Config struct {
+		// Custom returns the cache key of the request and its expiration,
+		// an empty key skips the cache, zero expiration uses the default
 		Custom func(c *znet.Context) (key string, expiration time.Duration)
+		// Vary lists the request headers whose values are part of the cache key,
+		// e.g. Accept-Encoding, Accept-Language or Authorization
+		Vary []string
+		// StaleWhileRevalidate serves an expired response for this long while it is
+		// refreshed in the background, the stale-while-revalidate of the handler
+		// Cache-Control takes precedence
+		StaleWhileRevalidate time.Duration
 		zcache.Options
 	}
+	// Cache caches the responses of the handlers after the middleware,
+	// the Cache-Control of the handler is respected: no-store, no-cache and
+	// private responses are not cached, s-maxage and max-age set the expiration.
+	// Responses setting cookies are never cached, responses to requests with
+	// Authorization only when they are public, have s-maxage or Vary has Authorization
+	Cache struct {
+		store      *zcache.FastCache
+		group      singleflight.Group
+		refreshing sync.Map
+		conf       Config
+		varyAuth   bool
+	}
 	cacheContext struct {
-		Type    string
-		Content []byte
-		Code    int32
+		StoredAt   time.Time
+		ExpiresAt  time.Time
+		StaleUntil time.Time
+		Header     map[string]string
+		Type       string
+		Content    []byte
+		Tags       []string
+		Code       int32
 	}
 )
 
+const tagsKey = "cache_tags"
+
+// replayHeaders are the response headers stored with the content
+var replayHeaders = []string{
+	"Cache-Control", "Content-Encoding", "Content-Language", "Content-Type",
+	"ETag", "Expires", "Last-Modified", "Vary",
+}
+
+// New returns a middleware caching the responses
 func New(opt ...func(conf *Config)) znet.HandlerFunc {
-	conf := Config{
+	return NewCache(opt...).Handler()
+}
+
+// NewCache creates a response cache, use Handler as the middleware and
+// PurgePrefix or PurgeTag to invalidate responses
+func NewCache(opt ...func(conf *Config)) *Cache {
+	cc := &Cache{conf: Config{
 		Custom: func(c *znet.Context) (key string, expiration time.Duration) {
 			return QueryKey(c), 0
 		},
-	}
+	}}
 
-	cache := zcache.NewFast(func(o *zcache.Options) {
-		conf.Options = *o
-		conf.Options.Expiration = time.Minute * 10
+	cc.store = zcache.NewFast(func(o *zcache.Options) {
+		cc.conf.Options = *o
+		cc.conf.Options.Expiration = time.Minute * 10
 		for _, f := range opt {
-			f(&conf)
+			f(&cc.conf)
 		}
-		*o = conf.Options
+		*o = cc.conf.Options
 	})
+	for i := range cc.conf.Vary {
+		cc.conf.Vary[i] = http.CanonicalHeaderKey(cc.conf.Vary[i])
+		if cc.conf.Vary[i] == "Authorization" {
+			cc.varyAuth = true
+		}
+	}
+	return cc
+}
 
+// Handler returns the cache middleware
+func (cc *Cache) Handler() znet.HandlerFunc {
+	vary := strings.Join(cc.conf.Vary, ", ")
 	return func(c *znet.Context) {
-		key, expiration := conf.Custom(c)
-		if key == "" {
+		base, expiration := cc.conf.Custom(c)
+		if base == "" {
 			c.Next()
 			return
 		}
+		if vary != "" {
+			c.SetHeader("Vary", vary, true)
+		}
+
+		key := cc.key(c, base)
+		if v, ok := cc.store.Get(key); ok {
+			if data, ok := v.(*cacheContext); ok {
+				now := time.Now()
+				if now.Before(data.ExpiresAt) {
+					data.write(c, now)
+					return
+				}
+				if now.Before(data.StaleUntil) {
+					cc.revalidate(c, key, expiration)
+					data.write(c, now)
+					return
+				}
+			}
+		}
 
-		v, ok := cache.ProvideGet(key, func() (interface{}, bool) {
+		ran := false
+		v, _, _ := cc.group.Do(key, func() (interface{}, error) {
+			ran = true
 			c.Next()
+			return cc.save(c, key, expiration), nil
+		})
+		if ran {
+			return
+		}
+		if data, ok := v.(*cacheContext); ok && data != nil {
+			data.write(c, time.Now())
+			return
+		}
+		c.Next()
+	}
+}
 
-			p := c.PrevContent()
-			if p.Code.Load() != 0 {
-				return &cacheContext{
-					Code:    p.Code.Load(),
-					Type:    p.Type,
-					Content: p.Content,
-				}, true
+// PurgePrefix removes the responses whose key starts with prefix,
+// the key is the request path with the sorted query by default
+func (cc *Cache) PurgePrefix(prefix string) int {
+	return cc.purge(func(key string, _ *cacheContext) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// PurgeTag removes the responses tagged with any of tags, see Tag
+func (cc *Cache) PurgeTag(tags ...string) int {
+	return cc.purge(func(_ string, data *cacheContext) bool {
+		for _, t := range data.Tags {
+			for i := range tags {
+				if t == tags[i] {
+					return true
+				}
 			}
-			return nil, false
-		}, expiration)
+		}
+		return false
+	})
+}
 
-		if !ok {
-			return
+// Tag tags the response of the request, tagged responses can be removed with PurgeTag
+func Tag(c *znet.Context, tags ...string) {
+	old, _ := c.MustValue(tagsKey, []string(nil)).([]string)
+	c.WithValue(tagsKey, append(old, tags...))
+}
+
+func (cc *Cache) purge(match func(key string, data *cacheContext) bool) int {
+	var keys []string
+	cc.store.ForEach(func(key string, v interface{}) bool {
+		if data, ok := v.(*cacheContext); ok && match(key, data) {
+			keys = append(keys, key)
 		}
+		return true
+	})
+	for i := range keys {
+		cc.store.Delete(keys[i])
+	}
+	return len(keys)
+}
 
-		if data, ok := v.(*cacheContext); ok {
-			p := c.PrevContent()
-			p.Code.Store(data.Code)
-			p.Content = data.Content
-			p.Type = data.Type
-			c.Abort()
+func (cc *Cache) key(c *znet.Context, base string) string {
+	if len(cc.conf.Vary) == 0 {
+		return base
+	}
+	h := sha256.New()
+	for i := range cc.conf.Vary {
+		h.Write([]byte(c.GetHeader(cc.conf.Vary[i])))
+		h.Write([]byte{0})
+	}
+	return base + "#" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// save stores the response of c when it is cacheable
+func (cc *Cache) save(c *znet.Context, key string, expiration time.Duration) *cacheContext {
+	p := c.PrevContent()
+	code := p.Code.Load()
+	if code == 0 || code >= http.StatusInternalServerError ||
+		c.GetResponseHeader("Set-Cookie") != "" || len(c.Writer.Header()["Set-Cookie"]) > 0 {
+		return nil
+	}
+
+	directives := parseCacheControl(c.GetResponseHeader("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return nil
 		}
 	}
+	if !cc.varyAuth && c.GetHeader("Authorization") != "" {
+		_, public := directives["public"]
+		_, shared := directives["s-maxage"]
+		if !public && !shared {
+			return nil
+		}
+	}
+
+	stale := cc.conf.StaleWhileRevalidate
+	if expiration <= 0 {
+		expiration = cc.conf.Expiration
+	}
+	if age, ok := directives["s-maxage"]; ok {
+		expiration = age
+	} else if age, ok := directives["max-age"]; ok {
+		expiration = age
+	}
+	if age, ok := directives["stale-while-revalidate"]; ok {
+		stale = age
+	}
+	if expiration <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	data := &cacheContext{
+		StoredAt:   now,
+		ExpiresAt:  now.Add(expiration),
+		StaleUntil: now.Add(expiration + stale),
+		Header:     make(map[string]string, len(replayHeaders)),
+		Code:       code,
+		Type:       p.Type,
+		Content:    p.Content,
+	}
+	for _, k := range replayHeaders {
+		if v := c.GetResponseHeader(k); v != "" {
+			data.Header[k] = v
+		}
+	}
+	data.Tags, _ = c.MustValue(tagsKey, []string(nil)).([]string)
+	cc.store.Set(key, data, expiration+stale)
+	return data
 }
 
+// revalidate runs the rest of the handlers on a copy of the request in the
+// background, at most once per key at a time
+func (cc *Cache) revalidate(c *znet.Context, key string, expiration time.Duration) {
+	if _, loaded := cc.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	clone := c.Clone(discardWriter{header: http.Header{}}, c.Request.Clone(context.Background()))
+	go func() {
+		defer cc.refreshing.Delete(key)
+		defer func() {
+			if err := recover(); err != nil {
+				clone.Log.Error("cache revalidate:", err)
+			}
+		}()
+		clone.Next()
+		cc.save(clone, key, expiration)
+	}()
+}
+
+func (data *cacheContext) write(c *znet.Context, now time.Time) {
+	for k, v := range data.Header {
+		c.SetHeader(k, v, true)
+	}
+	c.SetHeader("Age", strconv.Itoa(int(now.Sub(data.StoredAt)/time.Second)), true)
+	p := c.PrevContent()
+	p.Code.Store(data.Code)
+	p.Content = data.Content
+	p.Type = data.Type
+	c.Abort()
+}
+
+func parseCacheControl(v string) map[string]time.Duration {
+	directives := make(map[string]time.Duration)
+	for _, d := range strings.Split(v, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		sec, _ := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+		directives[name] = time.Duration(sec) * time.Second
+	}
+	return directives
+}
+
+type discardWriter struct {
+	header http.Header
+}
+
+func (w discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w discardWriter) WriteHeader(int) {}
+
+// QueryKey returns the request path with the sorted query as the cache key
 func QueryKey(c *znet.Context) (key string) {
 	m := c.GetAllQueryMaps()
 	mLen := len(m)
@@ -3,6 +3,8 @@ package cache_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -68,3 +70,135 @@ func TestCache(t *testing.T) {
 	}
 	_ = wg.Wait()
 }
+
+func get(r *znet.Engine, path string, header ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCacheControl(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	var calls int64
+	cc := cache.NewCache(func(conf *cache.Config) {
+		conf.Vary = []string{"accept-language"}
+	})
+	e := znet.New("cache-control-test")
+	e.SetMode(znet.QuietMode)
+	e.Use(cc.Handler())
+	e.GET("/page", func(c *znet.Context) {
+		atomic.AddInt64(&calls, 1)
+		cache.Tag(c, "page")
+		c.SetHeader("Cache-Control", "public, max-age=60")
+		c.String(200, c.GetHeader("Accept-Language")+strconv.FormatInt(atomic.LoadInt64(&calls), 10))
+	})
+	e.GET("/private", func(c *znet.Context) {
+		atomic.AddInt64(&calls, 1)
+		c.SetHeader("Cache-Control", "private")
+		c.String(200, strconv.FormatInt(atomic.LoadInt64(&calls), 10))
+	})
+	e.GET("/error", func(c *znet.Context) {
+		atomic.AddInt64(&calls, 1)
+		c.String(500, "error")
+	})
+
+	w := get(e, "/page", "Accept-Language", "en")
+	tt.Equal("en1", w.Body.String())
+	tt.Equal("Accept-Language", w.Header().Get("Vary"))
+	w = get(e, "/page", "Accept-Language", "en")
+	tt.Equal("en1", w.Body.String())
+	tt.Equal("public, max-age=60", w.Header().Get("Cache-Control"))
+	tt.Equal("0", w.Header().Get("Age"))
+	tt.Equal("zh2", get(e, "/page", "Accept-Language", "zh").Body.String())
+	tt.Equal("zh2", get(e, "/page", "Accept-Language", "zh").Body.String())
+
+	tt.Equal("3", get(e, "/private").Body.String())
+	tt.Equal("4", get(e, "/private").Body.String())
+	tt.Equal(500, get(e, "/error").Code)
+	tt.Equal(500, get(e, "/error").Code)
+	tt.Equal(int64(6), atomic.LoadInt64(&calls))
+
+	tt.Equal(2, cc.PurgeTag("page"))
+	tt.Equal("en7", get(e, "/page", "Accept-Language", "en").Body.String())
+	tt.Equal(0, cc.PurgePrefix("/other"))
+	tt.Equal(1, cc.PurgePrefix("/pa"))
+	tt.Equal("en8", get(e, "/page", "Accept-Language", "en").Body.String())
+}
+
+func TestCacheUncacheable(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	var calls int64
+	handler := func(c *znet.Context) {
+		atomic.AddInt64(&calls, 1)
+		c.String(200, strconv.FormatInt(atomic.LoadInt64(&calls), 10))
+	}
+	e := znet.New("cache-uncacheable-test")
+	e.SetMode(znet.QuietMode)
+	e.Use(cache.New())
+	e.GET("/cookie", func(c *znet.Context) {
+		c.SetCookie("sid", "secret")
+		handler(c)
+	})
+	e.GET("/auth", handler)
+	e.GET("/public", func(c *znet.Context) {
+		c.SetHeader("Cache-Control", "public, max-age=60")
+		handler(c)
+	})
+
+	tt.Equal("1", get(e, "/cookie").Body.String())
+	tt.Equal("2", get(e, "/cookie").Body.String())
+	tt.Equal("3", get(e, "/auth", "Authorization", "Bearer a").Body.String())
+	tt.Equal("4", get(e, "/auth", "Authorization", "Bearer b").Body.String())
+	tt.Equal("5", get(e, "/public", "Authorization", "Bearer a").Body.String())
+	tt.Equal("5", get(e, "/public", "Authorization", "Bearer b").Body.String())
+
+	v := znet.New("cache-vary-auth-test")
+	v.SetMode(znet.QuietMode)
+	v.Use(cache.New(func(conf *cache.Config) {
+		conf.Vary = []string{"authorization"}
+	}))
+	v.GET("/auth", handler)
+	tt.Equal("6", get(v, "/auth", "Authorization", "Bearer a").Body.String())
+	tt.Equal("6", get(v, "/auth", "Authorization", "Bearer a").Body.String())
+	tt.Equal("7", get(v, "/auth", "Authorization", "Bearer b").Body.String())
+}
+
+func TestCacheCollapseAndStale(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	var calls int64
+	e := znet.New("cache-stale-test")
+	e.SetMode(znet.QuietMode)
+	e.GET("/slow", func(c *znet.Context) {
+		n := atomic.AddInt64(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		c.SetHeader("Cache-Control", "max-age=1, stale-while-revalidate=10")
+		c.String(200, strconv.FormatInt(n, 10))
+	}, cache.New())
+
+	var wg zsync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Go(func() {
+			w := get(e, "/slow")
+			tt.Equal(200, w.Code)
+			tt.Equal("1", w.Body.String())
+		})
+	}
+	_ = wg.Wait()
+	tt.Equal(int64(1), atomic.LoadInt64(&calls))
+
+	time.Sleep(1100 * time.Millisecond)
+	start := time.Now()
+	tt.Equal("1", get(e, "/slow").Body.String())
+	tt.EqualTrue(time.Since(start) < 40*time.Millisecond)
+	tt.Equal("1", get(e, "/slow").Body.String())
+	time.Sleep(100 * time.Millisecond)
+	tt.Equal(int64(2), atomic.LoadInt64(&calls))
+	tt.Equal("2", get(e, "/slow").Body.String())
+}
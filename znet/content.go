@@ -0,0 +1,178 @@
+package znet
+
+import (
+	"bytes"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/zfile"
+	"github.com/sohaha/zlsgo/zstring"
+)
+
+type (
+	// cacheControl is a Cache-Control rule for a path pattern
+	cacheControl struct {
+		pattern string
+		value   string
+	}
+	// cacheControls holds the rules shared by an engine and its groups
+	cacheControls struct {
+		rules []cacheControl
+		mu    sync.RWMutex
+	}
+	// contentRecorder captures the response of http.ServeContent
+	contentRecorder struct {
+		header http.Header
+		body   bytes.Buffer
+		code   int
+	}
+)
+
+func (r *contentRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *contentRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func (r *contentRecorder) WriteHeader(code int) {
+	r.code = code
+}
+
+// SetCacheControl sets the Cache-Control header of files served by Static, StaticFS,
+// StaticFile and Context.File whose request path matches pattern (supports * and ?),
+// rules are checked in the order they were added, setting a pattern again replaces its value
+func (e *Engine) SetCacheControl(pattern, value string) {
+	cc := e.cacheControls
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for i := range cc.rules {
+		if cc.rules[i].pattern == pattern {
+			cc.rules[i].value = value
+			return
+		}
+	}
+	cc.rules = append(cc.rules, cacheControl{pattern: pattern, value: value})
+}
+
+func (e *Engine) cacheControlOf(path string) string {
+	cc := e.cacheControls
+	if cc == nil {
+		return ""
+	}
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	for i := range cc.rules {
+		if zstring.Match(path, cc.rules[i].pattern) {
+			return cc.rules[i].value
+		}
+	}
+	return ""
+}
+
+// ServeContent responds content with a strong ETag and handles If-None-Match,
+// If-Modified-Since, If-Range and byte Range requests (multipart/byteranges for
+// several ranges), name is used to detect the content type when it is not set
+func (c *Context) ServeContent(name string, modTime time.Time, content []byte) {
+	rec := &contentRecorder{header: make(http.Header), code: http.StatusOK}
+
+	r := c.mu.RLock()
+	for k, v := range c.header {
+		rec.header[k] = v
+	}
+	c.mu.RUnlock(r)
+
+	if rec.header.Get("Cache-Control") == "" && c.Engine != nil {
+		if v := c.Engine.cacheControlOf(c.Request.URL.Path); v != "" {
+			rec.header.Set("Cache-Control", v)
+		}
+	}
+	if rec.header.Get("ETag") == "" {
+		rec.header.Set("ETag", strongETag(content))
+	}
+	if rec.header.Get("Content-Type") == "" {
+		if rec.header.Get("Content-Encoding") != "" {
+			rec.header.Set("Content-Type", zfile.GetMimeType(name, nil))
+		} else {
+			rec.header.Set("Content-Type", zfile.GetMimeType(name, content))
+		}
+	}
+
+	http.ServeContent(rec, c.Request, name, modTime, bytes.NewReader(content))
+
+	c.mu.Lock()
+	for k := range c.header {
+		delete(c.header, k)
+	}
+	for k, v := range rec.header {
+		c.header[k] = v
+	}
+	c.render = nil
+	c.mu.Unlock()
+
+	c.prevData.Type = rec.header.Get("Content-Type")
+	c.prevData.Content = rec.body.Bytes()
+	c.prevData.Code.Store(int32(rec.code))
+}
+
+// serveFile serves the file opened by open, a precompressed .gz sibling
+// is served instead when the client accepts gzip
+func (c *Context) serveFile(open func(name string) (http.File, error), name string) {
+	f, err := open(name)
+	if err != nil {
+		c.toHTTPError(err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		c.toHTTPError(err)
+		return
+	}
+
+	if gz, err := open(name + ".gz"); err == nil {
+		defer gz.Close()
+		c.SetHeader("Vary", "Accept-Encoding")
+		if gzInfo, err := gz.Stat(); err == nil && !gzInfo.IsDir() && acceptsGzip(c) {
+			f, info = gz, gzInfo
+			c.SetHeader("Content-Encoding", "gzip", true)
+		}
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		c.toHTTPError(err)
+		return
+	}
+	c.ServeContent(name, info.ModTime(), content)
+}
+
+func acceptsGzip(c *Context) bool {
+	for _, v := range strings.Split(c.GetHeader("Accept-Encoding"), ",") {
+		v = strings.TrimSpace(v)
+		name, q, _ := strings.Cut(v, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		if q = strings.TrimSpace(q); strings.HasPrefix(q, "q=") {
+			if f, err := strconv.ParseFloat(q[2:], 64); err == nil && f == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func strongETag(content []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(content)
+	return `"` + strconv.FormatInt(int64(len(content)), 36) + "-" + strconv.FormatUint(h.Sum64(), 36) + `"`
+}
@@ -0,0 +1,128 @@
+package znet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+)
+
+func TestStaticCaching(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	dir := t.TempDir()
+	js := strings.Repeat("console.log('zlsgo');\n", 100)
+	_ = os.WriteFile(filepath.Join(dir, "app.js"), []byte(js), 0o644)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(js))
+	_ = zw.Close()
+	_ = os.WriteFile(filepath.Join(dir, "app.js.gz"), gz.Bytes(), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>0123456789</html>"), 0o644)
+
+	r := New("static-caching-test")
+	r.SetMode(QuietMode)
+	r.SetCacheControl("/assets/*.js", "public, max-age=31536000, immutable")
+	r.SetCacheControl("*", "no-cache")
+	r.Static("/assets/", dir)
+	r.GET("/index", func(c *Context) {
+		c.File(filepath.Join(dir, "index.html"))
+	})
+
+	header := func(kv ...string) func(w *httptest.ResponseRecorder, req *http.Request) {
+		return func(w *httptest.ResponseRecorder, req *http.Request) {
+			for i := 0; i < len(kv); i += 2 {
+				req.Header.Set(kv[i], kv[i+1])
+			}
+		}
+	}
+
+	w := request(r, "GET", "/assets/app.js", nil)
+	tt.Equal(200, w.Code)
+	tt.Equal(js, w.Body.String())
+	tt.Equal("public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+	tt.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	tt.Equal("bytes", w.Header().Get("Accept-Ranges"))
+	tt.EqualTrue(strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript"))
+	etag := w.Header().Get("ETag")
+	tt.EqualTrue(strings.HasPrefix(etag, `"`))
+
+	w = request(r, "GET", "/assets/app.js", nil, header("If-None-Match", etag))
+	tt.Equal(304, w.Code)
+	tt.Equal(0, w.Body.Len())
+
+	w = request(r, "GET", "/assets/app.js", nil, header("Accept-Encoding", "br, gzip"))
+	tt.Equal(200, w.Code)
+	tt.Equal("gzip", w.Header().Get("Content-Encoding"))
+	tt.Equal(gz.Bytes(), w.Body.Bytes())
+	tt.EqualTrue(strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript"))
+	tt.EqualTrue(w.Header().Get("ETag") != etag)
+
+	w = request(r, "GET", "/assets/app.js", nil, header("Accept-Encoding", "gzip;q=0"))
+	tt.Equal("", w.Header().Get("Content-Encoding"))
+
+	w = request(r, "GET", "/index", nil, header("Range", "bytes=6-15"))
+	tt.Equal(206, w.Code)
+	tt.Equal("0123456789", w.Body.String())
+	tt.Equal("bytes 6-15/23", w.Header().Get("Content-Range"))
+	tt.Equal("no-cache", w.Header().Get("Cache-Control"))
+
+	etag = w.Header().Get("ETag")
+	w = request(r, "GET", "/index", nil, header("Range", "bytes=0-0", "If-Range", `"other"`))
+	tt.Equal(200, w.Code)
+	w = request(r, "GET", "/index", nil, header("Range", "bytes=0-0", "If-Range", etag))
+	tt.Equal(206, w.Code)
+	tt.Equal("<", w.Body.String())
+
+	w = request(r, "GET", "/index", nil, header("Range", "bytes=0-5,16-22"))
+	tt.Equal(206, w.Code)
+	mediaType, params, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	tt.Equal("multipart/byteranges", mediaType)
+	mr := multipart.NewReader(w.Body, params["boundary"])
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		b, _ := io.ReadAll(p)
+		parts = append(parts, p.Header.Get("Content-Range")+" "+string(b))
+	}
+	tt.Equal([]string{"bytes 0-5/23 <html>", "bytes 16-22/23 </html>"}, parts)
+
+	w = request(r, "GET", "/index", nil, header("Range", "bytes=100-200"))
+	tt.Equal(416, w.Code)
+	tt.Equal("bytes */23", w.Header().Get("Content-Range"))
+
+	w = request(r, "GET", "/assets/none.js", nil)
+	tt.Equal(404, w.Code)
+}
+
+func TestCacheControlGroup(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a.js"), []byte("a"), 0o644)
+
+	r := New("cache-control-group-test")
+	r.SetMode(QuietMode)
+	r.Group("/assets", func(e *Engine) {
+		e.GET("/a.js", func(c *Context) {
+			c.File(filepath.Join(dir, "a.js"))
+		})
+	})
+	r.SetCacheControl("/assets/*", "public, max-age=60")
+
+	w := request(r, "GET", "/assets/a.js", nil)
+	tt.Equal(200, w.Code)
+	tt.Equal("public, max-age=60", w.Header().Get("Cache-Control"))
+}
@@ -82,6 +82,75 @@ func (c *Context) IsSSE() bool {
 	return false
 }
 
+// Accepts returns the offered media type preferred by the Accept header,
+// the first offer when the header is empty, or "" when none is acceptable.
+// Quality values and wildcards such as text/* and */* are honored, a more
+// specific media range takes precedence over a wildcard.
+func (c *Context) Accepts(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	header := c.GetHeader("Accept")
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		typ, sub string
+		q        float64
+	}
+	ranges := make([]mediaRange, 0, 4)
+	for _, v := range strings.Split(header, ",") {
+		params := strings.Split(v, ";")
+		typ, sub, _ := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if typ == "" {
+			continue
+		}
+		if sub == "" {
+			sub = "*"
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			k, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, sub: sub, q: q})
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		typ, sub, _ := strings.Cut(strings.ToLower(offer), "/")
+		if i := strings.IndexByte(sub, ';'); i >= 0 {
+			sub = strings.TrimSpace(sub[:i])
+		}
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := 0
+			switch {
+			case r.typ == typ && r.sub == sub:
+				s = 2
+			case r.typ == typ && r.sub == "*":
+				s = 1
+			case r.typ == "*" && r.sub == "*":
+				s = 0
+			default:
+				continue
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
 // IsAjax determines if the current request is an AJAX request
 // by checking for the X-Requested-With header with value XMLHttpRequest.
 func (c *Context) IsAjax() bool {
@@ -97,13 +166,17 @@ func (c *Context) GetClientIP() string {
 	c.mu.RUnlock(r)
 	if ip == "" {
 		c.mu.Lock()
-		ips := getRemoteIP(c.Request)
-		ip = clientPublicIP(c.Request, ips)
-		if ip == "" {
-			ip = clientIP(c.Request, ips)
-		}
-		if ip == "" {
-			ip = RemoteIP(c.Request)
+		if proxies, ok := c.trustedProxies(); ok {
+			ip = trustedClientIP(c.Request, proxies)
+		} else {
+			ips := getRemoteIP(c.Request)
+			ip = clientPublicIP(c.Request, ips)
+			if ip == "" {
+				ip = clientIP(c.Request, ips)
+			}
+			if ip == "" {
+				ip = RemoteIP(c.Request)
+			}
 		}
 		c.ip = ip
 		c.mu.Unlock()
@@ -134,6 +207,17 @@ func (c *Context) SetHeader(key, value string, only ...bool) {
 	c.mu.Unlock()
 }
 
+// GetResponseHeader returns the first value of the response header set by SetHeader.
+func (c *Context) GetResponseHeader(key string) string {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	r := c.mu.RLock()
+	defer c.mu.RUnlock(r)
+	if v := c.header[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
 // write finalizes the response by writing headers and body data to the response writer.
 // It handles content negotiation, status codes, and ensures headers are properly set.
 func (c *Context) write() {
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/sohaha/zlsgo"
 	"github.com/sohaha/zlsgo/zdi"
 )
 
@@ -242,6 +243,27 @@ func TestIsSSE(t *testing.T) {
 	r.ServeHTTP(w2, req2)
 }
 
+func TestAccepts(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("accepts-test")
+	r.SetMode(QuietMode)
+	r.GET("/", func(c *Context) {
+		c.String(200, c.Accepts("application/json", "text/html", "text/plain"))
+	})
+
+	for accept, expected := range map[string]string{
+		"":                                  "application/json",
+		"*/*":                               "application/json",
+		"text/html,application/xml;q=0.9":   "text/html",
+		"text/*;q=0.5, text/plain":          "text/plain",
+		"text/*, text/html;q=0":             "text/plain",
+		"application/json;q=0.2, */*;q=0.5": "text/html",
+		"image/png":                         "",
+	} {
+		r.Test(tt).GET("/").Header("Accept", accept).Do().ExpectBody(expected)
+	}
+}
+
 func TestGetReferer(t *testing.T) {
 	r := New()
 
@@ -0,0 +1,194 @@
+// Package csrf provides CSRF protection for znet
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/session"
+	"github.com/sohaha/zlsgo/zstring"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// Config configuration
+	Config struct {
+		// ErrorHandler responds to rejected requests, default is 403 with the error message
+		ErrorHandler func(c *znet.Context, err error)
+		// Skipper skips the check for the request when it returns true
+		Skipper func(c *znet.Context) bool
+		// Secret signs the double-submit cookie, a random secret is used when empty,
+		// so tokens do not survive restarts and are not shared between instances
+		Secret string
+		// CookieName of the double-submit cookie, default is _csrf
+		CookieName string
+		// SessionKey of the token in the session, default is csrf_token
+		SessionKey string
+		// Header carrying the token, default is X-CSRF-Token
+		Header string
+		// Field is the form or query field carrying the token, default is _csrf
+		Field string
+		// MaxAge of the double-submit cookie, default is 12 hours
+		MaxAge time.Duration
+		// Session stores the token in the znet/session of the request instead of a cookie,
+		// the session middleware must run before this middleware
+		Session bool
+	}
+)
+
+const (
+	// ContextKey is the key of the token stored on znet.Context
+	ContextKey  = "csrf_token"
+	fieldKey    = "csrf_field"
+	tokenLength = 32
+)
+
+var (
+	// ErrTokenMissing the request carries no token
+	ErrTokenMissing = errors.New("csrf token missing")
+	// ErrTokenInvalid the token of the request does not match
+	ErrTokenInvalid = errors.New("csrf token invalid")
+)
+
+// New returns a middleware that issues a token per client and validates it on
+// unsafe methods, the token is read from the header, form field or query field
+func New(opt ...func(conf *Config)) znet.HandlerFunc {
+	conf := zutil.Optional(Config{
+		CookieName: "_csrf",
+		SessionKey: "csrf_token",
+		Header:     "X-CSRF-Token",
+		Field:      "_csrf",
+		MaxAge:     12 * time.Hour,
+		ErrorHandler: func(c *znet.Context, err error) {
+			c.String(http.StatusForbidden, err.Error())
+		},
+	}, opt...)
+
+	secret := []byte(conf.Secret)
+	if len(secret) == 0 {
+		s, err := zstring.SecureRandString(tokenLength)
+		if err != nil {
+			panic(err)
+		}
+		secret = []byte(s)
+	}
+
+	return func(c *znet.Context) {
+		var (
+			token string
+			save  func(token string) error
+		)
+		if conf.Session {
+			s, err := session.Get(c)
+			if err != nil {
+				c.Log.Error("csrf: session middleware is required")
+				c.Abort(http.StatusInternalServerError)
+				return
+			}
+			token = s.Get(conf.SessionKey).String()
+			save = func(token string) error {
+				s.Set(conf.SessionKey, token)
+				return s.Save()
+			}
+		} else {
+			token = verifyCookie(c.GetCookie(conf.CookieName), secret)
+			save = func(token string) error {
+				c.SetSecureCookie(conf.CookieName, signCookie(token, secret), int(conf.MaxAge.Seconds()))
+				return nil
+			}
+		}
+
+		if !(conf.Skipper != nil && conf.Skipper(c)) && !safeMethod(c.Request.Method) {
+			sent := c.GetHeader(conf.Header)
+			if sent == "" {
+				sent = c.DefaultFormOrQuery(conf.Field, "")
+			}
+			var err error
+			if sent == "" {
+				err = ErrTokenMissing
+			} else if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				err = ErrTokenInvalid
+			}
+			if err != nil {
+				conf.ErrorHandler(c, err)
+				c.Abort()
+				return
+			}
+		}
+
+		if token == "" {
+			var err error
+			if token, err = zstring.SecureRandString(tokenLength); err == nil {
+				err = save(token)
+			}
+			if err != nil {
+				c.Log.Error("csrf:", err)
+				c.Abort(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		c.WithValue(ContextKey, token)
+		c.WithValue(fieldKey, conf.Field)
+		c.Next()
+	}
+}
+
+// Token returns the token of the request, empty when the middleware did not run
+func Token(c *znet.Context) string {
+	return c.MustValue(ContextKey, "").(string)
+}
+
+// FuncMap returns the template functions csrfToken and csrfField of the request,
+// pass it as the funcMap of Context.Template
+func FuncMap(c *znet.Context) template.FuncMap {
+	name := c.MustValue(fieldKey, "_csrf").(string)
+	token := Token(c)
+	return template.FuncMap{
+		"csrfToken": func() string {
+			return token
+		},
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(name) +
+				`" value="` + template.HTMLEscapeString(token) + `">`)
+		},
+	}
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func signCookie(token string, secret []byte) string {
+	return token + "." + sign(token, secret)
+}
+
+func verifyCookie(value string, secret []byte) string {
+	i := strings.LastIndexByte(value, '.')
+	if i <= 0 {
+		return ""
+	}
+	token := value[:i]
+	if !hmac.Equal([]byte(value[i+1:]), []byte(sign(token, secret))) {
+		return ""
+	}
+	return token
+}
+
+func sign(token string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
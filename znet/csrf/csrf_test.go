@@ -0,0 +1,119 @@
+package csrf_test
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/csrf"
+	"github.com/sohaha/zlsgo/znet/session"
+)
+
+func request(r *znet.Engine, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCookie(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := znet.New("csrf-cookie-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(csrf.New(func(conf *csrf.Config) {
+		conf.Secret = "secret"
+	}))
+	r.GET("/form", func(c *znet.Context) {
+		var buf bytes.Buffer
+		tpl := template.Must(template.New("").Funcs(csrf.FuncMap(c)).Parse(`<form>{{ csrfField }}</form>`))
+		_ = tpl.Execute(&buf, nil)
+		c.String(200, buf.String())
+	})
+	r.POST("/form", func(c *znet.Context) {
+		c.String(200, "ok")
+	})
+
+	w := request(r, "GET", "/form", "", nil)
+	tt.Equal(200, w.Code)
+	res := http.Response{Header: w.Header()}
+	cookies := res.Cookies()
+	tt.Equal(1, len(cookies))
+	tt.Equal("_csrf", cookies[0].Name)
+	tt.EqualTrue(cookies[0].Secure)
+	token := strings.Split(cookies[0].Value, ".")[0]
+	tt.Equal(32, len(token))
+	tt.Equal(`<form><input type="hidden" name="_csrf" value="`+token+`"></form>`, w.Body.String())
+	cookie := "_csrf=" + cookies[0].Value
+
+	w = request(r, "GET", "/form", "", map[string]string{"Cookie": cookie})
+	tt.Equal(0, len(w.Header().Values("Set-Cookie")))
+
+	w = request(r, "POST", "/form", "", map[string]string{"Cookie": cookie, "X-CSRF-Token": token})
+	tt.Equal(200, w.Code)
+	w = request(r, "POST", "/form", url.Values{"_csrf": {token}}.Encode(), map[string]string{"Cookie": cookie})
+	tt.Equal(200, w.Code)
+	w = request(r, "POST", "/form?_csrf="+token, "", map[string]string{"Cookie": cookie})
+	tt.Equal(200, w.Code)
+
+	w = request(r, "POST", "/form", "", map[string]string{"Cookie": cookie})
+	tt.Equal(403, w.Code)
+	tt.Equal(csrf.ErrTokenMissing.Error(), w.Body.String())
+	w = request(r, "POST", "/form", "", map[string]string{"Cookie": cookie, "X-CSRF-Token": token + "x"})
+	tt.Equal(403, w.Code)
+	tt.Equal(csrf.ErrTokenInvalid.Error(), w.Body.String())
+	w = request(r, "POST", "/form", "", map[string]string{"Cookie": "_csrf=" + token + ".forged", "X-CSRF-Token": token})
+	tt.Equal(403, w.Code)
+}
+
+func TestSession(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := znet.New("csrf-session-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(session.Default())
+	r.Use(csrf.New(func(conf *csrf.Config) {
+		conf.Session = true
+		conf.Skipper = func(c *znet.Context) bool {
+			return c.Request.URL.Path == "/webhook"
+		}
+	}))
+	r.GET("/token", func(c *znet.Context) {
+		c.String(200, csrf.Token(c))
+	})
+	r.POST("/save", func(c *znet.Context) {
+		c.String(200, "saved")
+	})
+	r.POST("/webhook", func(c *znet.Context) {
+		c.String(200, "hook")
+	})
+
+	w := request(r, "GET", "/token", "", nil)
+	token := w.Body.String()
+	tt.Equal(32, len(token))
+	cookie := w.Header().Get("Set-Cookie")
+	tt.EqualTrue(strings.HasPrefix(cookie, "session_id="))
+	cookie = strings.Split(cookie, ";")[0]
+
+	w = request(r, "GET", "/token", "", map[string]string{"Cookie": cookie})
+	tt.Equal(token, w.Body.String())
+
+	w = request(r, "POST", "/save", "", map[string]string{"Cookie": cookie, "X-CSRF-Token": token})
+	tt.Equal(200, w.Code)
+	w = request(r, "POST", "/save", "", map[string]string{"X-CSRF-Token": token})
+	tt.Equal(403, w.Code)
+	w = request(r, "POST", "/webhook", "", nil)
+	tt.Equal(200, w.Code)
+}
@@ -0,0 +1,448 @@
+package znet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sohaha/zlsgo/ztype"
+)
+
+type (
+	// orderedMap keeps the member order of a decoded JSON object
+	orderedMap    []orderedMember
+	orderedMember struct {
+		value interface{}
+		key   string
+	}
+)
+
+// toGeneric converts data to nil, bool, string, json.Number, []interface{}
+// and orderedMap through its JSON encoding, so json tags are respected
+func toGeneric(data interface{}) (interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeGeneric(dec)
+}
+
+func decodeGeneric(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := t.(json.Delim)
+	if !ok {
+		return t, nil
+	}
+	switch d {
+	case '{':
+		m := orderedMap{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeGeneric(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, orderedMember{key: k.(string), value: v})
+		}
+		_, err = dec.Token()
+		return m, err
+	default:
+		l := []interface{}{}
+		for dec.More() {
+			v, err := decodeGeneric(dec)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		_, err = dec.Token()
+		return l, err
+	}
+}
+
+// encodeXML encodes structs with encoding/xml, maps and slices of maps
+// are written as a root element with an item element per slice entry
+func encodeXML(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+
+	switch t := indirectType(data); {
+	case isMapLike(t):
+		v, err := toGeneric(data)
+		if err != nil {
+			return nil, err
+		}
+		if err = writeXMLElement(enc, "root", v); err != nil {
+			return nil, err
+		}
+	case t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		// the elements of a list are siblings, wrap them to keep a single root
+		start := xml.StartElement{Name: xml.Name{Local: "root"}}
+		if err := enc.EncodeToken(start); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(data); err != nil {
+			return nil, err
+		}
+		if err := enc.EncodeToken(start.End()); err != nil {
+			return nil, err
+		}
+	default:
+		if err := enc.Encode(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func indirectType(data interface{}) reflect.Type {
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isMapLike reports whether t is encoded through its generic form, lists of
+// structs keep their own XML mapping
+func isMapLike(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Slice, reflect.Array:
+		e := t.Elem()
+		for e.Kind() == reflect.Ptr {
+			e = e.Elem()
+		}
+		return e.Kind() != reflect.Struct && e.Kind() != reflect.Uint8
+	}
+	return false
+}
+
+func writeXMLElement(enc *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case orderedMap:
+		for _, m := range val {
+			if err := writeXMLElement(enc, m.key, m.value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i := range val {
+			if err := writeXMLElement(enc, "item", val[i]); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarString(val))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName replaces the characters not allowed in an element name
+func xmlName(name string) string {
+	b := []rune(name)
+	for i, r := range b {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'))) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+func scalarString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return ztype.ToString(val)
+	}
+}
+
+// encodeYAML encodes data as a YAML document
+func encodeYAML(data interface{}) ([]byte, error) {
+	v, err := toGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch val := v.(type) {
+	case orderedMap:
+		if len(val) > 0 {
+			writeYAMLMap(&buf, val, 0)
+			return buf.Bytes(), nil
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			writeYAMLList(&buf, val, 0)
+			return buf.Bytes(), nil
+		}
+	}
+	writeYAMLScalar(&buf, v)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func writeYAMLMap(buf *bytes.Buffer, m orderedMap, indent int) {
+	for _, member := range m {
+		buf.WriteString(strings.Repeat(" ", indent))
+		writeYAMLScalar(buf, member.key)
+		buf.WriteByte(':')
+		writeYAMLValue(buf, member.value, indent+2)
+	}
+}
+
+func writeYAMLList(buf *bytes.Buffer, l []interface{}, indent int) {
+	for i := range l {
+		buf.WriteString(strings.Repeat(" ", indent))
+		buf.WriteByte('-')
+		writeYAMLValue(buf, l[i], indent+2)
+	}
+}
+
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	switch val := v.(type) {
+	case orderedMap:
+		if len(val) > 0 {
+			buf.WriteByte('\n')
+			writeYAMLMap(buf, val, indent)
+			return
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			buf.WriteByte('\n')
+			writeYAMLList(buf, val, indent)
+			return
+		}
+	}
+	buf.WriteByte(' ')
+	writeYAMLScalar(buf, v)
+	buf.WriteByte('\n')
+}
+
+func writeYAMLScalar(buf *bytes.Buffer, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case orderedMap:
+		buf.WriteString("{}")
+	case []interface{}:
+		buf.WriteString("[]")
+	case string:
+		if yamlPlain(val) {
+			buf.WriteString(val)
+			return
+		}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(val)
+		buf.Truncate(buf.Len() - 1)
+	default:
+		buf.WriteString(scalarString(val))
+	}
+}
+
+// yamlPlain reports whether s can be written without quotes
+func yamlPlain(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n", "~":
+		return false
+	}
+	for i, r := range s {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == ' ' || r == '-' || r == '.' || r == '/') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// encodeMsgPack encodes data as MessagePack
+func encodeMsgPack(data interface{}) ([]byte, error) {
+	v, err := toGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgPack(make([]byte, 0, 64), v), nil
+}
+
+func appendMsgPack(b []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if val {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return appendMsgPackInt(b, i)
+		}
+		if u, err := strconv.ParseUint(val.String(), 10, 64); err == nil {
+			return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+		}
+		f, _ := val.Float64()
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f))
+	case string:
+		n := len(val)
+		switch {
+		case n < 32:
+			b = append(b, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			b = append(b, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+		}
+		return append(b, val...)
+	case []interface{}:
+		n := len(val)
+		switch {
+		case n < 16:
+			b = append(b, 0x90|byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+		}
+		for i := range val {
+			b = appendMsgPack(b, val[i])
+		}
+		return b
+	case orderedMap:
+		n := len(val)
+		switch {
+		case n < 16:
+			b = append(b, 0x80|byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+		}
+		for _, m := range val {
+			b = appendMsgPack(b, m.key)
+			b = appendMsgPack(b, m.value)
+		}
+		return b
+	}
+	return append(b, 0xc0)
+}
+
+func appendMsgPackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i < 128:
+		return append(b, byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(b, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(i))
+	case i >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+	}
+}
+
+var errCSVData = errors.New("csv data must be a slice of maps or structs")
+
+// encodeCSV encodes the rows with a header line, columns default to the sorted keys of all rows
+func encodeCSV(rows ztype.Maps, columns ...string) ([]byte, error) {
+	if len(columns) == 0 {
+		seen := make(map[string]struct{})
+		for i := range rows {
+			for k := range rows[i] {
+				if _, ok := seen[k]; !ok {
+					seen[k] = struct{}{}
+					columns = append(columns, k)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(columns))
+	for i := range rows {
+		for j, k := range columns {
+			record[j] = ztype.ToString(rows[i][k])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func toCSVRows(data interface{}) (ztype.Maps, error) {
+	if rows, ok := data.(ztype.Maps); ok {
+		return rows, nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if k := v.Kind(); k != reflect.Slice && k != reflect.Array {
+		return nil, errCSVData
+	}
+	return ztype.ToMaps(data), nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/sohaha/zlsgo/znet"
@@ -28,7 +29,8 @@ func New(conf Config) znet.HandlerFunc {
 		} else {
 			c.Next()
 			p := c.PrevContent()
-			if len(p.Content) < conf.MinContentLength {
+			if len(p.Content) < conf.MinContentLength || p.Code.Load() == http.StatusPartialContent ||
+				c.GetResponseHeader("Content-Encoding") != "" {
 				return
 			}
 
@@ -53,6 +55,9 @@ func New(conf Config) znet.HandlerFunc {
 			}
 
 			c.SetHeader("Content-Encoding", "gzip")
+			if etag := c.GetResponseHeader("ETag"); strings.HasPrefix(etag, `"`) {
+				c.SetHeader("ETag", "W/"+etag, true)
+			}
 			c.Byte(p.Code.Load(), be.Bytes())
 		}
 	}
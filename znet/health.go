@@ -0,0 +1,229 @@
+package znet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// HealthCheck a named check run by the health and readiness handlers,
+	// a failing critical check fails the probe, other failures only degrade it
+	HealthCheck struct {
+		Check    func(ctx context.Context) error
+		Name     string
+		Timeout  time.Duration
+		Critical bool
+	}
+
+	// HealthResult the result of a health check
+	HealthResult struct {
+		Error    string `json:"error,omitempty"`
+		Status   string `json:"status"`
+		Duration string `json:"duration"`
+		Critical bool   `json:"critical"`
+	}
+
+	// HealthReport the JSON body of the health and readiness handlers
+	HealthReport struct {
+		Checks   map[string]HealthResult `json:"checks,omitempty"`
+		Status   string                  `json:"status"`
+		InFlight int64                   `json:"in_flight"`
+	}
+
+	health struct {
+		checks   []HealthCheck
+		inFlight zutil.Int64
+		draining zutil.Bool
+		grace    time.Duration
+		delay    time.Duration
+		mu       sync.RWMutex
+	}
+)
+
+const (
+	// HealthUp all checks passed
+	HealthUp = "up"
+	// HealthDegraded a non-critical check failed
+	HealthDegraded = "degraded"
+	// HealthDown a critical check failed
+	HealthDown = "down"
+	// HealthDraining the engine is draining before shutdown
+	HealthDraining = "draining"
+)
+
+var defaultHealthTimeout = 3 * time.Second
+
+// AddHealthCheck registers a check run by HealthHandler and ReadyHandler,
+// checks are critical with a 3 second timeout by default
+func (e *Engine) AddHealthCheck(name string, check func(ctx context.Context) error, opt ...func(hc *HealthCheck)) {
+	hc := zutil.Optional(HealthCheck{
+		Name:     name,
+		Check:    check,
+		Timeout:  defaultHealthTimeout,
+		Critical: true,
+	}, opt...)
+	e.health.mu.Lock()
+	e.health.checks = append(e.health.checks, hc)
+	e.health.mu.Unlock()
+}
+
+// SetDrain sets the grace period shutdown waits for in-flight requests, delay keeps
+// the readiness failing for a while before that so load balancers stop routing first
+func (e *Engine) SetDrain(grace time.Duration, delay ...time.Duration) {
+	e.health.mu.Lock()
+	e.health.grace = grace
+	if len(delay) > 0 {
+		e.health.delay = delay[0]
+	}
+	e.health.mu.Unlock()
+}
+
+// InFlight returns the number of requests being handled, health probes and
+// upgraded connections are not counted
+func (e *Engine) InFlight() int64 {
+	return e.health.inFlight.Load()
+}
+
+// Draining reports whether the engine is draining before shutdown
+func (e *Engine) Draining() bool {
+	return e.health.draining.Load()
+}
+
+// Drain fails the readiness, waits the drain delay and then until the in-flight
+// requests finish or the grace period passes, it reports whether they all finished
+func (e *Engine) Drain() bool {
+	e.health.draining.Store(true)
+	e.health.mu.RLock()
+	grace, delay := e.health.grace, e.health.delay
+	e.health.mu.RUnlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	deadline := time.Now().Add(grace)
+	for e.health.inFlight.Load() > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// Health runs all health checks concurrently
+func (e *Engine) Health(ctx context.Context) *HealthReport {
+	e.health.mu.RLock()
+	checks := append([]HealthCheck{}, e.health.checks...)
+	e.health.mu.RUnlock()
+
+	report := &HealthReport{Status: HealthUp, InFlight: e.health.inFlight.Load()}
+	if len(checks) == 0 {
+		return report
+	}
+
+	results := make([]HealthResult, len(checks))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	report.Checks = make(map[string]HealthResult, len(checks))
+	for i := range checks {
+		report.Checks[checks[i].Name] = results[i]
+		if results[i].Status == HealthUp {
+			continue
+		}
+		if checks[i].Critical {
+			report.Status = HealthDown
+		} else if report.Status == HealthUp {
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+// HealthHandler responds the health checks as JSON, 503 when a critical check fails
+func (e *Engine) HealthHandler() HandlerFunc {
+	return func(c *Context) {
+		c.untrack()
+		e.respondHealth(c, e.Health(c.Request.Context()))
+	}
+}
+
+// ReadyHandler is HealthHandler that also fails while the engine is draining
+func (e *Engine) ReadyHandler() HandlerFunc {
+	return func(c *Context) {
+		c.untrack()
+		if e.Draining() {
+			e.respondHealth(c, &HealthReport{Status: HealthDraining, InFlight: e.health.inFlight.Load()})
+			return
+		}
+		e.respondHealth(c, e.Health(c.Request.Context()))
+	}
+}
+
+// untrack stops counting the request of c as in flight
+func (c *Context) untrack() {
+	if c.inFlight {
+		c.inFlight = false
+		c.Engine.health.inFlight.Sub(1)
+	}
+}
+
+func (e *Engine) respondHealth(c *Context, report *HealthReport) {
+	code := int32(http.StatusOK)
+	if report.Status == HealthDown || report.Status == HealthDraining {
+		code = http.StatusServiceUnavailable
+	}
+	c.SetHeader("Cache-Control", "no-store")
+	c.JSON(code, report)
+}
+
+func runHealthCheck(ctx context.Context, hc HealthCheck) (result HealthResult) {
+	result.Critical = hc.Critical
+	if hc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- hc.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result.Duration = time.Since(start).String()
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New("timeout")
+	}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+	} else {
+		result.Status = HealthUp
+	}
+	return
+}
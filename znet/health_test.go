@@ -0,0 +1,148 @@
+package znet
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+)
+
+func TestHealth(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := New("health-test")
+	r.SetMode(QuietMode)
+	r.GET("/healthz", r.HealthHandler())
+	r.GET("/readyz", r.ReadyHandler())
+
+	client := r.Test(tt)
+	client.GET("/readyz").Do().ExpectStatus(200).ExpectJSON("status", HealthUp).ExpectJSON("in_flight", 0)
+
+	var cacheErr error
+	r.AddHealthCheck("db", func(ctx context.Context) error {
+		return nil
+	})
+	r.AddHealthCheck("cache", func(ctx context.Context) error {
+		return cacheErr
+	}, func(hc *HealthCheck) {
+		hc.Critical = false
+	})
+	r.AddHealthCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, func(hc *HealthCheck) {
+		hc.Timeout = 10 * time.Millisecond
+		hc.Critical = false
+	})
+
+	client.GET("/healthz").Do().
+		ExpectStatus(200).
+		ExpectHeader("Cache-Control", "no-store").
+		ExpectJSON("status", HealthDegraded).
+		ExpectJSON("checks.db.status", HealthUp).
+		ExpectJSON("checks.db.critical", true).
+		ExpectJSON("checks.slow.error", "timeout")
+
+	cacheErr = errors.New("refused")
+	r.AddHealthCheck("panic", func(ctx context.Context) error {
+		panic("boom")
+	})
+	client.GET("/healthz").Do().
+		ExpectStatus(503).
+		ExpectJSON("status", HealthDown).
+		ExpectJSON("checks.cache.error", "refused").
+		ExpectJSON("checks.panic.error", "panic: boom")
+}
+
+func TestDrain(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := New("drain-test")
+	r.SetMode(QuietMode)
+	r.SetDrain(time.Second, 20*time.Millisecond)
+	r.GET("/readyz", r.ReadyHandler())
+	r.Group("/api", func(g *Engine) {
+		g.GET("/readyz", g.ReadyHandler())
+		g.AddHealthCheck("api", func(ctx context.Context) error {
+			return nil
+		})
+	})
+	tt.Equal(HealthUp, r.Health(context.Background()).Checks["api"].Status)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r.GET("/slow", func(c *Context) {
+		close(started)
+		<-release
+		c.String(200, "done")
+	})
+
+	var wg sync.WaitGroup
+	w := httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-started
+	tt.Equal(int64(1), r.InFlight())
+
+	drained := make(chan bool, 1)
+	go func() {
+		drained <- r.Drain()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	tt.EqualTrue(r.Draining())
+	client := r.Test(tt)
+	client.GET("/readyz").Do().ExpectStatus(503).ExpectJSON("status", HealthDraining).ExpectJSON("in_flight", 1)
+	client.GET("/api/readyz").Do().ExpectStatus(503).ExpectJSON("status", HealthDraining)
+
+	select {
+	case <-drained:
+		t.Fatal("drain returned with a request in flight")
+	default:
+	}
+	close(release)
+	tt.EqualTrue(<-drained)
+	wg.Wait()
+	tt.Equal("done", w.Body.String())
+	tt.Equal(int64(0), r.InFlight())
+
+	r.SetDrain(10 * time.Millisecond)
+	r.health.inFlight.Add(1)
+	tt.EqualTrue(!r.Drain())
+	r.health.inFlight.Sub(1)
+}
+
+func TestDrainWebSocket(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := New("drain-websocket-test")
+	r.SetMode(QuietMode)
+	hub := NewWebSocketHub(r)
+	joined := make(chan struct{}, 1)
+	hub.option.OnConnect = func(*WebSocketClient) {
+		joined <- struct{}{}
+	}
+	r.GET("/ws", func(c *Context) error {
+		return hub.Handle(c, nil)
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	ws := dialWebSocket(t, strings.TrimPrefix(srv.URL, "http://"), "/ws", nil)
+	defer ws.conn.Close()
+	<-joined
+
+	tt.Equal(int64(0), r.InFlight())
+	r.SetDrain(3 * time.Second)
+	start := time.Now()
+	tt.EqualTrue(r.Drain())
+	tt.EqualTrue(time.Since(start) < time.Second)
+	hub.Close()
+}
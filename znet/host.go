@@ -0,0 +1,170 @@
+package znet
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type (
+	// vhost is a route tree bound to a host pattern
+	vhost struct {
+		router  *router
+		regex   *regexp.Regexp
+		pattern string
+		names   []string
+	}
+	// vhosts holds the virtual hosts of an engine, shared by its groups
+	vhosts struct {
+		static   map[string]*vhost
+		patterns []*vhost
+		mu       sync.RWMutex
+	}
+)
+
+// Host creates an engine whose routes only match requests for the given host,
+// the pattern may contain named labels such as {tenant}.example.com which are
+// available through GetParam, and typed labels like {id:int} are supported.
+// Requests for a registered host never fall back to the default routes.
+func (e *Engine) Host(pattern string, groupHandle ...func(e *Engine)) (engine *Engine) {
+	h, err := e.router.hosts.add(pattern)
+	if err != nil {
+		e.Log.Fatal(err)
+		return
+	}
+
+	middleware := make([]handlerFn, len(e.router.middleware))
+	copy(middleware, e.router.middleware)
+	engine = e.derive(&router{
+		prefix:     e.router.prefix,
+		trees:      h.router.trees,
+		hosts:      e.router.hosts,
+		middleware: middleware,
+		notFound:   e.router.notFound,
+	})
+	if len(groupHandle) > 0 {
+		groupHandle[0](engine)
+	}
+	return
+}
+
+// add registers a host pattern, returning the existing one if already added
+func (v *vhosts) add(pattern string) (*vhost, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return nil, ErrPatternGrammar
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if h, ok := v.static[pattern]; ok {
+		return h, nil
+	}
+	for _, h := range v.patterns {
+		if h.pattern == pattern {
+			return h, nil
+		}
+	}
+
+	h := &vhost{
+		pattern: pattern,
+		router:  &router{prefix: "/", trees: make(map[string]*Tree)},
+	}
+	if !strings.ContainsAny(pattern, "{*") {
+		v.static[pattern] = h
+		return h, nil
+	}
+
+	expr, names, err := parseHostPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	h.regex, h.names = regexp.MustCompile(expr), names
+	v.patterns = append(v.patterns, h)
+	return h, nil
+}
+
+// match finds the virtual host for a hostname, exact hosts take precedence over patterns
+func (v *vhosts) match(hostname string) (*vhost, map[string]string) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if len(v.static) == 0 && len(v.patterns) == 0 {
+		return nil, nil
+	}
+
+	if h, ok := v.static[hostname]; ok {
+		return h, nil
+	}
+	for _, h := range v.patterns {
+		rr := h.regex.FindStringSubmatch(hostname)
+		if len(rr) == 0 {
+			continue
+		}
+		params := make(map[string]string, len(h.names))
+		for i, name := range h.names {
+			if name != "" {
+				params[name] = rr[i+1]
+			}
+		}
+		if !checkParamTypes(h.pattern, params) {
+			continue
+		}
+		return h, params
+	}
+	return nil, nil
+}
+
+// parseHostPattern converts a host pattern into an anchored regular expression,
+// a label is either literal, * or a {name} / {name:type} placeholder
+func parseHostPattern(pattern string) (string, []string, error) {
+	var names []string
+	labels := strings.Split(pattern, ".")
+	for i, label := range labels {
+		switch {
+		case label == "*":
+			labels[i] = "([^.]+)"
+			names = append(names, "")
+		case strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}"):
+			name, expr := parseBracePlaceholder(label[1 : len(label)-1])
+			if name == allKey {
+				expr = `[^.]+`
+			} else if expr == defaultPattern {
+				expr = `[^.]+`
+			}
+			if _, err := regexp.Compile(expr); err != nil {
+				return "", nil, ErrPatternGrammar
+			}
+			labels[i] = "(" + expr + ")"
+			names = append(names, name)
+		case strings.ContainsAny(label, "{}*"):
+			return "", nil, ErrPatternGrammar
+		default:
+			labels[i] = regexp.QuoteMeta(label)
+		}
+	}
+	return "^" + strings.Join(labels, `\.`) + "$", names, nil
+}
+
+// hostname returns the lower cased request host without its port
+func hostname(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// hostTrees picks the route trees for the request host and exposes the host params
+func (e *Engine) hostTrees(c *Context, req *http.Request) map[string]*Tree {
+	h, params := e.router.hosts.match(hostname(req))
+	if h == nil {
+		return e.router.trees
+	}
+	if len(params) > 0 {
+		c.Request = req.WithContext(context.WithValue(req.Context(), Utils.ContextKey, params))
+	}
+	return h.router.trees
+}
@@ -0,0 +1,67 @@
+package znet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+)
+
+func TestHost(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("host-test")
+	r.SetMode(QuietMode)
+
+	r.GET("/", func(c *Context) {
+		c.String(200, "default")
+	})
+	r.Host("api.example.com", func(e *Engine) {
+		e.GET("/", func(c *Context) {
+			c.String(200, "api")
+		})
+		e.Group("/v1", func(e *Engine) {
+			e.GET("/users/{id:int}", func(c *Context) {
+				c.String(200, "api user "+c.GetParam("id"))
+			})
+		})
+	})
+	tenant := r.Host("{tenant}.example.com")
+	tenant.GET("/", func(c *Context) {
+		c.String(200, "tenant "+c.GetParam("tenant"))
+	})
+	tenant.GET("/posts/:id", func(c *Context) {
+		c.String(200, c.GetParam("tenant")+" post "+c.GetParam("id"))
+	})
+	r.Host("{shard:int}.db.local").GET("/", func(c *Context) {
+		c.String(200, "shard "+c.GetParam("shard"))
+	})
+
+	for _, v := range []struct {
+		host, path, expected string
+		code                 int
+	}{
+		{"localhost", "/", "default", 200},
+		{"api.example.com:8080", "/", "api", 200},
+		{"API.example.com", "/v1/users/7", "api user 7", 200},
+		{"acme.example.com", "/", "tenant acme", 200},
+		{"acme.example.com", "/posts/3", "acme post 3", 200},
+		{"acme.example.com", "/v1/users/7", "", 404},
+		{"a.b.example.com", "/", "default", 200},
+		{"12.db.local", "/", "shard 12", 200},
+		{"x.db.local", "/", "default", 200},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", v.path, nil)
+		req.Host = v.host
+		r.ServeHTTP(w, req)
+		tt.Equal(v.code, w.Code)
+		if v.code == 200 {
+			tt.Equal(v.expected, w.Body.String())
+		}
+	}
+
+	tt.EqualTrue(r.Host("api.example.com") != nil)
+	_, err := r.router.hosts.add("bad{.example.com")
+	tt.Equal(ErrPatternGrammar, err)
+}
@@ -65,6 +65,11 @@ func (c *Context) GetAllParam() map[string]string {
 	return nil
 }
 
+// RoutePath returns the pattern of the matched route, empty when no route matched
+func (c *Context) RoutePath() string {
+	return c.route
+}
+
 // GetAllQuery Get All Queryst
 func (c *Context) GetAllQuery() url.Values {
 	c.initQuery()
@@ -366,21 +371,30 @@ func (c *Context) ParseMultipartForm(maxMultipartMemory ...int64) error {
 // multipartReader returns a multipart reader for the current request.
 // If allowMixed is true, it will handle multipart/mixed content types.
 func (c *Context) multipartReader(allowMixed bool) (*multipart.Reader, error) {
+	boundary, err := c.multipartBoundary(allowMixed)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.GetDataRaw()
+	if err != nil {
+		return nil, err
+	}
+	return multipart.NewReader(strings.NewReader(body), boundary), nil
+}
+
+// multipartBoundary returns the boundary of a multipart request.
+func (c *Context) multipartBoundary(allowMixed bool) (string, error) {
 	v := c.Request.Header.Get("Content-Type")
 	if v == "" {
-		return nil, http.ErrNotMultipart
+		return "", http.ErrNotMultipart
 	}
 	d, params, err := mime.ParseMediaType(v)
 	if err != nil || !(d == "multipart/form-data" || allowMixed && d == "multipart/mixed") {
-		return nil, http.ErrNotMultipart
+		return "", http.ErrNotMultipart
 	}
 	boundary, ok := params["boundary"]
 	if !ok {
-		return nil, http.ErrMissingBoundary
-	}
-	body, err := c.GetDataRaw()
-	if err != nil {
-		return nil, err
+		return "", http.ErrMissingBoundary
 	}
-	return multipart.NewReader(strings.NewReader(body), boundary), nil
+	return boundary, nil
 }
@@ -20,7 +20,8 @@ var (
 	RemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP", "Cf-Connecting-Ip"}
 
 	// TrustedProxies defines the IP ranges that are considered trusted proxies.
-	// By default, all IPs are trusted (0.0.0.0/0).
+	// By default, all IPs are trusted (0.0.0.0/0). It is the fallback of engines
+	// without Engine.SetTrustedProxies, which takes precedence when it is set.
 	TrustedProxies = []string{"0.0.0.0/0"}
 
 	// LocalNetworks defines the IP ranges that are considered local/private networks.
@@ -306,6 +307,109 @@ func GetIPv(s string) int {
 	return 6
 }
 
+// ParseNetworks parses CIDRs and plain IP addresses, an IP address is a network of itself
+func ParseNetworks(list ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, v := range list {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.Contains(v, "/") {
+			_, n, err := net.ParseCIDR(v)
+			if err != nil {
+				return nil, err
+			}
+			networks = append(networks, n)
+			continue
+		}
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, errors.New("invalid ip address: " + v)
+		}
+		bits := net.IPv6len * 8
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, net.IPv4len*8
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return networks, nil
+}
+
+// InNetworks reports whether ip belongs to one of the networks
+func InNetworks(ip net.IP, networks []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for i := range networks {
+		if networks[i].Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTrustedProxies sets the proxies whose RemoteIPHeaders are trusted by GetClientIP,
+// the client is the first address not belonging to them walking back from the peer,
+// the headers are ignored when the peer is not a trusted proxy, CIDRs and plain IP
+// addresses are accepted, e.g. append(realip.GetCloudflare(), "10.0.0.0/8").
+// Once set, the package-level TrustedProxies is ignored by the engine and its groups.
+func (e *Engine) SetTrustedProxies(proxies ...string) error {
+	networks, err := ParseNetworks(proxies...)
+	if err != nil {
+		return err
+	}
+	e.trustedProxies.Store(networks)
+	return nil
+}
+
+// HasTrustedProxies reports whether SetTrustedProxies has been called on the engine
+func (e *Engine) HasTrustedProxies() bool {
+	_, ok := e.explicitProxies()
+	return ok
+}
+
+// IsTrustedProxy reports whether ip is a trusted proxy of the engine, the package-level
+// TrustedProxies are used when SetTrustedProxies has not been called
+func (e *Engine) IsTrustedProxy(ip string) bool {
+	netIP := net.ParseIP(ip)
+	if networks, ok := e.explicitProxies(); ok {
+		return InNetworks(netIP, networks)
+	}
+	return netIP != nil && isProxyTrusted(netIP)
+}
+
+func (e *Engine) explicitProxies() ([]*net.IPNet, bool) {
+	if e == nil || e.trustedProxies == nil {
+		return nil, false
+	}
+	proxies, ok := e.trustedProxies.Load().([]*net.IPNet)
+	return proxies, ok
+}
+
+func (c *Context) trustedProxies() ([]*net.IPNet, bool) {
+	return c.Engine.explicitProxies()
+}
+
+// trustedClientIP resolves the client IP walking the headers back from the peer
+// while the addresses are trusted proxies
+func trustedClientIP(r *http.Request, proxies []*net.IPNet) string {
+	remoteIP := RemoteIP(r)
+	if !InNetworks(net.ParseIP(remoteIP), proxies) {
+		return remoteIP
+	}
+
+	for _, key := range RemoteIPHeaders {
+		ips := parseHeadersIP(strings.Join(r.Header.Values(key), ","))
+		for j := len(ips) - 1; j >= 0; j-- {
+			if j == 0 || !InNetworks(ips[j], proxies) {
+				return ips[j].String()
+			}
+		}
+	}
+	return remoteIP
+}
+
 // netCIDR parses a CIDR notation string into an IPNet.
 // It's an internal helper used for IP network operations.
 func netCIDR(network string) (*net.IPNet, error) {
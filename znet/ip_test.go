@@ -256,3 +256,38 @@ func TestGetClientIP(tt *testing.T) {
 		t.Equal("1.2.3.4", w.Body.String(), true)
 	})
 }
+
+func TestTrustedProxies(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := New("trusted-proxies-test")
+	r.SetMode(QuietMode)
+	r.GET("/", func(c *Context) {
+		c.String(200, c.GetClientIP())
+	})
+	client := r.Test(tt)
+
+	// TestMain sets the package-level TrustedProxies to the Cloudflare ranges
+	tt.EqualTrue(r.IsTrustedProxy("173.245.48.1"))
+	tt.EqualTrue(!r.IsTrustedProxy("1.1.1.1"))
+
+	tt.NoError(r.SetTrustedProxies("10.0.0.0/8"), true)
+	tt.EqualTrue(!r.IsTrustedProxy("173.245.48.1"))
+	client.GET("/").Header("X-Forwarded-For", "1.1.1.1").Do().ExpectBody("192.0.2.1")
+
+	tt.NoError(r.SetTrustedProxies("192.0.2.1", "10.0.0.0/8"), true)
+	tt.EqualTrue(r.IsTrustedProxy("10.2.3.4"))
+	tt.EqualTrue(!r.IsTrustedProxy("1.1.1.1"))
+	client.GET("/").Header("X-Forwarded-For", "9.9.9.9, 1.1.1.1, 10.0.0.2").Do().ExpectBody("1.1.1.1")
+	client.GET("/").Header("X-Forwarded-For", "10.0.0.3, 10.0.0.2").Do().ExpectBody("10.0.0.3")
+	client.GET("/").Header("X-Real-IP", "172.16.0.1").Do().ExpectBody("172.16.0.1")
+	client.GET("/").Do().ExpectBody("192.0.2.1")
+
+	tt.EqualTrue(r.SetTrustedProxies("10.0.0.0/33") != nil)
+	tt.EqualTrue(r.SetTrustedProxies("nope") != nil)
+
+	networks, err := ParseNetworks("::1", " 2001:db8::/32 ", "")
+	tt.NoError(err, true)
+	tt.Equal(2, len(networks))
+	tt.EqualTrue(InNetworks(net.ParseIP("2001:db8::1"), networks))
+}
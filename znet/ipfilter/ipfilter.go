@@ -0,0 +1,218 @@
+// Package ipfilter provides a middleware allowing or denying clients by IP address,
+// the lists can be loaded from a file that is reloaded when it changes
+package ipfilter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// Config configuration
+	Config struct {
+		// Handler responds the rejected requests, default is 403
+		Handler func(c *znet.Context)
+		// ClientIP returns the IP of the request, default is c.GetClientIP when the
+		// engine has SetTrustedProxies, otherwise the peer address as headers can be forged
+		ClientIP func(c *znet.Context) string
+		// File holds the lists, one "allow CIDR" or "deny CIDR" per line,
+		// lines starting with # are comments
+		File string
+		// Allow lists the allowed CIDRs or IPs, when not empty others are rejected
+		Allow []string
+		// Deny lists the rejected CIDRs or IPs, it takes precedence over Allow
+		Deny []string
+		// ReloadInterval is how often File is checked for changes by NewFilter, zero disables it
+		ReloadInterval time.Duration
+	}
+	// Filter holds the allow and deny lists of the middleware
+	Filter struct {
+		modTime time.Time
+		stop    chan struct{}
+		conf    Config
+		allow   []*net.IPNet
+		deny    []*net.IPNet
+		mu      sync.RWMutex
+		once    sync.Once
+	}
+)
+
+// ErrForbidden is the response of the rejected requests
+var ErrForbidden = errors.New("forbidden")
+
+// New returns an IP filter middleware, it panics on an invalid list, File is
+// only read once as there is no way to stop watching it, use NewFilter to reload it
+func New(opt ...func(conf *Config)) znet.HandlerFunc {
+	f, err := NewFilter(append(opt, func(conf *Config) {
+		conf.ReloadInterval = 0
+	})...)
+	if err != nil {
+		panic(fmt.Sprintf("invalid ipfilter config: %v", err))
+	}
+	return f.Handler()
+}
+
+// NewFilter creates an IP filter, the lists of File are added to Allow and Deny
+// and reloaded every ReloadInterval when the file changed, use Close to stop watching it
+func NewFilter(opt ...func(conf *Config)) (*Filter, error) {
+	f := &Filter{
+		conf: zutil.Optional(Config{
+			ReloadInterval: 10 * time.Second,
+			Handler: func(c *znet.Context) {
+				c.String(http.StatusForbidden, ErrForbidden.Error())
+			},
+			ClientIP: func(c *znet.Context) string {
+				if c.Engine.HasTrustedProxies() {
+					return c.GetClientIP()
+				}
+				return znet.RemoteIP(c.Request)
+			},
+		}, opt...),
+		stop: make(chan struct{}),
+	}
+
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	if f.conf.File != "" && f.conf.ReloadInterval > 0 {
+		go f.watch()
+	}
+	return f, nil
+}
+
+// Handler returns the IP filter middleware
+func (f *Filter) Handler() znet.HandlerFunc {
+	return func(c *znet.Context) {
+		if f.Allowed(f.conf.ClientIP(c)) {
+			c.Next()
+			return
+		}
+		defer c.Abort()
+		f.conf.Handler(c)
+	}
+}
+
+// Allowed reports whether ip passes the lists, an invalid ip only passes empty allow lists
+func (f *Filter) Allowed(ip string) bool {
+	netIP := net.ParseIP(strings.TrimSpace(ip))
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if znet.InNetworks(netIP, f.deny) {
+		return false
+	}
+	return len(f.allow) == 0 || znet.InNetworks(netIP, f.allow)
+}
+
+// Set replaces the lists of the config, the lists of File are kept
+func (f *Filter) Set(allow, deny []string) error {
+	f.mu.Lock()
+	f.conf.Allow, f.conf.Deny = allow, deny
+	f.mu.Unlock()
+	return f.Reload()
+}
+
+// Reload parses the lists again, the previous lists are kept on error
+func (f *Filter) Reload() error {
+	f.mu.RLock()
+	allowList, denyList, file := f.conf.Allow, f.conf.Deny, f.conf.File
+	f.mu.RUnlock()
+
+	var modTime time.Time
+	if file != "" {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTime = stat.ModTime()
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fileAllow, fileDeny, err := parseFile(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		allowList = append(append([]string{}, allowList...), fileAllow...)
+		denyList = append(append([]string{}, denyList...), fileDeny...)
+	}
+
+	allow, err := znet.ParseNetworks(allowList...)
+	if err != nil {
+		return err
+	}
+	deny, err := znet.ParseNetworks(denyList...)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.allow, f.deny, f.modTime = allow, deny, modTime
+	f.mu.Unlock()
+	return nil
+}
+
+// Close stops watching the file
+func (f *Filter) Close() {
+	f.once.Do(func() {
+		close(f.stop)
+	})
+}
+
+func (f *Filter) watch() {
+	ticker := time.NewTicker(f.conf.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			stat, err := os.Stat(f.conf.File)
+			if err != nil {
+				continue
+			}
+			f.mu.RLock()
+			changed := !stat.ModTime().Equal(f.modTime)
+			f.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err = f.Reload(); err != nil {
+				znet.Log.Warnf("ipfilter reload %s: %v", f.conf.File, err)
+			}
+		}
+	}
+}
+
+func parseFile(data []byte) (allow, deny []string, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("line %d: invalid rule %q", n, line)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return nil, nil, fmt.Errorf("line %d: invalid rule %q", n, line)
+		}
+	}
+	return allow, deny, scanner.Err()
+}
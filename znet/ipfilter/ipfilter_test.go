@@ -0,0 +1,107 @@
+package ipfilter_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/ipfilter"
+	"github.com/sohaha/zlsgo/znet/realip"
+)
+
+func TestIPFilter(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	file := filepath.Join(t.TempDir(), "ips.conf")
+	tt.NoError(os.WriteFile(file, []byte("# office\nallow 10.0.0.0/8\ndeny 10.0.0.1\n"), 0o644), true)
+
+	f, err := ipfilter.NewFilter(func(conf *ipfilter.Config) {
+		conf.File = file
+		conf.Allow = []string{"192.0.2.1"}
+		conf.ReloadInterval = 10 * time.Millisecond
+	})
+	tt.NoError(err, true)
+	defer f.Close()
+
+	tt.EqualTrue(f.Allowed("10.1.2.3"))
+	tt.EqualTrue(f.Allowed("192.0.2.1"))
+	tt.EqualTrue(!f.Allowed("10.0.0.1"))
+	tt.EqualTrue(!f.Allowed("8.8.8.8"))
+	tt.EqualTrue(!f.Allowed("invalid"))
+
+	r := znet.New("ipfilter-test")
+	r.SetMode(znet.QuietMode)
+	admin := r.Group("/admin", func(e *znet.Engine) {
+		e.Use(f.Handler())
+	})
+	admin.GET("/", func(c *znet.Context) {
+		c.String(200, c.GetClientIP())
+	})
+	r.GET("/public", func(c *znet.Context) {
+		c.String(200, "ok")
+	})
+
+	client := r.Test(tt)
+	client.GET("/admin/").Do().ExpectStatus(200).ExpectBody("192.0.2.1")
+	client.GET("/public").Do().ExpectStatus(200)
+
+	tt.NoError(os.WriteFile(file, []byte("deny 192.0.2.0/24\n"), 0o644), true)
+	future := time.Now().Add(time.Second)
+	tt.NoError(os.Chtimes(file, future, future), true)
+	for deadline := time.Now().Add(3 * time.Second); f.Allowed("192.0.2.1") && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	client.GET("/admin/").Do().ExpectStatus(403).ExpectBody(ipfilter.ErrForbidden.Error())
+	client.GET("/public").Do().ExpectStatus(200)
+
+	tt.NoError(os.WriteFile(file, []byte("deny nope\n"), 0o644), true)
+	tt.EqualTrue(f.Reload() != nil)
+	tt.EqualTrue(!f.Allowed("192.0.2.1"))
+
+	tt.NoError(os.WriteFile(file, []byte(""), 0o644), true)
+	tt.NoError(f.Set(nil, []string{"8.8.8.8"}), true)
+	tt.EqualTrue(f.Allowed("192.0.2.1"))
+	tt.EqualTrue(!f.Allowed("8.8.8.8"))
+	tt.EqualTrue(f.Set([]string{"bad"}, nil) != nil)
+
+	_, err = ipfilter.NewFilter(func(conf *ipfilter.Config) {
+		conf.Deny = []string{"300.0.0.1"}
+	})
+	tt.EqualTrue(err != nil)
+
+	tt.NoError(r.SetTrustedProxies(append(realip.GetCloudflare(0), "192.0.2.0/24")...), true)
+	tt.NoError(f.Set([]string{"5.5.5.0/24"}, nil), true)
+	client.GET("/admin/").Header("X-Forwarded-For", "5.5.5.5, 173.245.48.1").Do().ExpectStatus(200).ExpectBody("5.5.5.5")
+	client.GET("/admin/").Header("X-Forwarded-For", "5.5.5.5, 6.6.6.6").Do().ExpectStatus(403)
+}
+
+func TestNew(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	file := filepath.Join(t.TempDir(), "ips.conf")
+	tt.NoError(os.WriteFile(file, []byte("deny 192.0.2.1\n"), 0o644), true)
+
+	r := znet.New("ipfilter-new-test")
+	r.SetMode(znet.QuietMode)
+	r.GET("/", func(c *znet.Context) {
+		c.String(200, "ok")
+	}, ipfilter.New(func(conf *ipfilter.Config) {
+		conf.File = file
+		conf.Handler = func(c *znet.Context) {
+			c.String(401, "denied")
+		}
+	}))
+	r.Test(tt).GET("/").Do().ExpectStatus(401).ExpectBody("denied")
+	r.Test(tt).GET("/").Header("X-Forwarded-For", "8.8.8.8").Header("X-Real-IP", "8.8.8.8").Do().
+		ExpectStatus(401)
+
+	defer func() {
+		tt.EqualTrue(recover() != nil)
+	}()
+	ipfilter.New(func(conf *ipfilter.Config) {
+		conf.Allow = []string{"bad"}
+	})
+}
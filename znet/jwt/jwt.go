@@ -0,0 +1,244 @@
+// Package jwt signs and verifies JSON Web Tokens and provides a bearer token middleware for znet
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+type (
+	// Claims are the registered claims, embed it in a struct to add custom claims
+	Claims struct {
+		Issuer    string   `json:"iss,omitempty"`
+		Subject   string   `json:"sub,omitempty"`
+		ID        string   `json:"jti,omitempty"`
+		Audience  Audience `json:"aud,omitempty"`
+		ExpiresAt int64    `json:"exp,omitempty"`
+		NotBefore int64    `json:"nbf,omitempty"`
+		IssuedAt  int64    `json:"iat,omitempty"`
+	}
+	// Audience is a single string or an array of strings
+	Audience []string
+	// Validation controls the checks of the registered claims
+	Validation struct {
+		// Now returns the current time, default is time.Now
+		Now func() time.Time
+		// Issuer must equal the iss claim when set
+		Issuer string
+		// Audience must be contained in the aud claim when set
+		Audience string
+		// Leeway is the allowed clock skew for exp, nbf and iat
+		Leeway time.Duration
+		// RequireExpiration rejects tokens without the exp claim
+		RequireExpiration bool
+	}
+	header struct {
+		Algorithm Algorithm `json:"alg"`
+		Type      string    `json:"typ,omitempty"`
+		KeyID     string    `json:"kid,omitempty"`
+	}
+	numericClaims struct {
+		Issuer    string   `json:"iss"`
+		Audience  Audience `json:"aud"`
+		ExpiresAt *float64 `json:"exp"`
+		NotBefore *float64 `json:"nbf"`
+		IssuedAt  *float64 `json:"iat"`
+	}
+)
+
+var (
+	// ErrAlgorithm the algorithm is not supported or does not match the key
+	ErrAlgorithm = errors.New("jwt: unsupported algorithm")
+	// ErrMalformed the token is not a valid compact JWS
+	ErrMalformed = errors.New("jwt: malformed token")
+	// ErrSignature the signature does not verify
+	ErrSignature = errors.New("jwt: invalid signature")
+	// ErrExpired the token is expired
+	ErrExpired = errors.New("jwt: token is expired")
+	// ErrNotValidYet the token is used before nbf or iat
+	ErrNotValidYet = errors.New("jwt: token is not valid yet")
+	// ErrIssuer the iss claim does not match
+	ErrIssuer = errors.New("jwt: invalid issuer")
+	// ErrAudience the aud claim does not match
+	ErrAudience = errors.New("jwt: invalid audience")
+)
+
+var encoding = base64.RawURLEncoding
+
+// MarshalJSON encodes a single audience as a string
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts a string or an array of strings
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*a = Audience{s}
+		return nil
+	}
+	var v []string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Contains reports whether the audience includes aud
+func (a Audience) Contains(aud string) bool {
+	for i := range a {
+		if a[i] == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign signs the claims with the current key of the set
+func (s *KeySet) Sign(claims interface{}) (string, error) {
+	key, ok := s.Current()
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	return Sign(key, claims)
+}
+
+// Sign signs the claims with the key, the kid header is the key ID
+func Sign(key *Key, claims interface{}) (string, error) {
+	h, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encoding.EncodeToString(h) + "." + encoding.EncodeToString(payload)
+	sig, err := key.sign([]byte(unsigned))
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + encoding.EncodeToString(sig), nil
+}
+
+// Parse verifies the token with the key selected by its kid, or the current key when it has none,
+// validates the registered claims and decodes the payload into claims
+func (s *KeySet) Parse(token string, claims interface{}, opt ...func(v *Validation)) error {
+	h, err := parseHeader(token)
+	if err != nil {
+		return err
+	}
+	kid := h.KeyID
+	if kid == "" {
+		kid = s.currentID()
+	}
+	key, ok := s.Get(kid)
+	if !ok {
+		return ErrKeyNotFound
+	}
+	return parse(key, h, token, claims, opt...)
+}
+
+// Parse verifies the token with the key, validates the registered claims and decodes the payload into claims
+func Parse(key *Key, token string, claims interface{}, opt ...func(v *Validation)) error {
+	h, err := parseHeader(token)
+	if err != nil {
+		return err
+	}
+	return parse(key, h, token, claims, opt...)
+}
+
+func parseHeader(token string) (*header, error) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 || strings.Count(token, ".") != 2 {
+		return nil, ErrMalformed
+	}
+	b, err := encoding.DecodeString(token[:i])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	h := &header{}
+	if err = json.Unmarshal(b, h); err != nil {
+		return nil, ErrMalformed
+	}
+	return h, nil
+}
+
+func parse(key *Key, h *header, token string, claims interface{}, opt ...func(v *Validation)) error {
+	if h.Algorithm != key.Algorithm {
+		return ErrAlgorithm
+	}
+
+	i := strings.LastIndexByte(token, '.')
+	sig, err := encoding.DecodeString(token[i+1:])
+	if err != nil {
+		return ErrMalformed
+	}
+	if !key.verify([]byte(token[:i]), sig) {
+		return ErrSignature
+	}
+
+	payload, err := encoding.DecodeString(token[strings.IndexByte(token, '.')+1 : i])
+	if err != nil {
+		return ErrMalformed
+	}
+	var registered numericClaims
+	if err = json.Unmarshal(payload, &registered); err != nil {
+		return ErrMalformed
+	}
+
+	v := Validation{Now: time.Now}
+	for _, o := range opt {
+		o(&v)
+	}
+	if err = registered.validate(v); err != nil {
+		return err
+	}
+
+	if claims == nil {
+		return nil
+	}
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+func (c *numericClaims) validate(v Validation) error {
+	now := v.Now()
+	leeway := v.Leeway.Seconds()
+	unix := float64(now.UnixNano()) / 1e9
+
+	if c.ExpiresAt != nil {
+		if unix >= *c.ExpiresAt+leeway {
+			return ErrExpired
+		}
+	} else if v.RequireExpiration {
+		return ErrExpired
+	}
+	if c.NotBefore != nil && unix < *c.NotBefore-leeway {
+		return ErrNotValidYet
+	}
+	if c.IssuedAt != nil && unix < *c.IssuedAt-leeway {
+		return ErrNotValidYet
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return ErrIssuer
+	}
+	if v.Audience != "" && !c.Audience.Contains(v.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
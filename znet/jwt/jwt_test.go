@@ -0,0 +1,168 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/jwt"
+	"github.com/sohaha/zlsgo/zstring"
+)
+
+type userClaims struct {
+	Name string `json:"name"`
+	jwt.Claims
+}
+
+func TestAlgorithms(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	prv, pub, err := zstring.GenRSAKey()
+	tt.NoError(err, true)
+	rsaKey, err := jwt.ParseKey("rsa", jwt.RS256, prv)
+	tt.NoError(err, true)
+	rsaPub, err := jwt.ParseKey("rsa", jwt.RS256, pub)
+	tt.NoError(err, true)
+	tt.EqualFalse(rsaPub.CanSign())
+
+	ecPrv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(ecPrv)
+	ecKey, err := jwt.ParseKey("ec", jwt.ES256, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	tt.NoError(err, true)
+	der, _ = x509.MarshalPKIXPublicKey(&ecPrv.PublicKey)
+	ecPub, err := jwt.ParseKey("ec", jwt.ES256, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	tt.NoError(err, true)
+
+	edPub, edPrv, _ := ed25519.GenerateKey(rand.Reader)
+	edKey, err := jwt.NewKey("ed", jwt.EdDSA, edPrv)
+	tt.NoError(err, true)
+	edPubKey, err := jwt.NewKey("ed", jwt.EdDSA, edPub)
+	tt.NoError(err, true)
+
+	hsKey, err := jwt.NewKey("hs", jwt.HS256, "secret")
+	tt.NoError(err, true)
+
+	_, err = jwt.NewKey("rsa", jwt.RS256, edPrv)
+	tt.Equal(jwt.ErrInvalidKey, err)
+	_, err = jwt.NewKey("none", jwt.Algorithm("none"), nil)
+	tt.Equal(jwt.ErrAlgorithm, err)
+	_, err = jwt.Sign(rsaPub, jwt.Claims{})
+	tt.Equal(jwt.ErrCannotSign, err)
+
+	for _, v := range [][2]*jwt.Key{{rsaKey, rsaPub}, {ecKey, ecPub}, {edKey, edPubKey}, {hsKey, hsKey}} {
+		token, err := jwt.Sign(v[0], userClaims{Name: "zls", Claims: jwt.Claims{Subject: "1"}})
+		tt.NoError(err, true)
+		var claims userClaims
+		tt.NoError(jwt.Parse(v[1], token, &claims))
+		tt.Equal("zls", claims.Name)
+		tt.Equal("1", claims.Subject)
+
+		parts := strings.Split(token, ".")
+		forged := parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2]))
+		tt.Equal(jwt.ErrSignature, jwt.Parse(v[1], forged, nil))
+	}
+
+	token, _ := jwt.Sign(hsKey, jwt.Claims{})
+	tt.Equal(jwt.ErrAlgorithm, jwt.Parse(edPubKey, token, nil))
+	tt.Equal(jwt.ErrMalformed, jwt.Parse(hsKey, "a.b", nil))
+}
+
+func TestValidation(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	key, _ := jwt.NewKey("hs", jwt.HS256, []byte("secret"))
+	now := time.Now()
+	token, _ := jwt.Sign(key, jwt.Claims{
+		Issuer:    "zlsgo",
+		Audience:  jwt.Audience{"api", "admin"},
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		NotBefore: now.Add(-time.Minute).Unix(),
+		IssuedAt:  now.Unix(),
+	})
+
+	at := func(t time.Time, leeway time.Duration) func(v *jwt.Validation) {
+		return func(v *jwt.Validation) {
+			v.Now = func() time.Time { return t }
+			v.Leeway = leeway
+		}
+	}
+	tt.NoError(jwt.Parse(key, token, nil, at(now, 0)))
+	tt.Equal(jwt.ErrExpired, jwt.Parse(key, token, nil, at(now.Add(2*time.Minute), 0)))
+	tt.NoError(jwt.Parse(key, token, nil, at(now.Add(2*time.Minute), 2*time.Minute)))
+	tt.Equal(jwt.ErrNotValidYet, jwt.Parse(key, token, nil, at(now.Add(-2*time.Minute), 0)))
+	tt.NoError(jwt.Parse(key, token, nil, at(now.Add(-2*time.Minute), 3*time.Minute)))
+
+	tt.NoError(jwt.Parse(key, token, nil, func(v *jwt.Validation) {
+		v.Issuer, v.Audience = "zlsgo", "admin"
+	}))
+	tt.Equal(jwt.ErrIssuer, jwt.Parse(key, token, nil, func(v *jwt.Validation) { v.Issuer = "other" }))
+	tt.Equal(jwt.ErrAudience, jwt.Parse(key, token, nil, func(v *jwt.Validation) { v.Audience = "web" }))
+
+	token, _ = jwt.Sign(key, jwt.Claims{Audience: jwt.Audience{"api"}})
+	payload, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	tt.Equal(`{"aud":"api"}`, string(payload))
+	tt.Equal(jwt.ErrExpired, jwt.Parse(key, token, nil, func(v *jwt.Validation) { v.RequireExpiration = true }))
+}
+
+func TestMiddleware(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	old, _ := jwt.NewKey("2023", jwt.HS256, "old")
+	keys := jwt.NewKeySet(old)
+	oldToken, _ := keys.Sign(userClaims{Name: "old"})
+
+	next, _ := jwt.NewKey("2024", jwt.HS256, "new")
+	keys.Add(next, true)
+	newToken, _ := keys.Sign(userClaims{Name: "new", Claims: jwt.Claims{ExpiresAt: time.Now().Add(time.Hour).Unix()}})
+	expired, _ := keys.Sign(userClaims{Claims: jwt.Claims{ExpiresAt: time.Now().Add(-time.Hour).Unix()}})
+
+	r := znet.New("jwt-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(jwt.New[userClaims](keys, func(conf *jwt.Config) {
+		conf.Query = "token"
+	}))
+	r.GET("/me", func(c *znet.Context, claims *userClaims) {
+		got, _ := jwt.Get[userClaims](c)
+		c.String(200, claims.Name+" "+got.Name)
+	})
+
+	request := func(path, auth string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/me", "Bearer "+newToken)
+	tt.Equal(200, w.Code)
+	tt.Equal("new new", w.Body.String())
+	w = request("/me", "bearer "+oldToken)
+	tt.Equal("old old", w.Body.String())
+	w = request("/me?token="+newToken, "")
+	tt.Equal(200, w.Code)
+
+	w = request("/me", "")
+	tt.Equal(401, w.Code)
+	tt.Equal("Bearer", w.Header().Get("WWW-Authenticate"))
+	w = request("/me", "Bearer "+expired)
+	tt.Equal(401, w.Code)
+	tt.Equal(`Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+
+	keys.Remove("2023")
+	w = request("/me", "Bearer "+oldToken)
+	tt.Equal(401, w.Code)
+}
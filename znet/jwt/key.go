@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/sohaha/zlsgo/zstring"
+)
+
+type (
+	// Algorithm is a JWS signing algorithm
+	Algorithm string
+	// Key signs and verifies tokens with one algorithm, a key without the private part can only verify
+	Key struct {
+		private   crypto.PrivateKey
+		public    crypto.PublicKey
+		ID        string
+		Algorithm Algorithm
+		secret    []byte
+	}
+	// KeySet selects the verification key by the kid header and signs with the current key,
+	// add a new key and make it current to rotate while old tokens remain valid
+	KeySet struct {
+		keys    map[string]*Key
+		current string
+		mu      sync.RWMutex
+	}
+)
+
+// Supported algorithms
+const (
+	HS256 Algorithm = "HS256"
+	RS256 Algorithm = "RS256"
+	ES256 Algorithm = "ES256"
+	EdDSA Algorithm = "EdDSA"
+)
+
+var (
+	// ErrInvalidKey the key does not fit the algorithm
+	ErrInvalidKey = errors.New("jwt: invalid key")
+	// ErrKeyNotFound no key matches the kid of the token
+	ErrKeyNotFound = errors.New("jwt: key not found")
+	// ErrCannotSign the key has no private part
+	ErrCannotSign = errors.New("jwt: key cannot sign")
+)
+
+// NewKey creates a key, key is the []byte secret for HS256,
+// or a private or public key of crypto/rsa, crypto/ecdsa (P-256) or crypto/ed25519
+func NewKey(id string, alg Algorithm, key interface{}) (*Key, error) {
+	k := &Key{ID: id, Algorithm: alg}
+	switch alg {
+	case HS256:
+		switch v := key.(type) {
+		case []byte:
+			k.secret = v
+		case string:
+			k.secret = []byte(v)
+		}
+		if len(k.secret) == 0 {
+			return nil, ErrInvalidKey
+		}
+	case RS256:
+		switch v := key.(type) {
+		case *rsa.PrivateKey:
+			k.private, k.public = v, &v.PublicKey
+		case *rsa.PublicKey:
+			k.public = v
+		default:
+			return nil, ErrInvalidKey
+		}
+	case ES256:
+		switch v := key.(type) {
+		case *ecdsa.PrivateKey:
+			k.private, k.public = v, &v.PublicKey
+		case *ecdsa.PublicKey:
+			k.public = v
+		default:
+			return nil, ErrInvalidKey
+		}
+		if k.public.(*ecdsa.PublicKey).Curve != elliptic.P256() {
+			return nil, ErrInvalidKey
+		}
+	case EdDSA:
+		switch v := key.(type) {
+		case ed25519.PrivateKey:
+			k.private, k.public = v, v.Public()
+		case ed25519.PublicKey:
+			k.public = v
+		default:
+			return nil, ErrInvalidKey
+		}
+	default:
+		return nil, ErrAlgorithm
+	}
+	return k, nil
+}
+
+// ParseKey creates a key from a PEM encoded private or public key,
+// RSA keys are parsed with the zstring RSA helpers
+func ParseKey(id string, alg Algorithm, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+	switch {
+	case alg == RS256 && block.Type == "PUBLIC KEY":
+		key, err = zstring.ParseRSAPublicKey(data)
+	case alg == RS256:
+		key, err = zstring.ParseRSAPrivateKey(data)
+	case block.Type == "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case block.Type == "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(id, alg, key)
+}
+
+// CanSign reports whether the key holds the private part
+func (k *Key) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *Key) sign(data []byte) ([]byte, error) {
+	if !k.CanSign() {
+		return nil, ErrCannotSign
+	}
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case RS256:
+		sum := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, k.private.(*rsa.PrivateKey), crypto.SHA256, sum[:])
+	case ES256:
+		sum := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, k.private.(*ecdsa.PrivateKey), sum[:])
+		if err != nil {
+			return nil, err
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	case EdDSA:
+		return ed25519.Sign(k.private.(ed25519.PrivateKey), data), nil
+	}
+	return nil, ErrAlgorithm
+}
+
+func (k *Key) verify(data, sig []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case RS256:
+		sum := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k.public.(*rsa.PublicKey), crypto.SHA256, sum[:], sig) == nil
+	case ES256:
+		if len(sig) != 64 {
+			return false
+		}
+		sum := sha256.Sum256(data)
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k.public.(*ecdsa.PublicKey), sum[:], r, s)
+	case EdDSA:
+		return ed25519.Verify(k.public.(ed25519.PublicKey), data, sig)
+	}
+	return false
+}
+
+// NewKeySet creates a key set, the first key becomes the current signing key
+func NewKeySet(keys ...*Key) *KeySet {
+	s := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for i := range keys {
+		s.Add(keys[i], i == 0)
+	}
+	return s
+}
+
+// Add adds or replaces the key with the same ID, current makes it the signing key
+func (s *KeySet) Add(key *Key, current ...bool) {
+	s.mu.Lock()
+	s.keys[key.ID] = key
+	if len(current) > 0 && current[0] {
+		s.current = key.ID
+	}
+	s.mu.Unlock()
+}
+
+// Remove removes the key, tokens signed with it no longer verify
+func (s *KeySet) Remove(kid string) {
+	s.mu.Lock()
+	delete(s.keys, kid)
+	if s.current == kid {
+		s.current = ""
+	}
+	s.mu.Unlock()
+}
+
+// Current returns the signing key
+func (s *KeySet) Current() (*Key, bool) {
+	return s.Get(s.currentID())
+}
+
+// Get returns the key with the ID
+func (s *KeySet) Get(kid string) (*Key, bool) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+	return key, ok
+}
+
+func (s *KeySet) currentID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
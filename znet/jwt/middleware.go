@@ -0,0 +1,113 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// Config configuration of the middleware
+	Config struct {
+		// ErrorHandler responds to rejected requests, default is 401 with a WWW-Authenticate header
+		ErrorHandler func(c *znet.Context, err error)
+		// Skipper skips the check for the request when it returns true
+		Skipper func(c *znet.Context) bool
+		// Validation of the registered claims
+		Validation func(v *Validation)
+		// Header carrying the bearer token, default is Authorization
+		Header string
+		// Query is a query field carrying the token, disabled when empty
+		Query string
+		// Cookie is a cookie carrying the token, disabled when empty
+		Cookie string
+		// Optional lets requests without a token through, invalid tokens are still rejected
+		Optional bool
+	}
+)
+
+// ClaimsKey is the key of the claims stored on znet.Context
+const ClaimsKey = "jwt_claims"
+
+// ErrTokenMissing the request carries no token
+var ErrTokenMissing = errors.New("jwt: token missing")
+
+// New returns a middleware that verifies the bearer token with the key set and decodes it into *T,
+// the claims are stored on the Context and mapped into the injector, so handlers can take *T as an argument
+func New[T any](keys *KeySet, opt ...func(conf *Config)) znet.HandlerFunc {
+	conf := zutil.Optional(Config{
+		Header: "Authorization",
+		ErrorHandler: func(c *znet.Context, err error) {
+			if errors.Is(err, ErrTokenMissing) {
+				c.SetHeader("WWW-Authenticate", "Bearer", true)
+			} else {
+				c.SetHeader("WWW-Authenticate", `Bearer error="invalid_token"`, true)
+			}
+			c.Abort(http.StatusUnauthorized)
+		},
+	}, opt...)
+
+	var validation []func(v *Validation)
+	if conf.Validation != nil {
+		validation = append(validation, conf.Validation)
+	}
+
+	return func(c *znet.Context) {
+		if conf.Skipper != nil && conf.Skipper(c) {
+			c.Next()
+			return
+		}
+
+		token := conf.token(c)
+		if token == "" {
+			if conf.Optional {
+				c.Next()
+				return
+			}
+			conf.ErrorHandler(c, ErrTokenMissing)
+			c.Abort()
+			return
+		}
+
+		claims := new(T)
+		if err := keys.Parse(token, claims, validation...); err != nil {
+			conf.ErrorHandler(c, err)
+			c.Abort()
+			return
+		}
+
+		c.WithValue(ClaimsKey, claims)
+		_ = c.Injector().Map(claims)
+		c.Next()
+	}
+}
+
+// Get returns the claims decoded by the middleware
+func Get[T any](c *znet.Context) (*T, bool) {
+	v, ok := c.Value(ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*T)
+	return claims, ok
+}
+
+func (conf *Config) token(c *znet.Context) string {
+	if conf.Header != "" {
+		if v := c.GetHeader(conf.Header); len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+			return strings.TrimSpace(v[7:])
+		}
+	}
+	if conf.Query != "" {
+		if v := c.DefaultQuery(conf.Query, ""); v != "" {
+			return v
+		}
+	}
+	if conf.Cookie != "" {
+		return c.GetCookie(conf.Cookie)
+	}
+	return ""
+}
@@ -0,0 +1,264 @@
+package limiter
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+type (
+	// Algorithm decides whether a request is allowed from the state kept in a Store,
+	// the state is opaque to the store and nil when the key has no state yet
+	Algorithm interface {
+		// Name identifies the algorithm, rules using different algorithms never share state
+		Name() string
+		// Take consumes one request and returns the new state
+		Take(state []byte, now time.Time, limit int, window time.Duration) ([]byte, Result)
+		// Peek returns the result without consuming a request
+		Peek(state []byte, now time.Time, limit int, window time.Duration) Result
+	}
+	// Result of a rate limit check
+	Result struct {
+		// Reset is the time until the quota is fully restored
+		Reset time.Duration
+		// RetryAfter is the time until the next request is allowed, zero when allowed
+		RetryAfter time.Duration
+		// Window of the rule
+		Window    time.Duration
+		Limit     int
+		Remaining int
+		Allowed   bool
+	}
+	slidingLog  struct{}
+	fixedWindow struct{}
+	tokenBucket struct{}
+	gcra        struct{}
+)
+
+var (
+	// SlidingLog records the time of every request in the window, exact but the state grows with the limit
+	SlidingLog Algorithm = slidingLog{}
+	// FixedWindow counts requests per fixed window, cheap but allows bursts at window edges
+	FixedWindow Algorithm = fixedWindow{}
+	// TokenBucket refills limit tokens per window and allows bursts up to limit
+	TokenBucket Algorithm = tokenBucket{}
+	// GCRA is the generic cell rate algorithm, it spaces requests evenly and allows bursts up to limit
+	GCRA Algorithm = gcra{}
+)
+
+func decodeInt64s(state []byte) []int64 {
+	v := make([]int64, len(state)/8)
+	for i := range v {
+		v[i] = int64(binary.LittleEndian.Uint64(state[i*8:]))
+	}
+	return v
+}
+
+func encodeInt64s(v ...int64) []byte {
+	b := make([]byte, len(v)*8)
+	for i := range v {
+		binary.LittleEndian.PutUint64(b[i*8:], uint64(v[i]))
+	}
+	return b
+}
+
+func (slidingLog) Name() string {
+	return "sliding_log"
+}
+
+func (a slidingLog) Take(state []byte, now time.Time, limit int, window time.Duration) ([]byte, Result) {
+	log := a.trim(state, now, window)
+	if len(log) < limit {
+		log = append(log, now.UnixNano())
+		res := a.result(log, now, limit, window)
+		res.Allowed = true
+		return encodeInt64s(log...), res
+	}
+	return encodeInt64s(log...), a.result(log, now, limit, window)
+}
+
+func (a slidingLog) Peek(state []byte, now time.Time, limit int, window time.Duration) Result {
+	res := a.result(a.trim(state, now, window), now, limit, window)
+	res.Allowed = res.Remaining > 0
+	return res
+}
+
+func (slidingLog) trim(state []byte, now time.Time, window time.Duration) []int64 {
+	log := decodeInt64s(state)
+	start := now.Add(-window).UnixNano()
+	i := 0
+	for i < len(log) && log[i] <= start {
+		i++
+	}
+	return log[i:]
+}
+
+func (slidingLog) result(log []int64, now time.Time, limit int, window time.Duration) Result {
+	res := Result{Limit: limit, Window: window, Remaining: limit - len(log)}
+	if len(log) > 0 {
+		res.Reset = time.Duration(log[len(log)-1] + int64(window) - now.UnixNano())
+	}
+	if res.Remaining <= 0 {
+		res.Remaining = 0
+		if len(log) > 0 {
+			res.RetryAfter = time.Duration(log[len(log)-limit] + int64(window) - now.UnixNano())
+		} else {
+			res.RetryAfter = window
+		}
+	}
+	return res
+}
+
+func (fixedWindow) Name() string {
+	return "fixed_window"
+}
+
+func (a fixedWindow) Take(state []byte, now time.Time, limit int, window time.Duration) ([]byte, Result) {
+	start, count := a.window(state, now, window)
+	allowed := count < int64(limit)
+	if allowed {
+		count++
+	}
+	res := a.result(start, count, now, limit, window)
+	res.Allowed = allowed
+	return encodeInt64s(start, count), res
+}
+
+func (a fixedWindow) Peek(state []byte, now time.Time, limit int, window time.Duration) Result {
+	start, count := a.window(state, now, window)
+	res := a.result(start, count, now, limit, window)
+	res.Allowed = res.Remaining > 0
+	return res
+}
+
+func (fixedWindow) window(state []byte, now time.Time, window time.Duration) (start, count int64) {
+	v := decodeInt64s(state)
+	if len(v) == 2 && now.UnixNano() < v[0]+int64(window) {
+		return v[0], v[1]
+	}
+	return now.Truncate(window).UnixNano(), 0
+}
+
+func (fixedWindow) result(start, count int64, now time.Time, limit int, window time.Duration) Result {
+	res := Result{Limit: limit, Window: window, Remaining: limit - int(count)}
+	if count > 0 {
+		res.Reset = time.Duration(start + int64(window) - now.UnixNano())
+	}
+	if res.Remaining <= 0 {
+		res.Remaining = 0
+		res.RetryAfter = time.Duration(start + int64(window) - now.UnixNano())
+	}
+	return res
+}
+
+func (tokenBucket) Name() string {
+	return "token_bucket"
+}
+
+func (a tokenBucket) Take(state []byte, now time.Time, limit int, window time.Duration) ([]byte, Result) {
+	tokens := a.refill(state, now, limit, window)
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	res := a.result(tokens, limit, window)
+	res.Allowed = allowed
+	return encodeInt64s(int64(math.Float64bits(tokens)), now.UnixNano()), res
+}
+
+func (a tokenBucket) Peek(state []byte, now time.Time, limit int, window time.Duration) Result {
+	res := a.result(a.refill(state, now, limit, window), limit, window)
+	res.Allowed = res.Remaining > 0
+	return res
+}
+
+func (tokenBucket) refill(state []byte, now time.Time, limit int, window time.Duration) float64 {
+	v := decodeInt64s(state)
+	if len(v) != 2 {
+		return float64(limit)
+	}
+	tokens := math.Float64frombits(uint64(v[0]))
+	elapsed := float64(now.UnixNano() - v[1])
+	if elapsed > 0 {
+		tokens += elapsed * float64(limit) / float64(window)
+	}
+	return math.Min(tokens, float64(limit))
+}
+
+func (tokenBucket) result(tokens float64, limit int, window time.Duration) Result {
+	perToken := float64(window) / float64(limit)
+	res := Result{
+		Limit:     limit,
+		Window:    window,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(limit) - tokens) * perToken),
+	}
+	if tokens < 1 {
+		res.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return res
+}
+
+func (gcra) Name() string {
+	return "gcra"
+}
+
+func (a gcra) Take(state []byte, now time.Time, limit int, window time.Duration) ([]byte, Result) {
+	tat := a.tat(state, now)
+	interval := gcraInterval(limit, window)
+	next := tat + interval
+	allowAt := next - int64(window)
+	if now.UnixNano() < allowAt {
+		res := a.result(tat, now, limit, window)
+		res.RetryAfter = time.Duration(allowAt - now.UnixNano())
+		return encodeInt64s(tat), res
+	}
+	res := a.result(next, now, limit, window)
+	res.Allowed = true
+	return encodeInt64s(next), res
+}
+
+func (a gcra) Peek(state []byte, now time.Time, limit int, window time.Duration) Result {
+	tat := a.tat(state, now)
+	res := a.result(tat, now, limit, window)
+	res.Allowed = res.Remaining > 0
+	if !res.Allowed {
+		res.RetryAfter = time.Duration(tat + gcraInterval(limit, window) - int64(window) - now.UnixNano())
+	}
+	return res
+}
+
+// gcraInterval is the emission interval, at least one nanosecond
+func gcraInterval(limit int, window time.Duration) int64 {
+	interval := int64(window) / int64(limit)
+	if interval < 1 {
+		return 1
+	}
+	return interval
+}
+
+func (gcra) tat(state []byte, now time.Time) int64 {
+	v := decodeInt64s(state)
+	if len(v) == 1 && v[0] > now.UnixNano() {
+		return v[0]
+	}
+	return now.UnixNano()
+}
+
+func (gcra) result(tat int64, now time.Time, limit int, window time.Duration) Result {
+	interval := gcraInterval(limit, window)
+	backlog := tat - now.UnixNano()
+	if backlog < 0 {
+		backlog = 0
+	}
+	res := Result{
+		Limit:     limit,
+		Window:    window,
+		Remaining: int((int64(window) - backlog) / interval),
+		Reset:     time.Duration(backlog),
+	}
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	return res
+}
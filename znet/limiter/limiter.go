@@ -3,6 +3,8 @@ package limiter
 import (
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sohaha/zlsgo/znet"
@@ -10,27 +12,32 @@ import (
 
 // Rule user access control strategy
 type Rule struct {
-	rules []*singleRule
+	rules []policy
+}
+
+// RuleOptions options of a rule backed by a Store
+type RuleOptions struct {
+	// Store keeps the state, default is a new MemoryStore
+	Store Store
+	// Algorithm of the rule, default is SlidingLog
+	Algorithm Algorithm
+	// Prefix of the store keys, rules sharing a store need different prefixes,
+	// default is derived from the window, limit and algorithm
+	Prefix string
+}
+
+type policy interface {
+	take(key interface{}) Result
+	peek(key interface{}) Result
+	window() time.Duration
+	limit() int
 }
 
 // New Newlimiter
 func New(allowed uint64, overflow ...func(c *znet.Context)) znet.HandlerFunc {
 	r := NewRule()
-	f := func(c *znet.Context) {
-		c.String(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
-	}
-	if len(overflow) > 0 {
-		f = overflow[0]
-	}
 	r.AddRule(time.Second, int(allowed))
-	return func(c *znet.Context) {
-		if !r.AllowVisitByIP(c.GetClientIP()) {
-			f(c)
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
+	return r.Handler(overflow...)
 }
 
 // NewRule Custom limiter rule
@@ -41,33 +48,115 @@ func NewRule() *Rule {
 // AddRule increase user access control strategy
 // If less than 1s, please use golang.org/x/time/rate
 func (r *Rule) AddRule(exp time.Duration, allowed int, estimated ...int) {
-	r.rules = append(r.rules, newRule(exp, allowed, estimated...))
-	sort.Slice(r.rules, func(i int, j int) bool {
-		return r.rules[i].defaultExpiration < r.rules[j].defaultExpiration
+	r.addPolicy(newRule(exp, allowed, estimated...))
+}
+
+// AddStoreRule adds a rule whose state lives in a Store, so limits can be shared by processes
+// and survive restarts, the algorithm is selectable per rule, exp defaults to 1s when not positive
+func (r *Rule) AddStoreRule(exp time.Duration, allowed int, opt ...func(o *RuleOptions)) {
+	r.addPolicy(newStoreRule(exp, allowed, opt...))
+}
+
+func (r *Rule) addPolicy(p policy) {
+	r.rules = append(r.rules, p)
+	sort.SliceStable(r.rules, func(i int, j int) bool {
+		return r.rules[i].window() < r.rules[j].window()
 	})
 }
 
 // AllowVisit Is access allowed
 func (r *Rule) AllowVisit(keys ...interface{}) bool {
-	if len(r.rules) == 0 {
-		return true
-	}
+	return r.Take(keys...).Allowed
+}
+
+// AllowVisitByIP AllowVisit IP
+func (r *Rule) AllowVisitByIP(ip string) bool {
+	return r.TakeByIP(ip).Allowed
+}
+
+// Take records a visit of the keys on every rule and returns the most restrictive result,
+// checking stops at the first rule that rejects the visit
+func (r *Rule) Take(keys ...interface{}) Result {
+	res := Result{Allowed: true, Remaining: -1}
 	for i := range r.rules {
 		for _, key := range keys {
-			if !r.rules[i].allowVisit(key) {
-				return false
+			v := r.rules[i].take(key)
+			if !v.Allowed {
+				return v
+			}
+			if res.Remaining < 0 || v.Remaining < res.Remaining {
+				res = v
 			}
 		}
 	}
-	return true
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	return res
 }
 
-// AllowVisitByIP AllowVisit IP
-func (r *Rule) AllowVisitByIP(ip string) bool {
+// TakeByIP Take IP
+func (r *Rule) TakeByIP(ip string) Result {
 	i, err := znet.IPToLong(ip)
 	if err == nil {
-		return r.AllowVisit(i)
+		return r.Take(i)
+	}
+
+	return r.Take(ip)
+}
+
+// Handler returns a middleware limiting by client IP that responds with the
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and Retry-After headers
+func (r *Rule) Handler(overflow ...func(c *znet.Context)) znet.HandlerFunc {
+	f := func(c *znet.Context) {
+		c.String(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+	}
+	if len(overflow) > 0 {
+		f = overflow[0]
+	}
+	return func(c *znet.Context) {
+		res := r.TakeByIP(c.GetClientIP())
+		SetHeaders(c, res)
+		if len(r.rules) > 0 {
+			c.SetHeader("RateLimit-Policy", r.policyHeader())
+		}
+		if !res.Allowed {
+			f(c)
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
+}
 
-	return r.AllowVisit(ip)
+// SetHeaders writes the RateLimit headers of the result, and Retry-After when it is rejected
+func SetHeaders(c *znet.Context, res Result) {
+	if res.Limit == 0 {
+		return
+	}
+	c.SetHeader("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.SetHeader("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.SetHeader("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+	if !res.Allowed {
+		retry := seconds(res.RetryAfter)
+		if retry < 1 {
+			retry = 1
+		}
+		c.SetHeader("Retry-After", strconv.Itoa(retry))
+	}
+}
+
+func (r *Rule) policyHeader() string {
+	policies := make([]string, 0, len(r.rules))
+	for i := range r.rules {
+		policies = append(policies, strconv.Itoa(r.rules[i].limit())+";w="+strconv.Itoa(seconds(r.rules[i].window())))
+	}
+	return strings.Join(policies, ", ")
+}
+
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
 }
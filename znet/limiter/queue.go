@@ -46,6 +46,16 @@ func (c *circleQueue) pop() (val int64, err error) {
 	return
 }
 
+// bounds returns the first and last expiration in the queue
+func (c *circleQueue) bounds() (first, last int64, ok bool) {
+	t := c.mu.RLock()
+	defer c.mu.RUnlock(t)
+	if c.tail == c.head {
+		return 0, 0, false
+	}
+	return c.slice[c.head], c.slice[(c.tail+c.maxSize-1)%c.maxSize], true
+}
+
 func (c *circleQueue) isFull() bool {
 	t := c.mu.RLock()
 	defer c.mu.RUnlock(t)
@@ -1,8 +1,13 @@
 package limiter
 
 import (
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zutil"
 )
 
 type singleRule struct {
@@ -65,15 +70,44 @@ func (r *singleRule) allowVisit(key interface{}) bool {
 
 // remainingVisits Remaining visits
 func (r *singleRule) remainingVisits(key interface{}) int {
+	return r.peek(key).Remaining
+}
+
+func (r *singleRule) take(key interface{}) Result {
+	allowed := r.add(key) == nil
+	res := r.peek(key)
+	res.Allowed = allowed
+	return res
+}
+
+func (r *singleRule) peek(key interface{}) Result {
+	res := Result{Limit: r.allowed, Window: r.defaultExpiration, Remaining: r.allowed}
 	r.locker.Lock()
 	defer r.locker.Unlock()
 	if index, exist := r.usedRecordsIndex.Load(key); exist {
 		idx := index.(int)
 		if idx >= 0 && idx < len(r.records) {
-			r.records[idx].deleteExpired()
-			return r.records[idx].unUsedSize()
+			q := r.records[idx]
+			q.deleteExpired()
+			res.Remaining = q.unUsedSize()
+			if first, last, ok := q.bounds(); ok {
+				now := time.Now().UnixNano()
+				res.Reset = time.Duration(last - now)
+				if res.Remaining == 0 {
+					res.RetryAfter = time.Duration(first - now)
+				}
+			}
 		}
 	}
+	res.Allowed = res.Remaining > 0
+	return res
+}
+
+func (r *singleRule) window() time.Duration {
+	return r.defaultExpiration
+}
+
+func (r *singleRule) limit() int {
 	return r.allowed
 }
 
@@ -185,3 +219,69 @@ func (r *singleRule) needRecovery() bool {
 	}
 	return false
 }
+
+type storeRule struct {
+	store     Store
+	algorithm Algorithm
+	prefix    string
+	exp       time.Duration
+	allowed   int
+}
+
+func newStoreRule(exp time.Duration, allowed int, opt ...func(o *RuleOptions)) *storeRule {
+	o := zutil.Optional(RuleOptions{}, opt...)
+	if o.Store == nil {
+		o.Store = NewMemoryStore()
+	}
+	if o.Algorithm == nil {
+		o.Algorithm = SlidingLog
+	}
+	if allowed <= 0 {
+		allowed = 1
+	}
+	if exp <= 0 {
+		exp = time.Second
+	}
+	if o.Prefix == "" {
+		o.Prefix = o.Algorithm.Name() + ":" + strconv.Itoa(allowed) + ":" + exp.String() + ":"
+	}
+	return &storeRule{store: o.Store, algorithm: o.Algorithm, prefix: o.Prefix, exp: exp, allowed: allowed}
+}
+
+// take consumes a visit, store errors let the visit through
+func (r *storeRule) take(key interface{}) Result {
+	var res Result
+	err := r.store.Update(r.key(key), r.exp, func(state []byte) []byte {
+		state, res = r.algorithm.Take(state, time.Now(), r.allowed, r.exp)
+		return state
+	})
+	if err != nil {
+		return Result{Allowed: true, Limit: r.allowed, Window: r.exp, Remaining: r.allowed}
+	}
+	return res
+}
+
+func (r *storeRule) peek(key interface{}) Result {
+	state, err := r.store.Get(r.key(key))
+	if err != nil {
+		state = nil
+	}
+	return r.algorithm.Peek(state, time.Now(), r.allowed, r.exp)
+}
+
+func (r *storeRule) key(key interface{}) string {
+	if v, ok := key.(uint); ok {
+		if ip, err := znet.LongToIP(v); err == nil {
+			return r.prefix + ip
+		}
+	}
+	return r.prefix + fmt.Sprint(key)
+}
+
+func (r *storeRule) window() time.Duration {
+	return r.exp
+}
+
+func (r *storeRule) limit() int {
+	return r.allowed
+}
@@ -11,7 +11,7 @@ import (
 func (r *Rule) Remaining(key interface{}) []int {
 	arr := make([]int, 0, len(r.rules))
 	for i := range r.rules {
-		arr = append(arr, r.rules[i].remainingVisits(key))
+		arr = append(arr, r.rules[i].peek(key).Remaining)
 	}
 	return arr
 }
@@ -25,7 +25,7 @@ func (r *Rule) RemainingVisitsByIP(ip string) []int {
 	return r.Remaining(ipUint)
 }
 
-// GetOnline Get all current online users
+// GetOnline Get all current online users, rules backed by a Store are not included
 func (r *Rule) GetOnline() []string {
 	var insertIgnoreString = func(s []string, v string) []string {
 		for _, val := range s {
@@ -49,7 +49,9 @@ func (r *Rule) GetOnline() []string {
 			users = insertIgnoreString(users, user)
 			return true
 		}
-		r.rules[i].usedRecordsIndex.Range(f)
+		if rule, ok := r.rules[i].(*singleRule); ok {
+			rule.usedRecordsIndex.Range(f)
+		}
 	}
 	sort.Strings(users)
 	return users
@@ -0,0 +1,213 @@
+package limiter
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/zfile"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// Store keeps the algorithm state of every key, implementations must apply Update atomically
+	Store interface {
+		// Update replaces the state of key with the result of fn, missing or expired state is passed as nil,
+		// the new state expires after ttl
+		Update(key string, ttl time.Duration, fn func(state []byte) []byte) error
+		// Get returns the state of key, nil when it is missing or expired
+		Get(key string) ([]byte, error)
+	}
+	// MemoryStore keeps the state in process memory
+	MemoryStore struct {
+		entries   map[string]memoryEntry
+		collected time.Time
+		mu        sync.Mutex
+	}
+	memoryEntry struct {
+		state     []byte
+		expiresAt int64
+	}
+	// FileStore keeps the state in files so processes on the same host share the limits,
+	// updates are serialized across processes with zfile.ShardedFileLock
+	FileStore struct {
+		locks     *zfile.ShardedFileLock
+		collected time.Time
+		dir       string
+		mu        sync.Mutex
+	}
+	// FileStoreOptions options of the file store
+	FileStoreOptions struct {
+		// Shards is the number of lock files, keys in different shards update in parallel
+		Shards int
+		// LockTimeout is the longest wait for the lock of another process
+		LockTimeout time.Duration
+	}
+)
+
+// ErrLockTimeout the file lock was not acquired in time
+var ErrLockTimeout = errors.New("limiter: lock timeout")
+
+const collectInterval = time.Minute
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*FileStore)(nil)
+)
+
+// NewMemoryStore creates an in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), collected: time.Now()}
+}
+
+// Update implements Store
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.collected) > collectInterval {
+		s.collect(now.UnixNano())
+		s.collected = now
+	}
+	var state []byte
+	if e, ok := s.entries[key]; ok && e.expiresAt > now.UnixNano() {
+		state = e.state
+	}
+	s.entries[key] = memoryEntry{state: fn(state), expiresAt: now.Add(ttl).UnixNano()}
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.expiresAt > time.Now().UnixNano() {
+		return e.state, nil
+	}
+	return nil, nil
+}
+
+// Collect removes the expired state
+func (s *MemoryStore) Collect() {
+	s.mu.Lock()
+	s.collect(time.Now().UnixNano())
+	s.mu.Unlock()
+}
+
+func (s *MemoryStore) collect(now int64) {
+	for k, e := range s.entries {
+		if e.expiresAt <= now {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// NewFileStore creates a file store in dir
+func NewFileStore(dir string, opt ...func(o *FileStoreOptions)) (*FileStore, error) {
+	o := zutil.Optional(FileStoreOptions{Shards: 16, LockTimeout: 3 * time.Second}, opt...)
+	if o.Shards <= 0 {
+		o.Shards = 1
+	}
+	dir = zfile.RealPathMkdir(dir, true)
+	if !zfile.DirExist(dir) {
+		return nil, errors.New("limiter: can not create directory " + dir)
+	}
+
+	return &FileStore{
+		dir:       dir,
+		locks:     zfile.NewShardedFileLock(dir, o.Shards, o.LockTimeout),
+		collected: time.Now(),
+	}, nil
+}
+
+// Update implements Store
+func (s *FileStore) Update(key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	name, shard := s.file(key)
+	if err := s.lock(shard); err != nil {
+		return err
+	}
+	defer s.unlock(shard)
+
+	now := time.Now()
+	state := s.read(name, now.UnixNano())
+	state = fn(state)
+
+	b := make([]byte, 8+len(state))
+	binary.LittleEndian.PutUint64(b, uint64(now.Add(ttl).UnixNano()))
+	copy(b[8:], state)
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	collect := now.Sub(s.collected) > collectInterval
+	if collect {
+		s.collected = now
+	}
+	s.mu.Unlock()
+	if collect {
+		go s.Collect()
+	}
+	return nil
+}
+
+// Get implements Store
+func (s *FileStore) Get(key string) ([]byte, error) {
+	name, shard := s.file(key)
+	if err := s.lock(shard); err != nil {
+		return nil, err
+	}
+	defer s.unlock(shard)
+	return s.read(name, time.Now().UnixNano()), nil
+}
+
+// Collect removes the files of expired state
+func (s *FileStore) Collect() {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*.state"))
+	for _, name := range files {
+		base := filepath.Base(name)
+		shard := s.locks.Shard(base)
+		if s.lock(shard) != nil {
+			continue
+		}
+		if s.read(name, time.Now().UnixNano()) == nil {
+			_ = os.Remove(name)
+		}
+		s.unlock(shard)
+	}
+}
+
+func (s *FileStore) file(key string) (string, int) {
+	sum := sha1.Sum([]byte(key))
+	base := hex.EncodeToString(sum[:]) + ".state"
+	return filepath.Join(s.dir, base), s.locks.Shard(base)
+}
+
+func (s *FileStore) read(name string, now int64) []byte {
+	b, err := os.ReadFile(name)
+	if err != nil || len(b) < 8 || int64(binary.LittleEndian.Uint64(b)) <= now {
+		return nil
+	}
+	return b[8:]
+}
+
+func (s *FileStore) lock(shard int) error {
+	err := s.locks.Lock(shard)
+	if err == zfile.ErrLocked {
+		return ErrLockTimeout
+	}
+	return err
+}
+
+func (s *FileStore) unlock(shard int) {
+	s.locks.Unlock(shard)
+}
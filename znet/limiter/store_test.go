@@ -0,0 +1,181 @@
+package limiter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/limiter"
+)
+
+func TestAlgorithms(tt *testing.T) {
+	t := zlsgo.NewTest(tt)
+
+	now := time.Unix(1700000000, 0)
+	for _, a := range []limiter.Algorithm{limiter.SlidingLog, limiter.FixedWindow, limiter.TokenBucket, limiter.GCRA} {
+		t.Run(a.Name(), func(t *zlsgo.TestUtil) {
+			var (
+				state []byte
+				res   limiter.Result
+			)
+			for i := 0; i < 3; i++ {
+				state, res = a.Take(state, now, 3, time.Second)
+				t.EqualTrue(res.Allowed)
+				t.Equal(2-i, res.Remaining)
+			}
+			state, res = a.Take(state, now, 3, time.Second)
+			t.EqualFalse(res.Allowed)
+			t.Equal(0, res.Remaining)
+			t.EqualTrue(res.RetryAfter > 0 && res.RetryAfter <= time.Second)
+			t.EqualFalse(a.Peek(state, now, 3, time.Second).Allowed)
+
+			later := now.Add(time.Second)
+			t.EqualTrue(a.Peek(state, later, 3, time.Second).Allowed)
+			_, res = a.Take(state, later, 3, time.Second)
+			t.EqualTrue(res.Allowed)
+		})
+	}
+
+	var state []byte
+	var res limiter.Result
+	for i := 0; i < 3; i++ {
+		state, _ = limiter.GCRA.Take(state, now, 3, 3*time.Second)
+	}
+	state, res = limiter.GCRA.Take(state, now.Add(500*time.Millisecond), 3, 3*time.Second)
+	t.EqualFalse(res.Allowed)
+	t.Equal(500*time.Millisecond, res.RetryAfter)
+	_, res = limiter.GCRA.Take(state, now.Add(time.Second), 3, 3*time.Second)
+	t.EqualTrue(res.Allowed)
+
+	state = nil
+	for i := 0; i < 4; i++ {
+		state, _ = limiter.TokenBucket.Take(state, now, 4, 4*time.Second)
+	}
+	_, res = limiter.TokenBucket.Take(state, now.Add(1500*time.Millisecond), 4, 4*time.Second)
+	t.EqualTrue(res.Allowed)
+	t.Equal(0, res.Remaining)
+
+	state, res = limiter.GCRA.Take(nil, now, 10, time.Nanosecond)
+	t.EqualTrue(res.Allowed)
+	_, res = limiter.GCRA.Take(state, now, 10, time.Nanosecond)
+	t.EqualFalse(res.Allowed)
+}
+
+func TestFileStore(tt *testing.T) {
+	t := zlsgo.NewTest(tt)
+
+	dir := tt.TempDir()
+	a, err := limiter.NewFileStore(dir)
+	t.NoError(err, true)
+	b, err := limiter.NewFileStore(dir, func(o *limiter.FileStoreOptions) {
+		o.Shards = 16
+	})
+	t.NoError(err, true)
+
+	ruleA, ruleB := limiter.NewRule(), limiter.NewRule()
+	ruleA.AddStoreRule(time.Minute, 50, func(o *limiter.RuleOptions) { o.Store = a })
+	ruleB.AddStoreRule(time.Minute, 50, func(o *limiter.RuleOptions) { o.Store = b })
+
+	var wg sync.WaitGroup
+	var allowed int64
+	for i := 0; i < 80; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := ruleA
+			if i%2 == 0 {
+				r = ruleB
+			}
+			if r.AllowVisitByIP("10.0.0.1") {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	t.Equal(int64(50), allowed)
+	t.Equal([]int{0}, ruleA.RemainingVisitsByIP("10.0.0.1"))
+
+	c, _ := limiter.NewFileStore(dir)
+	t.NoError(c.Update("expired", time.Millisecond, func(state []byte) []byte { return []byte("x") }))
+	time.Sleep(5 * time.Millisecond)
+	state, err := c.Get("expired")
+	t.NoError(err)
+	t.EqualNil(state)
+	c.Collect()
+	state, _ = c.Get(limiter.SlidingLog.Name() + ":50:1m0s:10.0.0.1")
+	t.Equal(400, len(state))
+}
+
+func TestStoreRuleHeaders(tt *testing.T) {
+	t := zlsgo.NewTest(tt)
+
+	rule := limiter.NewRule()
+	rule.AddStoreRule(time.Minute, 2, func(o *limiter.RuleOptions) {
+		o.Algorithm = limiter.GCRA
+	})
+	rule.AddStoreRule(time.Second, 5, func(o *limiter.RuleOptions) {
+		o.Algorithm = limiter.TokenBucket
+		o.Store = limiter.NewMemoryStore()
+	})
+
+	r := znet.New("limiter-store-test")
+	r.SetMode(znet.QuietMode)
+	r.GET("/", func(c *znet.Context) {
+		c.String(200, "ok")
+	}, rule.Handler())
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Real-Ip", "10.0.0.2")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request()
+	t.Equal(200, w.Code)
+	t.Equal("2", w.Header().Get("RateLimit-Limit"))
+	t.Equal("1", w.Header().Get("RateLimit-Remaining"))
+	t.Equal("30", w.Header().Get("RateLimit-Reset"))
+	t.Equal("5;w=1, 2;w=60", w.Header().Get("RateLimit-Policy"))
+	t.Equal("", w.Header().Get("Retry-After"))
+
+	t.Equal(200, request().Code)
+	w = request()
+	t.Equal(429, w.Code)
+	t.Equal("0", w.Header().Get("RateLimit-Remaining"))
+	t.Equal("30", w.Header().Get("Retry-After"))
+}
+
+func TestStoreRuleZeroWindow(tt *testing.T) {
+	t := zlsgo.NewTest(tt)
+
+	rule := limiter.NewRule()
+	rule.AddStoreRule(0, 1, func(o *limiter.RuleOptions) {
+		o.Algorithm = limiter.GCRA
+	})
+
+	r := znet.New("limiter-store-zero-test")
+	r.SetMode(znet.QuietMode)
+	r.GET("/", func(c *znet.Context) {
+		c.String(200, "ok")
+	}, rule.Handler())
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Real-Ip", "10.0.0.3")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request()
+	t.Equal(200, w.Code)
+	t.Equal("1;w=1", w.Header().Get("RateLimit-Policy"))
+	t.Equal(429, request().Code)
+}
@@ -0,0 +1,165 @@
+// Package metrics provides Prometheus metrics for znet
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sohaha/zlsgo/znet"
+)
+
+type (
+	// Config configuration
+	Config struct {
+		// Registry receives the HTTP metrics, default is DefaultRegistry
+		Registry *Registry
+		// Skipper skips recording the request when it returns true
+		Skipper func(c *znet.Context) bool
+		// Namespace is prefixed to the metric names
+		Namespace string
+		// UnmatchedRoute is the route label of requests that matched no route, default is "unmatched"
+		UnmatchedRoute string
+		// Buckets of the request duration histogram in seconds, default is DefaultBuckets
+		Buckets []float64
+		// SizeBuckets of the response size histogram in bytes, default is DefaultSizeBuckets
+		SizeBuckets []float64
+	}
+	sizeWriter struct {
+		http.ResponseWriter
+		size int
+		code int
+	}
+)
+
+// ContentType of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// New returns a middleware that records the requests, latency, in-flight requests and
+// response size of every route, labelled by the route pattern instead of the request path
+func New(opt ...func(conf *Config)) znet.HandlerFunc {
+	conf := Config{
+		Registry:       DefaultRegistry,
+		UnmatchedRoute: "unmatched",
+		Buckets:        DefaultBuckets,
+		SizeBuckets:    DefaultSizeBuckets,
+	}
+	for _, o := range opt {
+		o(&conf)
+	}
+
+	prefix := ""
+	if conf.Namespace != "" {
+		prefix = conf.Namespace + "_"
+	}
+	reg := conf.Registry
+	requests := reg.NewCounter(prefix+"http_requests_total", "Total number of HTTP requests.", "method", "route", "code")
+	duration := reg.NewHistogram(prefix+"http_request_duration_seconds", "HTTP request latency in seconds.", conf.Buckets, "method", "route")
+	inFlight := reg.NewGauge(prefix+"http_requests_in_flight", "Number of HTTP requests being served.")
+	size := reg.NewHistogram(prefix+"http_response_size_bytes", "HTTP response size in bytes.", conf.SizeBuckets, "method", "route")
+
+	return func(c *znet.Context) {
+		if conf.Skipper != nil && conf.Skipper(c) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		inFlight.Inc()
+		w := &sizeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			inFlight.Dec()
+			route := c.RoutePath()
+			if route == "" {
+				route = conf.UnmatchedRoute
+			}
+			code := int(c.PrevContent().Code.Load())
+			if code == 0 {
+				code = w.code
+			}
+			if code == 0 {
+				code = http.StatusOK
+			}
+			n := len(c.PrevContent().Content)
+			if w.size > n {
+				n = w.size
+			}
+
+			method := c.Request.Method
+			requests.Inc(method, route, strconv.Itoa(code))
+			duration.Observe(time.Since(start).Seconds(), method, route)
+			size.Observe(float64(n), method, route)
+		}()
+		c.Next()
+	}
+}
+
+// Handler serves the metrics of the registries in the Prometheus text exposition format,
+// default is DefaultRegistry
+func Handler(reg ...*Registry) znet.HandlerFunc {
+	if len(reg) == 0 {
+		reg = []*Registry{DefaultRegistry}
+	}
+	return func(c *znet.Context) {
+		var b bytes.Buffer
+		for _, r := range reg {
+			_, _ = r.WriteTo(&b)
+		}
+		c.SetContentType(ContentType)
+		c.Byte(http.StatusOK, b.Bytes())
+	}
+}
+
+// Register uses the middleware on the engine and serves the metrics at path,
+// requests to the metrics endpoint itself are not recorded
+func Register(e *znet.Engine, path string, opt ...func(conf *Config)) {
+	if path == "" {
+		path = "/metrics"
+	}
+	conf := Config{Registry: DefaultRegistry}
+	o := append([]func(conf *Config){func(conf *Config) {
+		conf.Skipper = func(c *znet.Context) bool {
+			return c.Request.URL.Path == path
+		}
+	}}, opt...)
+	for _, fn := range o {
+		fn(&conf)
+	}
+	e.Use(New(o...))
+	e.GET(path, Handler(conf.Registry))
+}
+
+func (w *sizeWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sizeWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *sizeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *sizeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+func (w *sizeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,113 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/metrics"
+)
+
+func request(r *znet.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMetrics(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	reg := metrics.NewRegistry()
+	jobs := reg.NewCounter("app_jobs_total", "Processed jobs.", "queue")
+	r := znet.New("metrics-test")
+	r.SetMode(znet.QuietMode)
+	metrics.Register(r, "/metrics", func(conf *metrics.Config) {
+		conf.Registry = reg
+	})
+	r.GET("/users/:id", func(c *znet.Context) {
+		jobs.Inc("mail")
+		c.String(200, "user "+c.GetParam("id"))
+	})
+	r.GET("/stream", func(c *znet.Context) {
+		c.Stream(func(w io.Writer) bool {
+			_, _ = w.Write([]byte("chunk"))
+			return false
+		})
+	})
+
+	request(r, "GET", "/users/1")
+	request(r, "GET", "/users/2")
+	request(r, "GET", "/stream")
+	request(r, "GET", "/missing")
+
+	w := request(r, "GET", "/metrics")
+	tt.Equal(200, w.Code)
+	tt.Equal(metrics.ContentType, w.Header().Get("Content-Type"))
+	body := w.Body.String()
+
+	for _, v := range []string{
+		"# TYPE app_jobs_total counter\napp_jobs_total{queue=\"mail\"} 2\n",
+		`http_requests_total{method="GET",route="/users/:id",code="200"} 2`,
+		`http_requests_total{method="GET",route="unmatched",code="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/users/:id"} 2`,
+		`http_request_duration_seconds_bucket{method="GET",route="/users/:id",le="+Inf"} 2`,
+		`http_response_size_bytes_bucket{method="GET",route="/stream",le="100"} 1`,
+		`http_response_size_bytes_sum{method="GET",route="/stream"} 5`,
+		`http_response_size_bytes_sum{method="GET",route="/users/:id"} 12`,
+		"http_requests_in_flight 0\n",
+	} {
+		tt.Contains(v, body)
+	}
+	tt.NotContains(`route="/metrics"`, body)
+	tt.NotContains("go_goroutines", body)
+}
+
+func TestRegistry(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	reg := metrics.NewRegistry()
+	reg.RegisterRuntime()
+	g := reg.NewGauge("queue_size", "Queue \"size\".\nSecond line.", "name")
+	g.Set(3, `a"b`)
+	g.Dec(`a"b`)
+	g.Add(0.5, `a"b`)
+	tt.Equal(2.5, g.Value(`a"b`))
+	tt.Equal(0.0, g.Value("other"))
+
+	c := reg.NewCounter("events_total", "")
+	c.Add(-1)
+	c.Add(2)
+	tt.Equal(2.0, c.Value())
+	tt.Equal(c.Value(), reg.NewCounter("events_total", "").Value())
+
+	h := reg.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(5)
+	tt.Equal(uint64(3), h.Count())
+	tt.Equal(5.15, h.Sum())
+
+	var b bytes.Buffer
+	_, err := reg.WriteTo(&b)
+	tt.NoError(err)
+	out := b.String()
+	tt.Contains("# TYPE events_total counter\nevents_total 2\n", out)
+	tt.Contains("latency_seconds_bucket{le=\"0.1\"} 2\nlatency_seconds_bucket{le=\"1\"} 2\nlatency_seconds_bucket{le=\"+Inf\"} 3\nlatency_seconds_sum 5.15\nlatency_seconds_count 3\n", out)
+	tt.Contains("# HELP queue_size Queue \"size\".\\nSecond line.\n# TYPE queue_size gauge\nqueue_size{name=\"a\\\"b\"} 2.5\n", out)
+	tt.Contains("# TYPE go_goroutines gauge\n", out)
+	tt.Contains("process_uptime_seconds ", out)
+
+	for _, fn := range []func(){
+		func() { reg.NewGauge("events_total", "") },
+		func() { reg.NewCounter("events_total", "", "label") },
+		func() { reg.NewCounter("1invalid", "") },
+		func() { reg.NewHistogram("h", "", nil, "le") },
+	} {
+		tt.Panics(fn)
+	}
+}
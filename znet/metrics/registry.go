@@ -0,0 +1,408 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// Registry holds the metrics exposed by Handler
+	Registry struct {
+		collectors map[string]collector
+		mu         sync.RWMutex
+	}
+	// Counter is a monotonically increasing value
+	Counter struct {
+		*vec
+	}
+	// Gauge is a value that can go up and down
+	Gauge struct {
+		*vec
+	}
+	// Histogram counts observations in configurable buckets
+	Histogram struct {
+		*vec
+	}
+	collector interface {
+		kind() string
+		labels() []string
+		write(b *bytes.Buffer)
+	}
+	vec struct {
+		series     map[string]*series
+		name       string
+		help       string
+		typ        string
+		labelNames []string
+		buckets    []float64
+		mu         sync.Mutex
+	}
+	series struct {
+		labelValues []string
+		counts      []uint64
+		value       float64
+		count       uint64
+	}
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+var (
+	// ErrInvalidName the metric or label name is not a valid Prometheus name
+	ErrInvalidName = errors.New("metrics: invalid metric or label name")
+	// ErrAlreadyRegistered a different metric with the same name already exists
+	ErrAlreadyRegistered = errors.New("metrics: metric already registered with a different type or labels")
+
+	// DefaultBuckets latency buckets in seconds
+	DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets response size buckets in bytes
+	DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+	// DefaultRegistry is used when no registry is configured, it includes the runtime statistics
+	DefaultRegistry = NewRegistry()
+)
+
+func init() {
+	DefaultRegistry.RegisterRuntime()
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// NewCounter registers a counter on the default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+// NewGauge registers a gauge on the default registry
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+// NewHistogram registers a histogram on the default registry
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewCounter registers a counter, registering the same name and labels again returns the existing one,
+// it panics when the name is invalid or taken by a different metric
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, typeCounter, nil, labels)}
+}
+
+// NewGauge registers a gauge, see NewCounter for the registration rules
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, typeGauge, nil, labels)}
+}
+
+// NewHistogram registers a histogram with the upper bounds of its buckets,
+// DefaultBuckets is used when buckets is empty, see NewCounter for the registration rules
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := make([]float64, 0, len(buckets))
+	for _, v := range buckets {
+		if !math.IsInf(v, 1) {
+			b = append(b, v)
+		}
+	}
+	sort.Float64s(b)
+	return &Histogram{r.register(name, help, typeHistogram, b, labels)}
+}
+
+// Unregister removes a metric from the registry
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.collectors, name)
+	r.mu.Unlock()
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	var b bytes.Buffer
+	for _, c := range collectors {
+		c.write(&b)
+	}
+	return b.WriteTo(w)
+}
+
+func (r *Registry) register(name, help, typ string, buckets []float64, labels []string) *vec {
+	if !validName(name, true) {
+		panic(ErrInvalidName)
+	}
+	for _, l := range labels {
+		if !validName(l, false) || strings.HasPrefix(l, "__") || (typ == typeHistogram && l == "le") {
+			panic(ErrInvalidName)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.collectors[name]; ok {
+		v, isVec := c.(*vec)
+		if !isVec || c.kind() != typ || strings.Join(c.labels(), ",") != strings.Join(labels, ",") ||
+			len(v.buckets) != len(buckets) {
+			panic(ErrAlreadyRegistered)
+		}
+		return v
+	}
+
+	v := &vec{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: append([]string(nil), labels...),
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.collectors[name] = v
+	return v
+}
+
+func (v *vec) kind() string {
+	return v.typ
+}
+
+func (v *vec) labels() []string {
+	return v.labelNames
+}
+
+// with returns the series of the label values, missing values are empty and extra values are ignored,
+// the caller must hold the lock
+func (v *vec) with(labelValues []string) *series {
+	values := make([]string, len(v.labelNames))
+	copy(values, labelValues)
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: values}
+		if v.typ == typeHistogram {
+			s.counts = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	v.mu.Lock()
+	v.with(labelValues).value += delta
+	v.mu.Unlock()
+}
+
+func (v *vec) lookup(labelValues []string) (series, bool) {
+	values := make([]string, len(v.labelNames))
+	copy(values, labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[strings.Join(values, "\xff")]
+	if !ok {
+		return series{}, false
+	}
+	return *s, true
+}
+
+func (v *vec) get(labelValues []string) float64 {
+	s, _ := v.lookup(labelValues)
+	return s.value
+}
+
+// Inc increments the counter by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add increases the counter, negative values are ignored
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta > 0 {
+		c.add(delta, labelValues)
+	}
+}
+
+// Value returns the current value
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.get(labelValues)
+}
+
+// Set sets the gauge to value
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	g.with(labelValues).value = value
+	g.mu.Unlock()
+}
+
+// Inc increments the gauge by 1
+func (g *Gauge) Inc(labelValues ...string) {
+	g.add(1, labelValues)
+}
+
+// Dec decrements the gauge by 1
+func (g *Gauge) Dec(labelValues ...string) {
+	g.add(-1, labelValues)
+}
+
+// Add adds delta to the gauge, delta may be negative
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// Value returns the current value
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.get(labelValues)
+}
+
+// Observe adds an observation to the histogram
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	i := sort.SearchFloat64s(h.buckets, value)
+	h.mu.Lock()
+	s := h.with(labelValues)
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.count++
+	s.value += value
+	h.mu.Unlock()
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	s, _ := h.lookup(labelValues)
+	return s.count
+}
+
+// Sum returns the sum of all observations
+func (h *Histogram) Sum(labelValues ...string) float64 {
+	return h.get(labelValues)
+}
+
+func (v *vec) write(b *bytes.Buffer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.series) == 0 && len(v.labelNames) > 0 {
+		return
+	}
+	if len(v.series) == 0 {
+		v.with(nil)
+	}
+
+	writeHeader(b, v.name, v.help, v.typ)
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := v.series[k]
+		if v.typ != typeHistogram {
+			writeSample(b, v.name, v.labelNames, s.labelValues, "", "", s.value)
+			continue
+		}
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += s.counts[i]
+			writeSample(b, v.name+"_bucket", v.labelNames, s.labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(b, v.name+"_bucket", v.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(b, v.name+"_sum", v.labelNames, s.labelValues, "", "", s.value)
+		writeSample(b, v.name+"_count", v.labelNames, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+func writeHeader(b *bytes.Buffer, name, help, typ string) {
+	if help != "" {
+		b.WriteString("# HELP ")
+		b.WriteString(name)
+		b.WriteByte(' ')
+		b.WriteString(strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+		b.WriteByte('\n')
+	}
+	b.WriteString("# TYPE ")
+	b.WriteString(name)
+	b.WriteByte(' ')
+	b.WriteString(typ)
+	b.WriteByte('\n')
+}
+
+func writeSample(b *bytes.Buffer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	b.WriteString(name)
+	if len(labelNames) > 0 || extraName != "" {
+		b.WriteByte('{')
+		for i, l := range labelNames {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeLabel(b, l, labelValues[i])
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				b.WriteByte(',')
+			}
+			writeLabel(b, extraName, extraValue)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	b.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	b.WriteString(`="`)
+	b.WriteString(labelEscaper.Replace(value))
+	b.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func validName(name string, colon bool) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (colon && c == ':') ||
+			(i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/sohaha/zlsgo/zpprof"
+)
+
+type runtimeCollector struct {
+	startTime time.Time
+}
+
+const runtimeName = "go_runtime"
+
+var startTime = time.Now()
+
+// RegisterRuntime adds the Go runtime statistics of zpprof.SystemStats to the registry
+func (r *Registry) RegisterRuntime() {
+	r.mu.Lock()
+	if _, ok := r.collectors[runtimeName]; !ok {
+		r.collectors[runtimeName] = &runtimeCollector{startTime: startTime}
+	}
+	r.mu.Unlock()
+}
+
+func (r *runtimeCollector) kind() string {
+	return "runtime"
+}
+
+func (r *runtimeCollector) labels() []string {
+	return nil
+}
+
+func (r *runtimeCollector) write(b *bytes.Buffer) {
+	s := zpprof.NewSystemStats(r.startTime)
+	var lastGC float64
+	if !s.LastGC.IsZero() {
+		lastGC = float64(s.LastGC.UnixNano()) / 1e9
+	}
+	for _, m := range []struct {
+		name  string
+		help  string
+		typ   string
+		value float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", typeGauge, float64(s.Goroutines)},
+		{"go_cpus", "Number of logical CPUs usable by the process.", typeGauge, float64(s.CPUs)},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", typeCounter, float64(s.NumGC)},
+		{"go_gc_last_pause_seconds", "Duration of the last GC pause.", typeGauge, float64(s.PauseNs) / 1e9},
+		{"go_gc_pause_seconds_total", "Total GC pause duration.", typeCounter, float64(s.PauseTotalNs) / 1e9},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", typeGauge, float64(s.Alloc)},
+		{"go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", typeCounter, float64(s.TotalAlloc)},
+		{"go_memstats_frees_total", "Total number of frees.", typeCounter, float64(s.Frees)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", typeGauge, float64(s.HeapInuse)},
+		{"go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of last garbage collection.", typeGauge, lastGC},
+		{"go_memstats_lookups_total", "Total number of pointer lookups.", typeCounter, float64(s.Lookups)},
+		{"go_memstats_mallocs_total", "Total number of mallocs.", typeCounter, float64(s.Mallocs)},
+		{"go_memstats_next_gc_bytes", "Number of heap bytes when next garbage collection will take place.", typeGauge, float64(s.NextGC)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", typeGauge, float64(s.Sys)},
+		{"process_uptime_seconds", "Number of seconds since the process started.", typeGauge, s.Uptime.Seconds()},
+	} {
+		writeHeader(b, m.name, m.help, m.typ)
+		writeSample(b, m.name, nil, nil, "", "", m.value)
+	}
+}
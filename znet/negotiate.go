@@ -0,0 +1,189 @@
+package znet
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sohaha/zlsgo/ztype"
+)
+
+type (
+	// Encoder encodes data as the response body of a media type
+	Encoder func(data interface{}) ([]byte, error)
+	encoder struct {
+		encode      Encoder
+		mediaType   string
+		contentType string
+	}
+)
+
+var (
+	// ContentTypeXML xml
+	ContentTypeXML = "application/xml; charset=utf-8"
+	// ContentTypeYAML yaml
+	ContentTypeYAML = "application/yaml; charset=utf-8"
+	// ContentTypeMsgPack messagepack
+	ContentTypeMsgPack = "application/msgpack"
+	// ContentTypeCSV csv
+	ContentTypeCSV = "text/csv; charset=utf-8"
+	// ContentTypeNDJSON newline delimited json
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// ErrEncoderNotFound no encoder is registered for the media type
+var ErrEncoderNotFound = errors.New("encoder not found")
+
+var encoders = struct {
+	list []encoder
+	mu   sync.RWMutex
+}{}
+
+func init() {
+	RegisterEncoder(ContentTypeJSON, json.Marshal)
+	RegisterEncoder(ContentTypeXML, encodeXML)
+	RegisterEncoder(ContentTypeYAML, encodeYAML)
+	RegisterEncoder(ContentTypeMsgPack, encodeMsgPack)
+	RegisterEncoder(ContentTypeCSV, func(data interface{}) ([]byte, error) {
+		rows, err := toCSVRows(data)
+		if err != nil {
+			return nil, err
+		}
+		return encodeCSV(rows)
+	})
+	RegisterEncoder("text/xml; charset=utf-8", encodeXML)
+	RegisterEncoder("application/x-yaml; charset=utf-8", encodeYAML)
+	RegisterEncoder("application/x-msgpack", encodeMsgPack)
+}
+
+// RegisterEncoder registers the encoder used by Render and Negotiate for the media type
+// of contentType, e.g. a binary format for application/x-protobuf, registering a media
+// type again replaces its encoder
+func RegisterEncoder(contentType string, encode Encoder) {
+	mediaType := mediaTypeOf(contentType)
+	encoders.mu.Lock()
+	defer encoders.mu.Unlock()
+	for i := range encoders.list {
+		if encoders.list[i].mediaType == mediaType {
+			encoders.list[i] = encoder{mediaType: mediaType, contentType: contentType, encode: encode}
+			return
+		}
+	}
+	encoders.list = append(encoders.list, encoder{mediaType: mediaType, contentType: contentType, encode: encode})
+}
+
+// EncoderTypes returns the registered media types in the order they were registered
+func EncoderTypes() []string {
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+	types := make([]string, 0, len(encoders.list))
+	for i := range encoders.list {
+		types = append(types, encoders.list[i].mediaType)
+	}
+	return types
+}
+
+func lookupEncoder(mediaType string) (encoder, bool) {
+	mediaType = mediaTypeOf(mediaType)
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+	for i := range encoders.list {
+		if encoders.list[i].mediaType == mediaType {
+			return encoders.list[i], true
+		}
+	}
+	return encoder{}, false
+}
+
+func mediaTypeOf(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// Render responds data encoded by the encoder registered for mediaType
+func (c *Context) Render(code int32, mediaType string, data interface{}) error {
+	e, ok := lookupEncoder(mediaType)
+	if !ok {
+		return ErrEncoderNotFound
+	}
+	b, err := e.encode(data)
+	if err != nil {
+		return err
+	}
+	c.SetContentType(e.contentType)
+	c.Byte(code, b)
+	return nil
+}
+
+// Negotiate responds data in the media type preferred by the Accept header among
+// offers, all registered media types are offered by default and the first offer is
+// used when the header is empty, a 406 response is sent when none is acceptable
+func (c *Context) Negotiate(code int32, data interface{}, offers ...string) error {
+	if len(offers) == 0 {
+		offers = EncoderTypes()
+	}
+	c.SetHeader("Vary", "Accept")
+	mediaType := c.Accepts(offers...)
+	if mediaType == "" {
+		c.String(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+		return nil
+	}
+	return c.Render(code, mediaType, data)
+}
+
+// XML responds data as XML, maps and slices of maps are wrapped in a root element
+func (c *Context) XML(code int32, data interface{}) error {
+	return c.Render(code, ContentTypeXML, data)
+}
+
+// YAML responds data as YAML
+func (c *Context) YAML(code int32, data interface{}) error {
+	return c.Render(code, ContentTypeYAML, data)
+}
+
+// MsgPack responds data as MessagePack
+func (c *Context) MsgPack(code int32, data interface{}) error {
+	return c.Render(code, ContentTypeMsgPack, data)
+}
+
+// CSV responds rows as CSV with a header line, columns default to the sorted keys of the rows
+func (c *Context) CSV(code int32, rows ztype.Maps, columns ...string) error {
+	b, err := encodeCSV(rows, columns...)
+	if err != nil {
+		return err
+	}
+	c.SetContentType(ContentTypeCSV)
+	c.Byte(code, b)
+	return nil
+}
+
+// NDJSON streams newline delimited JSON, a line is written and flushed for every
+// value returned by next until it returns false or the client goes away
+func (c *Context) NDJSON(code int32, next func() (data interface{}, ok bool)) error {
+	c.SetContentType(ContentTypeNDJSON)
+	c.SetStatus(code)
+	c.write()
+
+	flusher, _ := c.Writer.(http.Flusher)
+	enc := json.NewEncoder(c.Writer)
+	ctx := c.Request.Context()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, ok := next()
+		if !ok {
+			return nil
+		}
+		if err := enc.Encode(data); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package znet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/ztype"
+)
+
+func TestNegotiate(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	type user struct {
+		XMLName xml.Name          `xml:"user" json:"-"`
+		Name    string            `xml:"name" json:"name"`
+		Note    string            `xml:"note" json:"note"`
+		Tags    []string          `xml:"tags>tag" json:"tags"`
+		Info    map[string]string `xml:"-" json:"info"`
+		Empty   []int             `xml:"-" json:"empty"`
+	}
+	u := user{Name: "zls", Note: "yes: x", Tags: []string{"a", "b"}, Info: map[string]string{"k": "1"}, Empty: []int{}}
+
+	encoders.mu.RLock()
+	registered := append([]encoder(nil), encoders.list...)
+	encoders.mu.RUnlock()
+	t.Cleanup(func() {
+		encoders.mu.Lock()
+		encoders.list = registered
+		encoders.mu.Unlock()
+	})
+	RegisterEncoder("application/x-test", func(data interface{}) ([]byte, error) {
+		return []byte("test:" + data.(user).Name), nil
+	})
+
+	r := New("negotiate-test")
+	r.SetMode(QuietMode)
+	r.GET("/user", func(c *Context) error {
+		return c.Negotiate(200, u)
+	})
+	r.GET("/limited", func(c *Context) error {
+		return c.Negotiate(201, ztype.Map{"a": 1}, "application/json", "application/yaml")
+	})
+	r.GET("/map.xml", func(c *Context) error {
+		return c.XML(200, ztype.Map{"name": "zls", "list": []int{1, 2}, "1st": nil})
+	})
+	r.GET("/list.xml", func(c *Context) error {
+		return c.XML(200, []user{{Name: "a"}, {Name: "b"}})
+	})
+	r.GET("/ints.xml", func(c *Context) error {
+		return c.XML(200, []int{1, 2})
+	})
+	r.GET("/pack", func(c *Context) error {
+		return c.MsgPack(200, ztype.Map{"a": 1, "b": []interface{}{true, nil}, "c": "x", "d": -300})
+	})
+	r.GET("/export", func(c *Context) error {
+		return c.CSV(200, ztype.Maps{{"id": 1, "name": "a,b"}, {"id": 2, "name": "c"}}, "name", "id")
+	})
+	r.GET("/stream", func(c *Context) error {
+		i := 0
+		return c.NDJSON(200, func() (interface{}, bool) {
+			i++
+			return ztype.Map{"i": i}, i <= 3
+		})
+	})
+
+	client := r.Test(tt)
+
+	client.GET("/user").Do().
+		ExpectHeader("Content-Type", ContentTypeJSON).
+		ExpectHeader("Vary", "Accept").
+		ExpectJSON("tags.1", "b")
+
+	client.GET("/user").Header("Accept", "text/xml;q=0.5, application/xml").Do().
+		ExpectHeader("Content-Type", ContentTypeXML).
+		ExpectBody(xml.Header + "<user><name>zls</name><note>yes: x</note><tags><tag>a</tag><tag>b</tag></tags></user>")
+
+	client.GET("/user").Header("Accept", "application/yaml").Do().
+		ExpectHeader("Content-Type", ContentTypeYAML).
+		ExpectBody("name: zls\nnote: \"yes: x\"\ntags:\n  - a\n  - b\ninfo:\n  k: \"1\"\nempty: []\n")
+
+	client.GET("/user").Header("Accept", "application/x-test").Do().
+		ExpectHeader("Content-Type", "application/x-test").
+		ExpectBody("test:zls")
+
+	client.GET("/user").Header("Accept", "text/csv").Do().ExpectStatus(500)
+
+	client.GET("/limited").Header("Accept", "application/yaml;q=0.8, */*;q=0.1").Do().
+		ExpectStatus(201).ExpectBody("a: 1\n")
+	client.GET("/limited").Header("Accept", "application/xml").Do().ExpectStatus(406)
+
+	client.GET("/map.xml").Do().
+		ExpectBody(xml.Header + "<root><_st></_st><list><item>1</item><item>2</item></list><name>zls</name></root>")
+	client.GET("/list.xml").Do().
+		ExpectBody(xml.Header + "<root><user><name>a</name><note></note><tags></tags></user><user><name>b</name><note></note><tags></tags></user></root>")
+	client.GET("/ints.xml").Do().
+		ExpectBody(xml.Header + "<root><item>1</item><item>2</item></root>")
+
+	res := client.GET("/pack").Do().ExpectHeader("Content-Type", ContentTypeMsgPack)
+	tt.Equal([]byte{0x84, 0xa1, 'a', 0x01, 0xa1, 'b', 0x92, 0xc3, 0xc0, 0xa1, 'c', 0xa1, 'x', 0xa1, 'd', 0xd1, 0xfe, 0xd4}, res.Body.Bytes())
+
+	client.GET("/export").Do().
+		ExpectHeader("Content-Type", ContentTypeCSV).
+		ExpectBody("name,id\n\"a,b\",1\nc,2\n")
+
+	res = client.GET("/stream").Do().ExpectHeader("Content-Type", ContentTypeNDJSON)
+	lines := bytes.Split(bytes.TrimSpace(res.Body.Bytes()), []byte("\n"))
+	tt.Equal(3, len(lines))
+	for i := range lines {
+		tt.Equal(`{"i":`+strconv.Itoa(i+1)+`}`, string(lines[i]))
+	}
+}
@@ -0,0 +1,453 @@
+package openapi
+
+import (
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/ztype"
+)
+
+type (
+	// Config configuration
+	Config struct {
+		// Operations overrides the inferred documentation of a route,
+		// keyed by method and route path, e.g. "GET /user/:id"
+		Operations  map[string]Operation
+		Title       string
+		Version     string
+		Description string
+		// UIPath serves a Swagger UI page for the document when set
+		UIPath string
+		// Servers lists the base URLs of the API
+		Servers []string
+		// Exclude skips routes equal to or under any of the paths, e.g. /doc
+		// skips /doc and /doc/json but not /documents
+		Exclude []string
+	}
+	// Operation describes a single route, Request and Response accept
+	// a value or a reflect.Type used to build the schemas
+	Operation struct {
+		Request     interface{}
+		Response    interface{}
+		Summary     string
+		Description string
+		Tags        []string
+		Deprecated  bool
+		Hidden      bool
+	}
+)
+
+// Version of the OpenAPI specification generated
+const Version = "3.1.0"
+
+var (
+	contextType   = reflect.TypeOf(&znet.Context{})
+	apiDataType   = reflect.TypeOf(znet.ApiData{})
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	namedGroup    = regexp.MustCompile(`\(\?P?<(\w+)>([^)]*)\)`)
+	bodyMethods   = map[string]bool{http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true}
+	anyMethods    = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	formMediaType = "application/x-www-form-urlencoded"
+)
+
+func newConfig(opt ...func(conf *Config)) *Config {
+	conf := &Config{
+		Title:   "API",
+		Version: "1.0.0",
+	}
+	for _, f := range opt {
+		f(conf)
+	}
+	return conf
+}
+
+// Generate builds the OpenAPI document of all routes registered on the engine
+func Generate(e *znet.Engine, opt ...func(conf *Config)) ztype.Map {
+	return generate(e, newConfig(opt...))
+}
+
+func generate(e *znet.Engine, conf *Config) ztype.Map {
+	s := newSchemas()
+	paths := ztype.Map{}
+	trees := e.GetTrees()
+
+	for method, tree := range trees {
+		for _, node := range tree.Nodes() {
+			route := node.Path()
+			if excluded(conf.Exclude, route) {
+				continue
+			}
+			methods := []string{method}
+			if method == "ANY" {
+				methods = methods[:0]
+				for _, m := range anyMethods {
+					if _, ok := trees[m]; !ok || !hasRoute(trees[m], route) {
+						methods = append(methods, m)
+					}
+				}
+			}
+			for _, m := range methods {
+				op, ok := conf.Operations[m+" "+route]
+				if !ok {
+					op = conf.Operations[method+" "+route]
+				}
+				if op.Hidden {
+					continue
+				}
+				p, params := convertPath(route)
+				item, _ := paths[p].(ztype.Map)
+				if item == nil {
+					item = ztype.Map{}
+					paths[p] = item
+				}
+				item[strings.ToLower(m)] = operation(s, m, node.Action(), op, params)
+			}
+		}
+	}
+
+	doc := ztype.Map{
+		"openapi": Version,
+		"info": ztype.Map{
+			"title":       conf.Title,
+			"version":     conf.Version,
+			"description": conf.Description,
+		},
+		"paths": paths,
+	}
+	if len(conf.Servers) > 0 {
+		servers := make([]ztype.Map, 0, len(conf.Servers))
+		for _, url := range conf.Servers {
+			servers = append(servers, ztype.Map{"url": url})
+		}
+		doc["servers"] = servers
+	}
+	if len(s.components) > 0 {
+		doc["components"] = ztype.Map{"schemas": s.components}
+	}
+	return doc
+}
+
+// New returns a handler serving the OpenAPI document as JSON,
+// the document is generated on the first request so every route is included
+func New(e *znet.Engine, opt ...func(conf *Config)) znet.HandlerFunc {
+	return handler(e, newConfig(opt...))
+}
+
+func handler(e *znet.Engine, conf *Config) znet.HandlerFunc {
+	var (
+		once sync.Once
+		doc  ztype.Map
+	)
+	return func(c *znet.Context) {
+		once.Do(func() {
+			doc = generate(e, conf)
+		})
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// Register serves the OpenAPI document on the path, and the Swagger UI page
+// on Config.UIPath when set, both routes are left out of the document
+func Register(e *znet.Engine, path string, opt ...func(conf *Config)) {
+	conf := newConfig(opt...)
+	conf.Exclude = append(conf.Exclude, path)
+	if conf.UIPath != "" {
+		conf.Exclude = append(conf.Exclude, conf.UIPath)
+	}
+
+	e.GET(path, handler(e, conf))
+	if conf.UIPath != "" {
+		page := strings.Replace(uiTemplate, "{{title}}", html.EscapeString(conf.Title), 1)
+		page = strings.Replace(page, "{{url}}", template.JSEscapeString(path), 1)
+		e.GET(conf.UIPath, func(c *znet.Context) {
+			c.HTML(http.StatusOK, page)
+		})
+	}
+}
+
+func excluded(prefixes []string, route string) bool {
+	for i := range prefixes {
+		prefix := prefixes[i]
+		if prefix == "" || !strings.HasPrefix(route, prefix) {
+			continue
+		}
+		if len(route) == len(prefix) || strings.HasSuffix(prefix, "/") || route[len(prefix)] == '/' {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRoute(t *znet.Tree, route string) bool {
+	for _, node := range t.Nodes() {
+		if node.Path() == route {
+			return true
+		}
+	}
+	return false
+}
+
+type pathParam struct {
+	name    string
+	pattern string
+	typ     string
+}
+
+// convertPath turns a znet route into an OpenAPI path template and its parameters
+func convertPath(route string) (string, []pathParam) {
+	var params []pathParam
+	segments := strings.Split(route, "/")
+	for i, seg := range segments {
+		switch {
+		case seg == "":
+		case seg[0] == ':':
+			name := seg[1:]
+			if name == "full" || name == "" {
+				name = "*"
+			}
+			params = append(params, pathParam{name: name, pattern: paramPattern(name, "")})
+			segments[i] = "{" + name + "}"
+		case seg[0] == '*':
+			params = append(params, pathParam{name: "*", pattern: ".*"})
+			segments[i] = "{*}"
+		case strings.Contains(seg, "{") && strings.Contains(seg, "}"):
+			var b strings.Builder
+			for {
+				open := strings.IndexByte(seg, '{')
+				end := strings.IndexByte(seg, '}')
+				if open == -1 || end < open {
+					b.WriteString(seg)
+					break
+				}
+				name, expr := seg[open+1:end], ""
+				if idx := strings.IndexByte(name, ':'); idx >= 0 {
+					name, expr = name[:idx], name[idx+1:]
+				}
+				if name == "full" {
+					name = "*"
+				}
+				param := pathParam{name: name, pattern: paramPattern(name, expr)}
+				if t, ok := znet.GetParamType(expr); ok {
+					param.typ, param.pattern = expr, t.Pattern
+				}
+				params = append(params, param)
+				b.WriteString(seg[:open] + "{" + name + "}")
+				seg = seg[end+1:]
+			}
+			segments[i] = b.String()
+		case strings.Contains(seg, "("):
+			segments[i] = namedGroup.ReplaceAllStringFunc(seg, func(group string) string {
+				m := namedGroup.FindStringSubmatch(group)
+				params = append(params, pathParam{name: m[1], pattern: m[2]})
+				return "{" + m[1] + "}"
+			})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func paramPattern(name, expr string) string {
+	if expr != "" {
+		return expr
+	}
+	switch name {
+	case "id":
+		return `[\d]+`
+	case "*":
+		return ".*"
+	}
+	return ""
+}
+
+// paramSchema documents a path parameter, typed parameters map to their JSON schema types
+func paramSchema(p pathParam) ztype.Map {
+	switch p.typ {
+	case "int":
+		return ztype.Map{"type": "integer", "format": "int64"}
+	case "uint":
+		return ztype.Map{"type": "integer", "minimum": 0}
+	case "float":
+		return ztype.Map{"type": "number"}
+	case "bool":
+		return ztype.Map{"type": "boolean"}
+	case "uuid":
+		return ztype.Map{"type": "string", "format": "uuid"}
+	case "date":
+		return ztype.Map{"type": "string", "format": "date"}
+	}
+	if isDigitPattern(p.pattern) {
+		return ztype.Map{"type": "integer"}
+	}
+	schema := ztype.Map{"type": "string"}
+	if p.pattern != "" && p.pattern != ".*" {
+		schema["pattern"] = "^" + p.pattern + "$"
+	}
+	return schema
+}
+
+// operation builds the documentation of a route from its handler signature
+func operation(s *schemas, method string, action znet.Handler, op Operation, params []pathParam) ztype.Map {
+	var request, response reflect.Type
+	if action != nil {
+		request, response = inspect(reflect.TypeOf(action))
+	}
+	if op.Request != nil {
+		request = typeOf(op.Request)
+	}
+	if op.Response != nil {
+		response = typeOf(op.Response)
+	}
+
+	o := ztype.Map{}
+	if op.Summary != "" {
+		o["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		o["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		o["tags"] = op.Tags
+	}
+	if op.Deprecated {
+		o["deprecated"] = true
+	}
+
+	parameters := make([]ztype.Map, 0, len(params))
+	names := make(map[string]struct{}, len(params))
+	for _, p := range params {
+		names[p.name] = struct{}{}
+		schema := paramSchema(p)
+		parameters = append(parameters, ztype.Map{
+			"name":     p.name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+
+	if request != nil {
+		if bodyMethods[method] {
+			schema := s.of(request)
+			o["requestBody"] = ztype.Map{
+				"required": true,
+				"content": ztype.Map{
+					"application/json": ztype.Map{"schema": schema},
+					formMediaType:      ztype.Map{"schema": schema},
+				},
+			}
+		} else {
+			obj := s.object(request)
+			required := make(map[string]bool)
+			for _, name := range requiredOf(obj) {
+				required[name] = true
+			}
+			properties, _ := obj["properties"].(ztype.Map)
+			fieldNames, _ := s.fields(request)
+			for _, name := range fieldNames {
+				if _, ok := names[name]; ok {
+					continue
+				}
+				schema, ok := properties[name]
+				if !ok {
+					continue
+				}
+				param := ztype.Map{"name": name, "in": "query", "schema": schema}
+				if required[name] {
+					param["required"] = true
+				}
+				parameters = append(parameters, param)
+			}
+		}
+	}
+	if len(parameters) > 0 {
+		o["parameters"] = parameters
+	}
+
+	data := ztype.Map{}
+	if response != nil {
+		data = s.of(response)
+	}
+	o["responses"] = ztype.Map{
+		"200": ztype.Map{
+			"description": http.StatusText(http.StatusOK),
+			"content": ztype.Map{
+				"application/json": ztype.Map{
+					"schema": ztype.Map{
+						"type": "object",
+						"properties": ztype.Map{
+							"code": ztype.Map{"type": "integer"},
+							"msg":  ztype.Map{"type": "string"},
+							"data": data,
+						},
+					},
+				},
+			},
+		},
+	}
+	return o
+}
+
+// inspect finds the request struct among the handler arguments and the
+// data type among its results, handlers invoked through zdi declare both
+func inspect(t reflect.Type) (request, response reflect.Type) {
+	if t.Kind() != reflect.Func {
+		return
+	}
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if in == contextType {
+			continue
+		}
+		if isStruct(in) {
+			request = in
+			break
+		}
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		out := t.Out(i)
+		if out.Implements(errorType) || out == apiDataType {
+			continue
+		}
+		switch out.Kind() {
+		case reflect.Interface, reflect.Int, reflect.Int32, reflect.Uint, reflect.String:
+			continue
+		}
+		response = out
+		break
+	}
+	return
+}
+
+func isStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+func typeOf(v interface{}) reflect.Type {
+	if t, ok := v.(reflect.Type); ok {
+		return t
+	}
+	return reflect.TypeOf(v)
+}
+
+const uiTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{title}}</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>window.ui = SwaggerUIBundle({url: "{{url}}", dom_id: "#swagger-ui"});</script>
+</body>
+</html>`
@@ -0,0 +1,192 @@
+package openapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/zjson"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/openapi"
+	"github.com/sohaha/zlsgo/zvalid"
+)
+
+type (
+	createUser struct {
+		Name  string   `json:"name"`
+		Email string   `json:"email"`
+		IP    string   `json:"ip"`
+		Tags  []string `json:"tags"`
+		Age   int      `json:"age"`
+	}
+	user struct {
+		Created time.Time `json:"created"`
+		Profile *profile  `json:"profile"`
+		Name    string    `json:"name"`
+		ID      int64     `json:"id"`
+	}
+	profile struct {
+		Bio string `json:"bio"`
+	}
+	listUser struct {
+		Keyword string `json:"keyword"`
+		Page    int    `json:"page"`
+	}
+	userController struct{}
+	Audit          struct {
+		A string `json:"a"`
+		B string `json:"b"`
+		C string `json:"c"`
+		D string `json:"d"`
+		E string `json:"e"`
+		F string `json:"f"`
+	}
+	auditedUser struct {
+		Audit
+		Name string `json:"name"`
+	}
+)
+
+func (auditedUser) Rules() map[string]zvalid.Engine {
+	rules := map[string]zvalid.Engine{}
+	for _, name := range []string{"name", "f", "e", "d", "c", "b", "a"} {
+		rules[name] = zvalid.New().Required()
+	}
+	return rules
+}
+
+func (createUser) Rules() map[string]zvalid.Engine {
+	return map[string]zvalid.Engine{
+		"name":  zvalid.New().Required().MinLength(2).MaxLength(20),
+		"email": zvalid.New().Required().IsMail(),
+		"ip":    zvalid.New().IsIP(),
+		"age":   zvalid.New().MinInt(18),
+	}
+}
+
+func (listUser) Rules() map[string]zvalid.Engine {
+	return map[string]zvalid.Engine{
+		"page": zvalid.New().Required(),
+	}
+}
+
+func (*userController) GetInfo(c *znet.Context) (*user, error) {
+	return &user{}, nil
+}
+
+func newEngine(t *testing.T) *znet.Engine {
+	r := znet.New("openapi-" + t.Name())
+	r.SetMode(znet.QuietMode)
+	return r
+}
+
+func TestGenerate(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := newEngine(t)
+
+	r.POST("/users", func(c *znet.Context, req *createUser) (*user, error) {
+		return &user{}, nil
+	})
+	r.GET("/users", func(c *znet.Context, req listUser) ([]user, error) {
+		return nil, nil
+	})
+	r.GET("/users/:id", func(c *znet.Context) {})
+	r.GET(`/posts/{slug:[a-z-]+}`, func(c *znet.Context) {})
+	r.GET(`/files/:name/*`, func(c *znet.Context) {})
+	r.GET(`/orders/{no:uuid}`, func(c *znet.Context) {})
+	r.DELETE("/internal/cache", func(c *znet.Context) {})
+	r.GET("/internals", func(c *znet.Context) {})
+	_ = r.BindStruct("/ctl", &userController{})
+
+	doc := zjson.Parse(zjson.Stringify(openapi.Generate(r, func(conf *openapi.Config) {
+		conf.Title = "Demo"
+		conf.Servers = []string{"https://api.example.com"}
+		conf.Exclude = []string{"/internal"}
+		conf.Operations = map[string]openapi.Operation{
+			"GET /users/:id": {Summary: "Get user", Tags: []string{"user"}, Response: user{}},
+		}
+	})))
+
+	tt.Equal(openapi.Version, doc.Get("openapi").String())
+	tt.Equal("Demo", doc.Get("info.title").String())
+	tt.Equal("https://api.example.com", doc.Get("servers.0.url").String())
+	tt.EqualFalse(doc.Get("paths./internal/cache").Exists())
+	tt.EqualTrue(doc.Get("paths./internals.get").Exists())
+
+	body := doc.Get(`paths./users.post.requestBody.content.application/json.schema.$ref`).String()
+	tt.Equal("#/components/schemas/createUser", body)
+	schema := doc.Get("components.schemas.createUser")
+	tt.Equal([]string{"name", "email"}, schema.Get("required").SliceString())
+	tt.Equal(2, schema.Get("properties.name.minLength").Int())
+	tt.Equal(20, schema.Get("properties.name.maxLength").Int())
+	tt.Equal("email", schema.Get("properties.email.format").String())
+	tt.Equal("ipv4", schema.Get("properties.ip.anyOf.0.format").String())
+	tt.Equal("ipv6", schema.Get("properties.ip.anyOf.1.format").String())
+	tt.Equal(18, schema.Get("properties.age.minimum").Int())
+	tt.Equal("array", schema.Get("properties.tags.type").String())
+
+	res := doc.Get(`paths./users.post.responses.200.content.application/json.schema.properties.data.$ref`).String()
+	tt.Equal("#/components/schemas/user", res)
+	tt.Equal("date-time", doc.Get("components.schemas.user.properties.created.format").String())
+	tt.Equal("#/components/schemas/profile", doc.Get("components.schemas.user.properties.profile.$ref").String())
+
+	query := doc.Get("paths./users.get.parameters")
+	tt.Equal(2, len(query.Array()))
+	tt.Equal("query", query.Get("0.in").String())
+	tt.Equal("array", doc.Get(`paths./users.get.responses.200.content.application/json.schema.properties.data.type`).String())
+
+	info := doc.Get("paths./users/{id}.get")
+	tt.Equal("Get user", info.Get("summary").String())
+	tt.Equal("id", info.Get("parameters.0.name").String())
+	tt.Equal("integer", info.Get("parameters.0.schema.type").String())
+	tt.EqualTrue(info.Get("parameters.0.required").Bool())
+
+	slug := doc.Get("paths./posts/{slug}.get.parameters.0.schema.pattern").String()
+	tt.Equal("^[a-z-]+$", slug)
+	tt.Equal("uuid", doc.Get("paths./orders/{no}.get.parameters.0.schema.format").String())
+	tt.EqualTrue(doc.Get("paths./files/{name}/{\\*}.get").Exists())
+	tt.EqualTrue(doc.Get("paths./ctl/info.get").Exists())
+}
+
+func TestEmbeddedOrder(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := newEngine(t)
+	r.POST("/audited", func(c *znet.Context, req auditedUser) error {
+		return nil
+	})
+
+	for i := 0; i < 10; i++ {
+		doc := zjson.Parse(zjson.Stringify(openapi.Generate(r)))
+		tt.Equal([]string{"a", "b", "c", "d", "e", "f", "name"},
+			doc.Get("components.schemas.auditedUser.required").SliceString())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := newEngine(t)
+
+	openapi.Register(r, "/openapi.json", func(conf *openapi.Config) {
+		conf.UIPath = "/docs"
+		conf.Title = `<script>"api"</script>`
+	})
+	r.GET("/ping", func(c *znet.Context) {})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	r.ServeHTTP(w, req)
+	tt.Equal(http.StatusOK, w.Code)
+	doc := zjson.ParseBytes(w.Body.Bytes())
+	tt.EqualTrue(doc.Get("paths./ping.get").Exists())
+	tt.EqualFalse(doc.Get("paths./openapi\\.json").Exists())
+	tt.EqualFalse(doc.Get("paths./docs").Exists())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/docs", nil)
+	r.ServeHTTP(w, req)
+	tt.Equal(http.StatusOK, w.Code)
+	tt.Contains(`url: "/openapi.json"`, w.Body.String())
+	tt.Contains(`<title>&lt;script&gt;&#34;api&#34;&lt;/script&gt;</title>`, w.Body.String())
+}
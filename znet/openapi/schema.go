@@ -0,0 +1,252 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sohaha/zlsgo/zreflect"
+	"github.com/sohaha/zlsgo/ztype"
+	"github.com/sohaha/zlsgo/zvalid"
+)
+
+// RuleProvider is implemented by request structs that declare their validation rules,
+// the rules are documented as schema constraints of the struct fields.
+type RuleProvider interface {
+	Rules() map[string]zvalid.Engine
+}
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	ruleProviderType = reflect.TypeOf((*RuleProvider)(nil)).Elem()
+)
+
+// schemas collects named struct schemas into the components section.
+type schemas struct {
+	components ztype.Map
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: ztype.Map{},
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// of returns the schema of a Go type, named structs are referenced from components.
+func (s *schemas) of(t reflect.Type) ztype.Map {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return ztype.Map{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return ztype.Map{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return ztype.Map{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ztype.Map{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return ztype.Map{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return ztype.Map{"type": "number", "format": "double"}
+	case reflect.String:
+		return ztype.Map{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ztype.Map{"type": "string", "format": "byte"}
+		}
+		return ztype.Map{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return ztype.Map{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return ztype.Map{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return ztype.Map{"$ref": "#/components/schemas/" + s.register(t)}
+	default:
+		return ztype.Map{}
+	}
+}
+
+// register adds a named struct to the components and returns its name.
+func (s *schemas) register(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, exists := s.components[name]; exists {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	s.names[t] = name
+	s.components[name] = ztype.Map{}
+	s.components[name] = s.object(t)
+	return name
+}
+
+// object builds an object schema from the exported fields of a struct.
+func (s *schemas) object(t reflect.Type) ztype.Map {
+	obj, _ := s.fieldsObject(t)
+	return obj
+}
+
+// fieldsObject builds the object schema of a struct and returns its property names in field order.
+func (s *schemas) fieldsObject(t reflect.Type) (ztype.Map, []string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	properties := ztype.Map{}
+	obj := ztype.Map{"type": "object", "properties": properties}
+	order := make([]string, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _ := zreflect.GetStructTag(field)
+		if name == "" {
+			continue
+		}
+		if field.Anonymous && !hasTag(field) {
+			embedded, embeddedOrder := s.fieldsObject(field.Type)
+			embeddedProperties := embedded["properties"].(ztype.Map)
+			for _, k := range embeddedOrder {
+				properties[k] = embeddedProperties[k]
+				order = append(order, k)
+			}
+			if required, ok := embedded["required"].([]string); ok {
+				obj["required"] = append(requiredOf(obj), required...)
+			}
+			continue
+		}
+		properties[name] = s.of(field.Type)
+		order = append(order, name)
+	}
+
+	if reflect.PtrTo(t).Implements(ruleProviderType) {
+		if rules := reflect.New(t).Interface().(RuleProvider).Rules(); len(rules) > 0 {
+			applyRules(obj, order, rules)
+		}
+	}
+	return obj, order
+}
+
+// fields lists the exported fields of a struct with their bound names, used for query parameters.
+func (s *schemas) fields(t reflect.Type) (names []string, types []reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _ := zreflect.GetStructTag(field)
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+		types = append(types, field.Type)
+	}
+	return
+}
+
+// hasTag reports whether a field has an explicit binding tag.
+func hasTag(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup(zreflect.Tag)
+	if !ok {
+		_, ok = field.Tag.Lookup("json")
+	}
+	return ok
+}
+
+func requiredOf(obj ztype.Map) []string {
+	required, _ := obj["required"].([]string)
+	return required
+}
+
+// applyRules documents zvalid rules as constraints of the object properties, in field order.
+func applyRules(obj ztype.Map, order []string, rules map[string]zvalid.Engine) {
+	properties, _ := obj["properties"].(ztype.Map)
+	for _, name := range order {
+		engine, ok := rules[name]
+		if !ok {
+			continue
+		}
+		prop, ok := properties[name].(ztype.Map)
+		if !ok {
+			continue
+		}
+		if _, isRef := prop["$ref"]; isRef {
+			prop = ztype.Map{"allOf": []interface{}{prop}}
+			properties[name] = prop
+		}
+		for _, rule := range engine.Rules() {
+			if rule.Name == "required" {
+				required := requiredOf(obj)
+				exists := false
+				for i := range required {
+					if required[i] == name {
+						exists = true
+						break
+					}
+				}
+				if !exists {
+					obj["required"] = append(required, name)
+				}
+				continue
+			}
+			applyRule(prop, rule)
+		}
+	}
+}
+
+// applyRule maps a single zvalid rule onto a JSON schema keyword.
+func applyRule(prop ztype.Map, rule zvalid.Rule) {
+	var arg interface{}
+	if len(rule.Args) > 0 {
+		arg = rule.Args[0]
+	}
+	switch rule.Name {
+	case "minLength":
+		prop["minLength"] = arg
+	case "maxLength":
+		prop["maxLength"] = arg
+	case "min":
+		prop["minimum"] = arg
+	case "max":
+		prop["maximum"] = arg
+	case "enum":
+		prop["enum"] = arg
+	case "regex":
+		prop["pattern"] = arg
+	case "mail":
+		prop["format"] = "email"
+	case "url":
+		prop["format"] = "uri"
+	case "ip":
+		prop["anyOf"] = []interface{}{ztype.Map{"format": "ipv4"}, ztype.Map{"format": "ipv6"}}
+	case "integer":
+		if prop["type"] == "string" {
+			prop["pattern"] = `^-?\d+$`
+		}
+	}
+}
+
+// isDigitPattern reports whether the segment expression only matches digits.
+func isDigitPattern(expr string) bool {
+	switch strings.TrimSpace(expr) {
+	case `[\d]+`, `\d+`, `[0-9]+`:
+		return true
+	}
+	return false
+}
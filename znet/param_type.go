@@ -0,0 +1,120 @@
+package znet
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParamType describes a named route parameter constraint used as {name:type}.
+type ParamType struct {
+	// Check optionally validates the value once the pattern matched
+	Check func(value string) bool
+	// Pattern is the regular expression a path segment must match
+	Pattern string
+}
+
+var (
+	// ErrParamTypeInvalid is returned when a parameter type has an empty or invalid pattern,
+	// patterns must not contain capturing groups
+	ErrParamTypeInvalid = errors.New("invalid route parameter type")
+
+	paramTypes = struct {
+		types map[string]ParamType
+		mu    sync.RWMutex
+	}{
+		types: map[string]ParamType{
+			"int": {Pattern: `-?\d+`, Check: func(value string) bool {
+				_, err := strconv.ParseInt(value, 10, 64)
+				return err == nil
+			}},
+			"uint": {Pattern: `\d+`, Check: func(value string) bool {
+				_, err := strconv.ParseUint(value, 10, 64)
+				return err == nil
+			}},
+			"float": {Pattern: `-?\d+(?:\.\d+)?`},
+			"bool":  {Pattern: `(?:true|false|1|0)`},
+			"alpha": {Pattern: `[a-zA-Z]+`},
+			"alnum": {Pattern: `[a-zA-Z0-9]+`},
+			"slug":  {Pattern: `[a-z0-9]+(?:-[a-z0-9]+)*`},
+			"uuid":  {Pattern: `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`},
+			"date": {Pattern: `\d{4}-\d{2}-\d{2}`, Check: func(value string) bool {
+				_, err := time.Parse("2006-01-02", value)
+				return err == nil
+			}},
+		},
+	}
+
+	// paramChecks caches the value checks of each route path
+	paramChecks sync.Map
+)
+
+// RegisterParamType registers a route parameter type, routes declared as {name:type}
+// only match when the segment satisfies its pattern and check.
+// Types must be registered before the routes using them are matched.
+func RegisterParamType(name string, pattern string, check ...func(value string) bool) error {
+	if name == "" || pattern == "" {
+		return ErrParamTypeInvalid
+	}
+	r, err := regexp.Compile(pattern)
+	if err != nil || r.NumSubexp() > 0 {
+		return ErrParamTypeInvalid
+	}
+
+	t := ParamType{Pattern: pattern}
+	if len(check) > 0 {
+		t.Check = check[0]
+	}
+
+	paramTypes.mu.Lock()
+	paramTypes.types[name] = t
+	paramTypes.mu.Unlock()
+	return nil
+}
+
+// GetParamType returns the registered route parameter type by name
+func GetParamType(name string) (ParamType, bool) {
+	paramTypes.mu.RLock()
+	t, ok := paramTypes.types[name]
+	paramTypes.mu.RUnlock()
+	return t, ok
+}
+
+// checkParamTypes runs the value checks of the typed parameters declared in path
+func checkParamTypes(path string, params map[string]string) bool {
+	var checks map[string]func(string) bool
+	if v, ok := paramChecks.Load(path); ok {
+		checks = v.(map[string]func(string) bool)
+	} else {
+		checks = make(map[string]func(string) bool)
+		for s := path; ; {
+			open := strings.IndexByte(s, '{')
+			if open == -1 {
+				break
+			}
+			end := strings.IndexByte(s[open:], '}')
+			if end == -1 {
+				break
+			}
+			name, typ := s[open+1:open+end], ""
+			if idx := strings.IndexByte(name, ':'); idx >= 0 {
+				name, typ = name[:idx], name[idx+1:]
+			}
+			if t, ok := GetParamType(typ); ok && t.Check != nil {
+				checks[name] = t.Check
+			}
+			s = s[open+end+1:]
+		}
+		paramChecks.Store(path, checks)
+	}
+
+	for name, check := range checks {
+		if !check(params[name]) {
+			return false
+		}
+	}
+	return true
+}
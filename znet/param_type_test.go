@@ -0,0 +1,66 @@
+package znet
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+)
+
+func TestParamType(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("param-type-test")
+	r.SetMode(QuietMode)
+
+	tt.NoError(RegisterParamType("lower", `[a-z]+`, func(value string) bool {
+		return len(value) <= 5
+	}))
+	tt.Equal(ErrParamTypeInvalid, RegisterParamType("bad", `([a-z]+)`))
+	tt.Equal(ErrParamTypeInvalid, RegisterParamType("", `\d+`))
+
+	r.GET("/user/{id:int}", func(c *Context) {
+		c.String(200, "int:"+c.GetParam("id"))
+	})
+	r.GET("/user/{name:alpha}", func(c *Context) {
+		c.String(200, "alpha:"+c.GetParam("name"))
+	})
+	r.GET("/item/{uid:uuid}", func(c *Context) {
+		c.String(200, c.GetParam("uid"))
+	})
+	r.GET("/archive/{day:date}.html", func(c *Context) {
+		c.String(200, c.GetParam("day"))
+	})
+	r.GET("/tag/{tag:lower}", func(c *Context) {
+		c.String(200, c.GetParam("tag"))
+	})
+
+	for path, expected := range map[string]string{
+		"/user/42":                   "int:42",
+		"/user/-7":                   "int:-7",
+		"/user/bob":                  "alpha:bob",
+		"/user/bob1":                 "",
+		"/user/99999999999999999999": "",
+		"/item/6ba7b810-9dad-11d1-80b4-00c04fd430c8": "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"/item/6ba7b810":           "",
+		"/archive/2024-02-29.html": "2024-02-29",
+		"/archive/2023-02-29.html": "",
+		"/tag/go":                  "go",
+		"/tag/golang":              "",
+	} {
+		w := request(r, "GET", path, nil)
+		if expected == "" {
+			tt.Equal(http.StatusNotFound, w.Code)
+			continue
+		}
+		tt.Equal(http.StatusOK, w.Code)
+		tt.Equal(expected, w.Body.String())
+	}
+
+	r.GETAndName("/post/{id:int}", func(c *Context) {}, "post")
+	u, err := r.GenerateURL("GET", "post", map[string]string{"id": "12"})
+	tt.NoError(err)
+	tt.Equal("/post/12", u)
+	_, err = r.GenerateURL("GET", "post", map[string]string{"id": strings.Repeat("9", 30)})
+	tt.Equal(ErrGenerateParameters, err)
+}
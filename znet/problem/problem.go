@@ -0,0 +1,266 @@
+// Package problem renders handler errors as RFC 7807 problem details for znet
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/sohaha/zlsgo/zerror"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zutil"
+	"github.com/sohaha/zlsgo/zvalid"
+)
+
+type (
+	// Problem is an RFC 7807 problem detail, it can be returned by handlers as an error
+	Problem struct {
+		err error
+		// Extensions are additional members of the problem
+		Extensions map[string]interface{} `json:"-"`
+		Type       string                 `json:"type"`
+		Title      string                 `json:"title"`
+		Detail     string                 `json:"detail,omitempty"`
+		Instance   string                 `json:"instance,omitempty"`
+		// Errors are the validation errors of the request fields
+		Errors zvalid.FieldErrors `json:"errors,omitempty"`
+		Status int                `json:"status"`
+	}
+	// Config configuration
+	Config struct {
+		// Codes maps zerror codes to HTTP status codes, the outermost code in the chain is used
+		Codes map[zerror.ErrCode]int
+		// Tags maps zerror tags to HTTP status codes, merged into the default mapping
+		Tags map[zerror.TagKind]int
+		// Template renders the problem for clients preferring text/html, it is executed
+		// with *Problem, default is DefaultTemplate, nil disables the HTML response
+		Template *template.Template
+		// Convert adjusts the problem built from err before it is rendered
+		Convert func(c *znet.Context, err error, p *Problem)
+		// TypeBase is prefixed to the status code as the type URI, default type is about:blank
+		TypeBase string
+		// ExposeInternal shows the detail of 5xx errors, which is only shown in debug mode by default
+		ExposeInternal bool
+	}
+)
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// DefaultTemplate is the HTML rendering of a problem
+var DefaultTemplate = template.Must(template.New("problem").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{ .Status }} {{ .Title }}</title></head>
+<body><h1>{{ .Title }}</h1>{{ with .Detail }}<p>{{ . }}</p>{{ end }}{{ with .Errors }}
+<ul>{{ range . }}<li>{{ .Field }}: {{ .Message }}</li>{{ end }}</ul>{{ end }}
+</body></html>`))
+
+var (
+	defaultTags = map[zerror.TagKind]int{
+		zerror.Internal:         http.StatusInternalServerError,
+		zerror.Cancelled:        499,
+		zerror.InvalidInput:     http.StatusBadRequest,
+		zerror.NotFound:         http.StatusNotFound,
+		zerror.PermissionDenied: http.StatusForbidden,
+		zerror.Unauthorized:     http.StatusUnauthorized,
+	}
+	titles = map[int]string{
+		499: "Client Closed Request",
+	}
+)
+
+// Status returns a problem with the status code, detail is optional
+func Status(status int, detail ...string) *Problem {
+	p := &Problem{Status: status, Title: title(status)}
+	if len(detail) > 0 {
+		p.Detail = detail[0]
+	}
+	return p
+}
+
+// With sets an extension member of the problem
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// clone copies the problem so that sentinel problems are never changed by a request
+func (p *Problem) clone() *Problem {
+	cp := *p
+	if p.Extensions != nil {
+		cp.Extensions = make(map[string]interface{}, len(p.Extensions))
+		for k, v := range p.Extensions {
+			cp.Extensions[k] = v
+		}
+	}
+	if p.Errors != nil {
+		cp.Errors = append(zvalid.FieldErrors(nil), p.Errors...)
+	}
+	return &cp
+}
+
+// Error returns the detail or the title of the problem
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	if p.Title != "" {
+		return p.Title
+	}
+	return title(p.Status)
+}
+
+// Unwrap returns the error the problem was built from
+func (p *Problem) Unwrap() error {
+	return p.err
+}
+
+// MarshalJSON encodes the problem with its extensions as top-level members
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	if len(p.Errors) > 0 {
+		m["errors"] = p.Errors
+	}
+	return json.Marshal(m)
+}
+
+// New returns a middleware rendering the errors returned by the following handlers as problems
+func New(opt ...func(conf *Config)) znet.Handler {
+	return znet.RewriteErrorHandler(Handler(opt...))
+}
+
+// Handler returns an error handler rendering err as a problem, it can also be
+// used with Engine.PanicHandler and znet.Recovery
+func Handler(opt ...func(conf *Config)) znet.ErrHandlerFunc {
+	conf := zutil.Optional(Config{Template: DefaultTemplate}, opt...)
+	tags := make(map[zerror.TagKind]int, len(defaultTags)+len(conf.Tags))
+	for k, v := range defaultTags {
+		tags[k] = v
+	}
+	for k, v := range conf.Tags {
+		tags[k] = v
+	}
+	conf.Tags = tags
+
+	return func(c *znet.Context, err error) {
+		p := conf.build(c, err)
+		if conf.Convert != nil {
+			conf.Convert(c, err, p)
+		}
+		conf.render(c, p)
+	}
+}
+
+func (conf *Config) build(c *znet.Context, err error) *Problem {
+	var p *Problem
+	if target := (*Problem)(nil); errors.As(err, &target) {
+		p = target.clone()
+	} else {
+		p = &Problem{Status: http.StatusInternalServerError, Detail: err.Error(), err: err}
+
+		var fields zvalid.FieldErrors
+		if errors.As(err, &fields) {
+			p.Status = http.StatusUnprocessableEntity
+			p.Errors = fields
+		}
+		if status, ok := conf.Tags[zerror.GetTag(err)]; ok {
+			p.Status = status
+		}
+		for e := err; e != nil; e = errors.Unwrap(e) {
+			if code, ok := zerror.UnwrapCode(e); ok && code != 0 {
+				if status, ok := conf.Codes[code]; ok {
+					p.Status = status
+				}
+				p.With("code", code)
+				break
+			}
+		}
+		if p.Status >= http.StatusInternalServerError && !conf.ExposeInternal && !c.Engine.IsDebug() {
+			p.Detail = ""
+		}
+	}
+
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Title == "" {
+		p.Title = title(p.Status)
+	}
+	if p.Type == "" {
+		if conf.TypeBase != "" {
+			p.Type = conf.TypeBase + strconv.Itoa(p.Status)
+		} else {
+			p.Type = "about:blank"
+		}
+	}
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	return p
+}
+
+func (conf *Config) render(c *znet.Context, p *Problem) {
+	offers := []string{ContentType, "application/json", "text/plain"}
+	if conf.Template != nil {
+		offers = append(offers, "text/html")
+	}
+	c.SetHeader("Vary", "Accept")
+
+	code := int32(p.Status)
+	switch c.Accepts(offers...) {
+	case "text/html":
+		var buf bytes.Buffer
+		if err := conf.Template.Execute(&buf, p); err == nil {
+			c.SetContentType(znet.ContentTypeHTML)
+			c.HTML(code, buf.String())
+			return
+		}
+	case "text/plain":
+		text := strconv.Itoa(p.Status) + " " + p.Title
+		if p.Detail != "" {
+			text += ": " + p.Detail
+		}
+		for _, f := range p.Errors {
+			text += "\n" + f.Field + ": " + f.Message
+		}
+		c.SetContentType(znet.ContentTypePlain)
+		c.String(code, "%s", text)
+		return
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		c.SetContentType(znet.ContentTypePlain)
+		c.String(code, "%s", p.Error())
+		return
+	}
+	c.SetContentType(ContentType)
+	c.Byte(code, b)
+}
+
+func title(status int) string {
+	if t, ok := titles[status]; ok {
+		return t
+	}
+	if t := http.StatusText(status); t != "" {
+		return t
+	}
+	return "Error"
+}
@@ -0,0 +1,126 @@
+package problem_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/zerror"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/problem"
+	"github.com/sohaha/zlsgo/zvalid"
+)
+
+func TestProblem(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := znet.New("problem-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(problem.New(func(conf *problem.Config) {
+		conf.Codes = map[zerror.ErrCode]int{1001: 409}
+		conf.TypeBase = "https://example.com/problems/"
+	}))
+	r.GET("/status", func(c *znet.Context) error {
+		return problem.Status(402, "balance is too low").With("balance", 30)
+	})
+	r.GET("/tag", func(c *znet.Context) error {
+		return zerror.NotFound.Text("user not found")
+	})
+	r.GET("/code", func(c *znet.Context) error {
+		return zerror.Wrap(errors.New("duplicate"), 1001, "user exists", zerror.WrapTag(zerror.InvalidInput))
+	})
+	r.GET("/internal", func(c *znet.Context) error {
+		return errors.New("database password is wrong")
+	})
+	r.POST("/valid", func(c *znet.Context) error {
+		var user struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+		return c.BindValid(&user, map[string]zvalid.Engine{
+			"name": zvalid.New().Required("name is required"),
+			"age":  zvalid.New().MinInt(18, "age must be at least 18"),
+		})
+	})
+
+	client := r.Test(tt)
+
+	client.GET("/status").Do().
+		ExpectStatus(402).
+		ExpectHeader("Content-Type", problem.ContentType).
+		ExpectJSON("type", "https://example.com/problems/402").
+		ExpectJSON("title", "Payment Required").
+		ExpectJSON("status", 402).
+		ExpectJSON("detail", "balance is too low").
+		ExpectJSON("instance", "/status").
+		ExpectJSON("balance", 30)
+
+	client.GET("/tag").Do().ExpectStatus(404).ExpectJSON("detail", "user not found")
+
+	client.GET("/code").Do().ExpectStatus(409).ExpectJSON("code", 1001).ExpectJSON("detail", "user exists")
+
+	res := client.GET("/internal").Do().ExpectStatus(500).ExpectJSON("title", "Internal Server Error")
+	tt.EqualTrue(!res.JSON("detail").Exists())
+
+	client.POST("/valid").JSON(map[string]interface{}{"age": 3}).Do().
+		ExpectStatus(422).
+		ExpectJSON("errors.#", 2).
+		ExpectJSON("errors.0.field", "name").
+		ExpectJSON("errors.1.message", "age must be at least 18")
+
+	client.GET("/tag").Header("Accept", "text/html,*/*;q=0.8").Do().
+		ExpectStatus(404).
+		ExpectHeader("Content-Type", znet.ContentTypeHTML).
+		ExpectBodyContains("<h1>Not Found</h1><p>user not found</p>")
+	client.GET("/tag").Header("Accept", "text/plain").Do().
+		ExpectHeader("Content-Type", znet.ContentTypePlain).
+		ExpectBody("404 Not Found: user not found")
+	client.GET("/tag").Header("Accept", "application/json").Do().
+		ExpectHeader("Content-Type", problem.ContentType).
+		ExpectHeader("Vary", "Accept")
+}
+
+func TestPanicHandler(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := znet.New("problem-panic-test")
+	r.SetMode(znet.QuietMode)
+	r.PanicHandler(problem.Handler(func(conf *problem.Config) {
+		conf.ExposeInternal = true
+		conf.Template = nil
+		conf.Convert = func(c *znet.Context, err error, p *problem.Problem) {
+			p.With("trace_id", "abc")
+		}
+	}))
+	r.GET("/", func(c *znet.Context) {
+		panic("boom")
+	})
+
+	r.Test(tt).GET("/").Header("Accept", "text/html").Do().
+		ExpectStatus(500).
+		ExpectHeader("Content-Type", problem.ContentType).
+		ExpectJSON("detail", "boom").
+		ExpectJSON("trace_id", "abc")
+}
+
+func TestSentinelProblem(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	errGone := problem.Status(404, "gone").With("resource", "user")
+	r := znet.New("problem-sentinel-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(problem.New(func(conf *problem.Config) {
+		conf.Convert = func(c *znet.Context, err error, p *problem.Problem) {
+			p.With("path", c.Request.URL.Path)
+		}
+	}))
+	r.GET("/users", func(c *znet.Context) error {
+		return errGone
+	})
+
+	r.Test(tt).GET("/users").Do().
+		ExpectStatus(404).
+		ExpectJSON("resource", "user").
+		ExpectJSON("path", "/users")
+	tt.Equal(map[string]interface{}{"resource": "user"}, errGone.Extensions)
+}
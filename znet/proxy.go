@@ -0,0 +1,268 @@
+package znet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sohaha/zlsgo/zpool"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// ProxyOption configures the reverse proxy handler
+	ProxyOption struct {
+		// Transport used to reach the upstreams, default is http.DefaultTransport
+		Transport http.RoundTripper
+		// Rewrite allows custom changes to the outgoing request after the defaults were applied
+		Rewrite func(c *Context, out *http.Request)
+		// ModifyResponse allows changes to the upstream response before it is copied
+		ModifyResponse func(res *http.Response) error
+		// Headers are set on the outgoing request, an empty value removes the header
+		Headers map[string]string
+		// StripPrefix is removed from the request path before forwarding
+		StripPrefix string
+		// AddPrefix is prepended to the request path before forwarding
+		AddPrefix string
+		// FailureStatus lists upstream status codes treated as failures,
+		// default is 502, 503 and 504
+		FailureStatus []int
+		// Retries is the number of extra attempts on other upstreams for idempotent requests
+		Retries int
+		// MaxRetryBody is the largest request body buffered to allow retries, default is 1MB
+		MaxRetryBody int64
+		// FlushInterval is passed to httputil.ReverseProxy, event streams are always flushed immediately
+		FlushInterval time.Duration
+		// Strategy used by the balancer to pick an upstream
+		Strategy zpool.BalancerStrategy
+		// PreserveHost keeps the Host header of the incoming request
+		PreserveHost bool
+	}
+	proxyAttempt struct {
+		ctx    *Context
+		target *url.URL
+		err    error
+		key    string
+		last   bool
+	}
+	proxyAttemptKey struct{}
+	proxyWriter     struct {
+		http.ResponseWriter
+		code int
+	}
+)
+
+var (
+	// ErrProxyUpstream is returned when the upstream response is a failure
+	ErrProxyUpstream = errors.New("proxy: upstream failure")
+
+	idempotentMethods = map[string]struct{}{
+		http.MethodGet:     {},
+		http.MethodHead:    {},
+		http.MethodOptions: {},
+		http.MethodTrace:   {},
+		http.MethodPut:     {},
+		http.MethodDelete:  {},
+	}
+)
+
+// Proxy returns a reverse proxy handler forwarding requests to the upstreams of the
+// balancer, the node values are upstream base URLs such as http://10.0.0.1:8080.
+// Failing upstreams are ejected with Balancer.Mark for their cooldown period, and
+// idempotent requests are retried on another upstream. WebSocket upgrades and
+// server-sent events are passed through.
+func Proxy(b *zpool.Balancer[string], opt ...func(o *ProxyOption)) HandlerFunc {
+	o := zutil.Optional(ProxyOption{
+		Transport:     http.DefaultTransport,
+		FailureStatus: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		MaxRetryBody:  1 << 20,
+	}, opt...)
+
+	isFailure := func(code int) bool {
+		for i := range o.FailureStatus {
+			if o.FailureStatus[i] == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	rp := &httputil.ReverseProxy{
+		Transport:     o.Transport,
+		FlushInterval: o.FlushInterval,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			a := pr.In.Context().Value(proxyAttemptKey{}).(*proxyAttempt)
+			p := pr.Out.URL.Path
+			if o.StripPrefix != "" {
+				p = strings.TrimPrefix(p, strings.TrimSuffix(o.StripPrefix, "/"))
+				if p == "" || p[0] != '/' {
+					p = "/" + p
+				}
+			}
+			if o.AddPrefix != "" {
+				p = strings.TrimSuffix(o.AddPrefix, "/") + p
+			}
+			if p != pr.Out.URL.Path {
+				pr.Out.URL.Path, pr.Out.URL.RawPath = p, ""
+			}
+			pr.SetURL(a.target)
+			pr.SetXForwarded()
+			if o.PreserveHost {
+				pr.Out.Host = pr.In.Host
+			}
+			for k, v := range o.Headers {
+				if v == "" {
+					pr.Out.Header.Del(k)
+				} else {
+					pr.Out.Header.Set(k, v)
+				}
+			}
+			if o.Rewrite != nil {
+				o.Rewrite(a.ctx, pr.Out)
+			}
+		},
+		ModifyResponse: func(res *http.Response) error {
+			a := res.Request.Context().Value(proxyAttemptKey{}).(*proxyAttempt)
+			if isFailure(res.StatusCode) {
+				b.Mark(a.key, false)
+				if !a.last {
+					return ErrProxyUpstream
+				}
+			}
+			if o.ModifyResponse != nil {
+				return o.ModifyResponse(res)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			a := r.Context().Value(proxyAttemptKey{}).(*proxyAttempt)
+			if r.Context().Err() != nil {
+				a.err = err
+				return
+			}
+			if err != ErrProxyUpstream {
+				b.Mark(a.key, false)
+			}
+			if !a.last {
+				a.err = err
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+
+	return func(c *Context) {
+		req := c.Request
+		_, retryable := idempotentMethods[req.Method]
+		var body []byte
+		if retryable && o.Retries > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req.ContentLength < 0 || req.ContentLength > o.MaxRetryBody {
+				retryable = false
+			} else {
+				var err error
+				body, err = io.ReadAll(io.LimitReader(req.Body, o.MaxRetryBody+1))
+				if err != nil || int64(len(body)) > o.MaxRetryBody {
+					c.String(http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+					return
+				}
+			}
+		}
+
+		header := c.Writer.Header()
+		for k, v := range c.header {
+			header[k] = append(header[k][:0:0], v...)
+		}
+
+		w := &proxyWriter{ResponseWriter: c.Writer}
+		attempts := 0
+		err := b.Run(func(target string) (bool, error) {
+			u, err := url.Parse(target)
+			if err != nil {
+				return true, err
+			}
+			a := &proxyAttempt{
+				ctx:    c,
+				target: u,
+				key:    balancerKey(b, target),
+				last:   !retryable || attempts >= o.Retries,
+			}
+			attempts++
+			r := req.WithContext(context.WithValue(req.Context(), proxyAttemptKey{}, a))
+			if body != nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				r.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(body)), nil
+				}
+			}
+			rp.ServeHTTP(w, r)
+			if a.err != nil && req.Context().Err() == nil {
+				return true, a.err
+			}
+			return true, nil
+		}, o.Strategy)
+
+		if w.code == 0 {
+			if req.Context().Err() != nil {
+				c.Abort()
+				c.done.Store(true)
+				return
+			}
+			if err == nil {
+				err = ErrProxyUpstream
+			}
+			c.Log.Debug("proxy: ", err)
+			code := http.StatusBadGateway
+			if attempts == 0 {
+				code = http.StatusServiceUnavailable
+			}
+			c.String(int32(code), http.StatusText(code))
+			return
+		}
+		c.Abort(int32(w.code))
+		c.done.Store(true)
+	}
+}
+
+// balancerKey finds the key of the balancer node holding the target
+func balancerKey(b *zpool.Balancer[string], target string) string {
+	if node, _, ok := b.Get(target); ok && node == target {
+		return target
+	}
+	for _, key := range b.Keys() {
+		if node, _, ok := b.Get(key); ok && node == target {
+			return key
+		}
+	}
+	return ""
+}
+
+func (w *proxyWriter) WriteHeader(code int) {
+	if w.code == 0 && (code >= http.StatusOK || code == http.StatusSwitchingProtocols) {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *proxyWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *proxyWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer so the proxy can hijack upgraded connections
+func (w *proxyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,124 @@
+package znet
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/zpool"
+)
+
+func TestProxy(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "good")
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get("X-Gateway") + " " + string(body)))
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	r := New("proxy-test")
+	r.SetMode(QuietMode)
+
+	b := zpool.NewBalancer[string]()
+	_ = b.Add("bad", bad.URL)
+	_ = b.Add("down", down.URL)
+	_ = b.Add("good", good.URL, func(opts *zpool.BalancerNodeOptions) {
+		opts.Weight = 1
+	})
+	r.Any("/api/*", Proxy(b, func(o *ProxyOption) {
+		o.Strategy = zpool.StrategyRoundRobin
+		o.StripPrefix = "/api"
+		o.AddPrefix = "/v2"
+		o.Retries = 2
+		o.Headers = map[string]string{"X-Gateway": "znet", "Cookie": ""}
+	}))
+
+	only := zpool.NewBalancer[string]()
+	_ = only.Add("bad", bad.URL)
+	r.POST("/only", Proxy(only, func(o *ProxyOption) {
+		o.Retries = 3
+	}))
+
+	empty := zpool.NewBalancer[string]()
+	r.GET("/empty", Proxy(empty))
+
+	for i := 0; i < 3; i++ {
+		w := request(r, "PUT", "/api/users?id=1", strings.NewReader("data"))
+		tt.Equal(http.StatusOK, w.Code)
+		tt.Equal("good", w.Header().Get("X-Upstream"))
+		tt.Equal("PUT /v2/users?id=1 znet data", w.Body.String())
+	}
+	info, _ := b.GetNodeInfo("bad")
+	tt.EqualFalse(info.Available)
+	info, _ = b.GetNodeInfo("down")
+	tt.EqualFalse(info.Available)
+
+	w := request(r, "POST", "/only", strings.NewReader("x"))
+	tt.Equal(http.StatusServiceUnavailable, w.Code)
+
+	w = request(r, "GET", "/empty", nil)
+	tt.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
+func TestProxyStream(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	upstream := New("proxy-test-upstream")
+	upstream.SetMode(QuietMode)
+	upstream.GET("/ws", func(c *Context) {
+		ws, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		mt, data, err := ws.ReadMessage()
+		if err == nil {
+			_ = ws.WriteMessage(mt, append([]byte("upstream:"), data...))
+		}
+		_ = ws.Close(CloseNormalClosure, "")
+	})
+	upstream.GET("/events", func(c *Context) {
+		c.SetContentType("text/event-stream")
+		c.Stream(func(w io.Writer) bool {
+			_, _ = w.Write([]byte("data: hello\n\n"))
+			return false
+		})
+	})
+	up := httptest.NewServer(upstream)
+	defer up.Close()
+
+	r := New("proxy-test-stream")
+	r.SetMode(QuietMode)
+	b := zpool.NewBalancer[string]()
+	_ = b.Add("up", up.URL)
+	r.GET("/*", Proxy(b))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := dialWebSocket(t, strings.TrimPrefix(srv.URL, "http://"), "/ws", nil)
+	defer client.conn.Close()
+	tt.Equal(http.StatusSwitchingProtocols, client.resp.StatusCode)
+	client.writeFrame(TextMessage, []byte("hi"), true, false)
+	op, _, payload, err := client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(TextMessage), op)
+	tt.Equal("upstream:hi", string(payload))
+
+	res, err := http.Get(srv.URL + "/events")
+	tt.NoError(err, true)
+	defer res.Body.Close()
+	tt.Equal("text/event-stream", res.Header.Get("Content-Type"))
+	line, _ := bufio.NewReader(res.Body).ReadString('\n')
+	tt.Equal("data: hello\n", line)
+}
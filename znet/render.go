@@ -249,22 +249,17 @@ func (c *Context) SetContent(data *PrevData) {
 	c.mu.Unlock()
 }
 
+// File responds the file with the caching semantics of ServeContent,
+// a precompressed .gz sibling is served when the client accepts gzip
 func (c *Context) File(path string) {
 	path = zfile.RealPath(path)
-	f, err := os.Stat(path)
-	fileExist := err == nil
-	var code int32
-	if fileExist {
-		code = http.StatusOK
-	} else {
-		code = http.StatusNotFound
-	}
-	if fileExist {
-		if !isModified(c, f.ModTime()) {
-			return
-		}
+	if f, err := os.Stat(path); err != nil || f.IsDir() {
+		c.renderProcessing(http.StatusNotFound, &renderFile{Data: path})
+		return
 	}
-	c.renderProcessing(code, &renderFile{Data: path, FileExist: fileExist})
+	c.serveFile(func(name string) (http.File, error) {
+		return os.Open(name)
+	}, path)
 }
 
 func (c *Context) JSON(code int32, values interface{}) {
@@ -3,7 +3,6 @@ package znet
 import (
 	"errors"
 	"fmt"
-	"io"
 	"io/fs"
 	"net/http"
 	"path"
@@ -96,34 +95,7 @@ func (e *Engine) StaticFS(relativePath string, fs http.FileSystem, moreHandler .
 	}
 	log := temporarilyTurnOffTheLog(e, routeLog(e.Log, f, "FILE", ap))
 	handler := func(c *Context) {
-		p := strings.TrimPrefix(c.Request.URL.Path, relativePath)
-		f, err := fs.Open(p)
-		if err != nil {
-			c.toHTTPError(err)
-			return
-		}
-
-		defer f.Close()
-
-		fileInfo, err := f.Stat()
-		if err != nil {
-			c.toHTTPError(err)
-			return
-		}
-
-		if !isModified(c, fileInfo.ModTime()) {
-			return
-		}
-
-		c.prevData.Content, err = io.ReadAll(f)
-		if err != nil {
-			c.toHTTPError(err)
-			return
-		}
-
-		c.prevData.Type = zfile.GetMimeType(p, c.prevData.Content)
-		c.SetContentType(c.prevData.Type)
-		c.prevData.Code.Store(http.StatusOK)
+		c.serveFile(fs.Open, strings.TrimPrefix(c.Request.URL.Path, relativePath))
 	}
 	if strings.HasSuffix(relativePath, "/") {
 		urlPattern = path.Join(relativePath, "*")
@@ -311,10 +283,20 @@ func (e *Engine) Group(prefix string, groupHandle ...func(e *Engine)) (engine *E
 	route := &router{
 		prefix:     prefix,
 		trees:      e.router.trees,
+		hosts:      e.router.hosts,
 		middleware: middleware,
 		notFound:   e.router.notFound,
 	}
-	engine = &Engine{
+	engine = e.derive(route)
+	if len(groupHandle) > 0 {
+		groupHandle[0](engine)
+	}
+	return
+}
+
+// derive creates an engine that shares the settings of e and registers routes on the given router.
+func (e *Engine) derive(route *router) *Engine {
+	engine := &Engine{
 		router:              route,
 		views:               e.views,
 		webMode:             e.webMode,
@@ -330,14 +312,14 @@ func (e *Engine) Group(prefix string, groupHandle ...func(e *Engine)) (engine *E
 		template:            e.template,
 		injector:            e.injector,
 		customRenderings:    e.customRenderings,
+		trustedProxies:      e.trustedProxies,
+		cacheControls:       e.cacheControls,
+		health:              e.health,
 	}
 	engine.pool.New = func() interface{} {
 		return e.NewContext(nil, nil)
 	}
-	if len(groupHandle) > 0 {
-		groupHandle[0](engine)
-	}
-	return
+	return engine
 }
 
 // GenerateURL generates a URL for a named route with the given parameters.
@@ -373,12 +355,15 @@ func (e *Engine) GenerateURL(method string, routeName string, params map[string]
 			if string(segment[0]) == "{" {
 				segmentLen := len(segment)
 				if string(segment[segmentLen-1]) == "}" {
-					splitRes := strings.Split(segment[1:segmentLen-1], ":")
-					re := regexp.MustCompile(splitRes[1])
-					key := params[splitRes[0]]
+					name, expr := parseBracePlaceholder(segment[1 : segmentLen-1])
+					re := regexp.MustCompile(expr)
+					key := params[name]
 					if one := re.Find([]byte(key)); one == nil {
 						return "", ErrGenerateParameters
 					}
+					if !checkParamTypes(segment, map[string]string{name: key}) {
+						return "", ErrGenerateParameters
+					}
 					segments = append(segments, key)
 					continue
 				}
@@ -426,7 +411,7 @@ func (e *Engine) GetTrees() map[string]*Tree {
 // This is the core routing function that all other HTTP method functions use internally.
 func (e *Engine) Handle(method string, path string, action Handler, moreHandler ...Handler) *Engine {
 	handler, firsthandle := handlerFuncs(moreHandler)
-	p, l, ok := e.addHandle(method, path, Utils.ParseHandlerFunc(action, e.customRenderings...), firsthandle, handler)
+	p, l, ok := e.addHandle(method, path, action, Utils.ParseHandlerFunc(action, e.customRenderings...), firsthandle, handler)
 	if !ok {
 		return e
 	}
@@ -437,7 +422,7 @@ func (e *Engine) Handle(method string, path string, action Handler, moreHandler
 
 // addHandle is the internal implementation of route registration.
 // It adds a handler to the routing tree and returns the processed path, handler count, and a success flag.
-func (e *Engine) addHandle(method string, path string, handle handlerFn, beforehandle []handlerFn, moreHandler []handlerFn) (string, int, bool) {
+func (e *Engine) addHandle(method string, path string, action Handler, handle handlerFn, beforehandle []handlerFn, moreHandler []handlerFn) (string, int, bool) {
 	if _, ok := methods[method]; !ok {
 		e.Log.Fatal(method + " is invalid method")
 	}
@@ -474,7 +459,8 @@ func (e *Engine) addHandle(method string, path string, handle handlerFn, beforeh
 		middleware = append(beforehandle, middleware...)
 	}
 
-	tree.Add(e, path, handle, middleware...)
+	node := tree.Add(e, path, handle, middleware...)
+	node.action = action
 	tree.parameters.routeName = ""
 	return path, len(middleware) + 1, true
 }
@@ -488,8 +474,11 @@ func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	c := e.acquireContext(w, req)
+	e.health.inFlight.Add(1)
+	c.inFlight = true
 	defer func() {
 		c.write()
+		c.untrack()
 		e.releaseContext(c)
 	}()
 
@@ -530,20 +519,21 @@ func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 // It returns true if a handler was found and executed, false otherwise.
 func (e *Engine) FindHandle(rw *Context, req *http.Request, requestURL string, applyMiddleware bool) (not bool) {
 	var anyTrees bool
-	t, ok := e.router.trees[req.Method]
+	trees := e.hostTrees(rw, req)
+	t, ok := trees[req.Method]
 	if !ok {
-		t, ok = e.router.trees[anyMethod]
+		t, ok = trees[anyMethod]
 		anyTrees = true
 	}
 	if !ok {
 		return true
 	}
 
-	engine, handler, middleware, ok := Utils.TreeFind(t, requestURL)
+	route, engine, handler, middleware, ok := Utils.treeFind(t, requestURL)
 	if !ok && !anyTrees {
-		t, ok = e.router.trees[anyMethod]
+		t, ok = trees[anyMethod]
 		if ok {
-			engine, handler, middleware, ok = Utils.TreeFind(t, requestURL)
+			route, engine, handler, middleware, ok = Utils.treeFind(t, requestURL)
 		}
 	}
 
@@ -554,6 +544,7 @@ func (e *Engine) FindHandle(rw *Context, req *http.Request, requestURL string, a
 	if engine != nil {
 		rw.Engine = engine
 	}
+	rw.route = route
 
 	if applyMiddleware {
 		handleAction(rw, handler, middleware)
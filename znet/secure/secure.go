@@ -0,0 +1,270 @@
+// Package secure provides a middleware setting security response headers for znet
+package secure
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+type (
+	// Config configuration, an empty value disables the header
+	Config struct {
+		// Skipper skips setting the headers when it returns true
+		Skipper func(c *znet.Context) bool
+		// ContentSecurityPolicy, every NoncePlaceholder is replaced by the nonce of the request
+		ContentSecurityPolicy string
+		// ReportURI adds the report-uri directive to the policy, see ReportHandler
+		ReportURI string
+		// FrameOptions is X-Frame-Options, default is SAMEORIGIN
+		FrameOptions string
+		// ReferrerPolicy default is strict-origin-when-cross-origin
+		ReferrerPolicy string
+		// PermissionsPolicy e.g. camera=(), microphone=(), geolocation=()
+		PermissionsPolicy string
+		// CrossOriginOpenerPolicy default is same-origin
+		CrossOriginOpenerPolicy string
+		// CrossOriginResourcePolicy default is same-origin
+		CrossOriginResourcePolicy string
+		// CrossOriginEmbedderPolicy e.g. require-corp
+		CrossOriginEmbedderPolicy string
+		// HSTSMaxAge of Strict-Transport-Security, only sent over HTTPS or behind a proxy trusted
+		// by SetTrustedProxies of the engine setting X-Forwarded-Proto, default is one year
+		HSTSMaxAge time.Duration
+		// HSTSIncludeSubdomains adds includeSubDomains to Strict-Transport-Security
+		HSTSIncludeSubdomains bool
+		// HSTSPreload adds preload to Strict-Transport-Security
+		HSTSPreload bool
+		// ContentTypeNosniff sets X-Content-Type-Options: nosniff, default is true
+		ContentTypeNosniff bool
+		// ReportOnly sends the policy as Content-Security-Policy-Report-Only
+		ReportOnly bool
+	}
+	// Report is a CSP violation report
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		BlockedURI         string `json:"blocked-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		OriginalPolicy     string `json:"original-policy"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		ScriptSample       string `json:"script-sample"`
+		StatusCode         int    `json:"status-code"`
+		LineNumber         int    `json:"line-number"`
+		ColumnNumber       int    `json:"column-number"`
+	}
+)
+
+const (
+	// NoncePlaceholder is replaced by the nonce of the request in ContentSecurityPolicy
+	NoncePlaceholder = "{nonce}"
+	// ContextKey is the key of the nonce stored on znet.Context
+	ContextKey = "csp_nonce"
+	// DefaultContentSecurityPolicy only allows same origin resources and scripts or styles carrying the nonce
+	DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; " +
+		"object-src 'none'; base-uri 'self'; frame-ancestors 'self'"
+	maxReportSize = 64 << 10
+)
+
+// New returns a middleware that sets the security headers
+func New(opt ...func(conf *Config)) znet.HandlerFunc {
+	conf := zutil.Optional(Config{
+		ContentSecurityPolicy:     DefaultContentSecurityPolicy,
+		FrameOptions:              "SAMEORIGIN",
+		ReferrerPolicy:            "strict-origin-when-cross-origin",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+		HSTSMaxAge:                365 * 24 * time.Hour,
+		ContentTypeNosniff:        true,
+	}, opt...)
+
+	csp := conf.ContentSecurityPolicy
+	if csp != "" && conf.ReportURI != "" && !strings.Contains(csp, "report-uri") {
+		csp = strings.TrimRight(strings.TrimSpace(csp), ";") + "; report-uri " + conf.ReportURI
+	}
+	cspHeader := "Content-Security-Policy"
+	if conf.ReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(csp, NoncePlaceholder)
+
+	var hsts string
+	if conf.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(conf.HSTSMaxAge/time.Second), 10)
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	headers := [][2]string{
+		{"X-Frame-Options", conf.FrameOptions},
+		{"Referrer-Policy", conf.ReferrerPolicy},
+		{"Permissions-Policy", conf.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", conf.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Resource-Policy", conf.CrossOriginResourcePolicy},
+		{"Cross-Origin-Embedder-Policy", conf.CrossOriginEmbedderPolicy},
+	}
+	if conf.ContentTypeNosniff {
+		headers = append(headers, [2]string{"X-Content-Type-Options", "nosniff"})
+	}
+
+	return func(c *znet.Context) {
+		if conf.Skipper != nil && conf.Skipper(c) {
+			c.Next()
+			return
+		}
+
+		for _, h := range headers {
+			if h[1] != "" {
+				c.SetHeader(h[0], h[1], true)
+			}
+		}
+		if hsts != "" && isHTTPS(c) {
+			c.SetHeader("Strict-Transport-Security", hsts, true)
+		}
+		if csp != "" {
+			policy := csp
+			if useNonce {
+				nonce := newNonce()
+				c.WithValue(ContextKey, nonce)
+				policy = strings.ReplaceAll(policy, NoncePlaceholder, nonce)
+			}
+			c.SetHeader(cspHeader, policy, true)
+		}
+		c.Next()
+	}
+}
+
+// Nonce returns the CSP nonce of the request, empty when the policy has no NoncePlaceholder
+func Nonce(c *znet.Context) string {
+	return c.MustValue(ContextKey, "").(string)
+}
+
+// FuncMap returns the template function cspNonce of the request,
+// pass it as the funcMap of Context.Template
+func FuncMap(c *znet.Context) template.FuncMap {
+	nonce := Nonce(c)
+	return template.FuncMap{
+		"cspNonce": func() string {
+			return nonce
+		},
+	}
+}
+
+// ReportHandler receives CSP violation reports sent to ReportURI, both the
+// application/csp-report and the Reporting API formats are accepted,
+// reports are logged when fn is nil
+func ReportHandler(fn ...func(c *znet.Context, r Report)) znet.HandlerFunc {
+	return func(c *znet.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxReportSize))
+		if err != nil {
+			c.Abort(http.StatusBadRequest)
+			return
+		}
+
+		reports, err := parseReports(body)
+		if err != nil {
+			c.Abort(http.StatusBadRequest)
+			return
+		}
+		for _, r := range reports {
+			if len(fn) > 0 && fn[0] != nil {
+				fn[0](c, r)
+			} else {
+				c.Log.Warnf("csp violation: %s blocked %s on %s", r.EffectiveDirective, r.BlockedURI, r.DocumentURI)
+			}
+		}
+		c.Abort(http.StatusNoContent)
+	}
+}
+
+// reportingBody is the body of a Reporting API csp-violation report
+type reportingBody struct {
+	DocumentURL        string `json:"documentURL"`
+	Referrer           string `json:"referrer"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	OriginalPolicy     string `json:"originalPolicy"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"sourceFile"`
+	Sample             string `json:"sample"`
+	StatusCode         int    `json:"statusCode"`
+	LineNumber         int    `json:"lineNumber"`
+	ColumnNumber       int    `json:"columnNumber"`
+}
+
+func parseReports(body []byte) ([]Report, error) {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) > 0 && body[0] == '[' {
+		var list []struct {
+			Type string        `json:"type"`
+			Body reportingBody `json:"body"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+		reports := make([]Report, 0, len(list))
+		for _, v := range list {
+			if v.Type != "csp-violation" {
+				continue
+			}
+			b := v.Body
+			reports = append(reports, Report{
+				DocumentURI:        b.DocumentURL,
+				Referrer:           b.Referrer,
+				BlockedURI:         b.BlockedURL,
+				ViolatedDirective:  b.EffectiveDirective,
+				EffectiveDirective: b.EffectiveDirective,
+				OriginalPolicy:     b.OriginalPolicy,
+				Disposition:        b.Disposition,
+				SourceFile:         b.SourceFile,
+				ScriptSample:       b.Sample,
+				StatusCode:         b.StatusCode,
+				LineNumber:         b.LineNumber,
+				ColumnNumber:       b.ColumnNumber,
+			})
+		}
+		return reports, nil
+	}
+
+	var v struct {
+		Report Report `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, err
+	}
+	if v.Report.EffectiveDirective == "" {
+		v.Report.EffectiveDirective = v.Report.ViolatedDirective
+	}
+	return []Report{v.Report}, nil
+}
+
+// isHTTPS only honours X-Forwarded-Proto sent by a proxy the engine explicitly trusts,
+// the package-level TrustedProxies trust everyone by default
+func isHTTPS(c *znet.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	return strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") &&
+		c.Engine.HasTrustedProxies() && c.Engine.IsTrustedProxy(znet.RemoteIP(c.Request))
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,118 @@
+package secure_test
+
+import (
+	"bytes"
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/secure"
+)
+
+func request(r *znet.Engine, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHeaders(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := znet.New("secure-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(secure.New(func(conf *secure.Config) {
+		conf.PermissionsPolicy = "camera=()"
+		conf.HSTSMaxAge = time.Hour
+		conf.HSTSIncludeSubdomains = true
+		conf.Skipper = func(c *znet.Context) bool {
+			return c.Request.URL.Path == "/skip"
+		}
+	}))
+	r.GET("/", func(c *znet.Context) {
+		var buf bytes.Buffer
+		tpl := template.Must(template.New("").Funcs(secure.FuncMap(c)).Parse(`<script nonce="{{ cspNonce }}"></script>`))
+		_ = tpl.Execute(&buf, nil)
+		c.String(200, buf.String())
+	})
+	r.GET("/skip", func(c *znet.Context) {
+		c.String(200, secure.Nonce(c))
+	})
+
+	w := request(r, "GET", "/", "", nil)
+	h := w.Header()
+	tt.Equal("SAMEORIGIN", h.Get("X-Frame-Options"))
+	tt.Equal("nosniff", h.Get("X-Content-Type-Options"))
+	tt.Equal("strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+	tt.Equal("camera=()", h.Get("Permissions-Policy"))
+	tt.Equal("same-origin", h.Get("Cross-Origin-Opener-Policy"))
+	tt.Equal("", h.Get("Cross-Origin-Embedder-Policy"))
+	tt.Equal("", h.Get("Strict-Transport-Security"))
+
+	nonce := strings.TrimSuffix(strings.TrimPrefix(w.Body.String(), `<script nonce="`), `"></script>`)
+	tt.Equal(22, len(nonce))
+	tt.EqualTrue(strings.Contains(h.Get("Content-Security-Policy"), "script-src 'self' 'nonce-"+nonce+"'"))
+	w2 := request(r, "GET", "/", "", nil)
+	tt.EqualTrue(w2.Body.String() != w.Body.String())
+
+	w = request(r, "GET", "/", "", map[string]string{"X-Forwarded-Proto": "https"})
+	tt.Equal("", w.Header().Get("Strict-Transport-Security"))
+
+	tt.NoError(r.SetTrustedProxies("10.0.0.0/8"), true)
+	w = request(r, "GET", "/", "", map[string]string{"X-Forwarded-Proto": "https"})
+	tt.Equal("", w.Header().Get("Strict-Transport-Security"))
+	tt.NoError(r.SetTrustedProxies("192.0.2.1"), true)
+	w = request(r, "GET", "/", "", map[string]string{"X-Forwarded-Proto": "https"})
+	tt.Equal("max-age=3600; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+
+	w = request(r, "GET", "/skip", "", nil)
+	tt.Equal("", w.Body.String())
+	tt.Equal("", w.Header().Get("X-Frame-Options"))
+}
+
+func TestReportOnly(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	var reports []secure.Report
+	r := znet.New("secure-report-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(secure.New(func(conf *secure.Config) {
+		conf.ContentSecurityPolicy = "default-src 'self';"
+		conf.ReportOnly = true
+		conf.ReportURI = "/csp-report"
+	}))
+	r.GET("/", func(c *znet.Context) {
+		c.String(200, secure.Nonce(c))
+	})
+	r.POST("/csp-report", secure.ReportHandler(func(c *znet.Context, r secure.Report) {
+		reports = append(reports, r)
+	}))
+
+	w := request(r, "GET", "/", "", nil)
+	tt.Equal("", w.Body.String())
+	tt.Equal("", w.Header().Get("Content-Security-Policy"))
+	tt.Equal("default-src 'self'; report-uri /csp-report", w.Header().Get("Content-Security-Policy-Report-Only"))
+
+	w = request(r, "POST", "/csp-report", `{"csp-report":{"document-uri":"https://a.com/","blocked-uri":"https://evil.com/x.js","violated-directive":"script-src","line-number":3}}`,
+		map[string]string{"Content-Type": "application/csp-report"})
+	tt.Equal(204, w.Code)
+	w = request(r, "POST", "/csp-report", `[{"type":"csp-violation","body":{"documentURL":"https://a.com/","blockedURL":"inline","effectiveDirective":"style-src-elem"}},{"type":"deprecation","body":{}}]`,
+		map[string]string{"Content-Type": "application/reports+json"})
+	tt.Equal(204, w.Code)
+	w = request(r, "POST", "/csp-report", `oops`, nil)
+	tt.Equal(400, w.Code)
+
+	tt.Equal(2, len(reports))
+	tt.Equal("https://evil.com/x.js", reports[0].BlockedURI)
+	tt.Equal("script-src", reports[0].EffectiveDirective)
+	tt.Equal(3, reports[0].LineNumber)
+	tt.Equal("inline", reports[1].BlockedURI)
+	tt.Equal("style-src-elem", reports[1].ViolatedDirective)
+}
@@ -0,0 +1,324 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zstring"
+	"github.com/sohaha/zlsgo/ztype"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+// Cookie implements the Session interface for CookieStore,
+// the whole session is written back to the client when it changed.
+type Cookie struct {
+	expiresAt time.Time
+	store     *CookieStore
+	data      map[string]interface{}
+	id        string
+	mu        sync.RWMutex
+	changed   bool
+	destroyed bool
+}
+
+var _ Session = (*Cookie)(nil)
+
+// ID returns the session ID.
+func (s *Cookie) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// Get retrieves a value from the session by key.
+func (s *Cookie) Get(key string) ztype.Type {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if value, ok := s.data[key]; ok {
+		return ztype.New(value)
+	}
+	return ztype.New(nil)
+}
+
+// Set stores a value in the session with the specified key.
+func (s *Cookie) Set(key string, value interface{}) {
+	s.mu.Lock()
+	s.data[key] = value
+	s.changed = true
+	s.destroyed = false
+	s.mu.Unlock()
+}
+
+// Delete removes a value from the session by key.
+func (s *Cookie) Delete(key string) error {
+	s.mu.Lock()
+	if _, ok := s.data[key]; ok {
+		delete(s.data, key)
+		s.changed = true
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Save marks the session to be written, the cookie is set when the request finishes.
+func (s *Cookie) Save() error {
+	s.mu.Lock()
+	s.changed = true
+	s.mu.Unlock()
+	return nil
+}
+
+// ExpiresAt returns the time when the session will expire.
+func (s *Cookie) ExpiresAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.expiresAt
+}
+
+// Destroy removes all data from the session and clears its cookies.
+func (s *Cookie) Destroy() error {
+	s.mu.Lock()
+	s.data = make(map[string]interface{})
+	s.destroyed = true
+	s.changed = false
+	s.mu.Unlock()
+	return nil
+}
+
+// Regenerate issues a new session ID, the cookie is rewritten with the same data.
+func (s *Cookie) Regenerate() error {
+	id, err := generateSessionID()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.id = id
+	s.changed = true
+	s.mu.Unlock()
+	return nil
+}
+
+// CookieStore implements the Store interface by keeping the whole session
+// in an AES-GCM encrypted cookie, so no shared backend is needed.
+// Sessions larger than one cookie are split into numbered chunks.
+// It only works with the session middleware, the ID based methods are no-ops.
+type CookieStore struct {
+	keys     []string
+	secure   bool
+	sameSite http.SameSite
+}
+
+var _ Store = (*CookieStore)(nil)
+
+// CookieStoreOptions config of the cookie store.
+// DecryptKeys: previous keys still accepted when reading, for key rotation.
+type CookieStoreOptions struct {
+	DecryptKeys []string
+	SameSite    http.SameSite
+	Secure      bool
+}
+
+// cookiePayload is the encrypted content of the session cookie.
+type cookiePayload struct {
+	ExpiresAt time.Time              `json:"expires_at"`
+	Data      map[string]interface{} `json:"data"`
+	ID        string                 `json:"id"`
+}
+
+// ErrCookieTooLarge is returned when the session does not fit into the cookie chunks
+var ErrCookieTooLarge = errors.New("session: cookie too large")
+
+const (
+	cookieChunkSize = 3800
+	cookieMaxChunks = 10
+)
+
+// NewCookieStore creates a cookie store, key (16, 24 or 32 bytes) encrypts the session.
+func NewCookieStore(key string, opt ...func(*CookieStoreOptions)) (*CookieStore, error) {
+	cfg := zutil.Optional(CookieStoreOptions{SameSite: http.SameSiteLaxMode}, opt...)
+	keys := append([]string{key}, cfg.DecryptKeys...)
+	for _, k := range keys {
+		switch len(k) {
+		case 16, 24, 32:
+		default:
+			return nil, errors.New("session: encrypt key must be 16, 24 or 32 bytes")
+		}
+	}
+	return &CookieStore{keys: keys, secure: cfg.Secure, sameSite: cfg.SameSite}, nil
+}
+
+// New creates a new empty session.
+func (store *CookieStore) New(sessionID string, expiresAt time.Time) (Session, error) {
+	return store.session(sessionID, expiresAt, nil), nil
+}
+
+// Get always fails, cookie sessions are loaded from the request.
+func (store *CookieStore) Get(sessionID string) (Session, error) {
+	return nil, errSessionNotFound
+}
+
+// Save is a no-op, the session middleware writes the cookie.
+func (store *CookieStore) Save(session Session) error {
+	return nil
+}
+
+// Delete is a no-op, use Session.Destroy to clear the cookie.
+func (store *CookieStore) Delete(sessionID string) error {
+	return nil
+}
+
+// Collect is a no-op, the expiry is embedded in the cookie.
+func (store *CookieStore) Collect() error {
+	return nil
+}
+
+// Renew is a no-op, the session middleware renews the cookie.
+func (store *CookieStore) Renew(sessionID string, expiresAt time.Time) error {
+	return nil
+}
+
+// Close is a no-op.
+func (store *CookieStore) Close() error {
+	return nil
+}
+
+func (store *CookieStore) session(sessionID string, expiresAt time.Time, data map[string]interface{}) *Cookie {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	return &Cookie{id: sessionID, expiresAt: expiresAt, store: store, data: data}
+}
+
+// load reads the session from the request cookies, a new session is
+// returned when the cookie is missing, tampered or expired.
+func (store *CookieStore) load(c *znet.Context, name string, expiresAt time.Duration) (Session, error) {
+	if value := store.read(c, name); value != "" {
+		if p, ok := store.decode(value); ok && time.Now().Before(p.ExpiresAt) {
+			return store.session(p.ID, p.ExpiresAt, p.Data), nil
+		}
+	}
+
+	id, err := generateSessionID()
+	if err != nil {
+		return nil, err
+	}
+	return store.session(id, time.Now().Add(expiresAt), nil), nil
+}
+
+// flush writes the session cookies when the session changed or was renewed.
+func (store *CookieStore) flush(c *znet.Context, name string, session Session, renew time.Time) error {
+	s := session.(*Cookie)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists := store.chunks(c, name)
+	if s.destroyed {
+		store.clear(c, name, 0, exists)
+		return nil
+	}
+	if !renew.IsZero() && exists > 0 {
+		s.expiresAt, s.changed = renew, true
+	}
+	if !s.changed {
+		return nil
+	}
+
+	b, err := json.Marshal(cookiePayload{ID: s.id, ExpiresAt: s.expiresAt, Data: s.data})
+	if err != nil {
+		return err
+	}
+	b, err = zstring.AesGCMEncrypt(b, store.keys[0])
+	if err != nil {
+		return err
+	}
+	value := base64.RawURLEncoding.EncodeToString(b)
+	n := (len(value) + cookieChunkSize - 1) / cookieChunkSize
+	if n > cookieMaxChunks {
+		return ErrCookieTooLarge
+	}
+
+	maxAge := int(time.Until(s.expiresAt).Seconds())
+	for i := 0; i < n; i++ {
+		end := (i + 1) * cookieChunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		store.set(c, chunkName(name, i), value[i*cookieChunkSize:end], maxAge)
+	}
+	store.clear(c, name, n, exists)
+	s.changed = false
+	return nil
+}
+
+func (store *CookieStore) read(c *znet.Context, name string) string {
+	value := ""
+	for i := 0; i < cookieMaxChunks; i++ {
+		cookie, err := c.Request.Cookie(chunkName(name, i))
+		if err != nil {
+			break
+		}
+		value += cookie.Value
+	}
+	return value
+}
+
+func (store *CookieStore) decode(value string) (p cookiePayload, ok bool) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return
+	}
+	for _, key := range store.keys {
+		plain, err := zstring.AesGCMDecrypt(b, key)
+		if err != nil {
+			continue
+		}
+		if json.Unmarshal(plain, &p) != nil || validateSessionID(p.ID) != nil {
+			return p, false
+		}
+		return p, true
+	}
+	return
+}
+
+func (store *CookieStore) chunks(c *znet.Context, name string) int {
+	n := 0
+	for n < cookieMaxChunks {
+		if _, err := c.Request.Cookie(chunkName(name, n)); err != nil {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func (store *CookieStore) clear(c *znet.Context, name string, from, to int) {
+	for i := from; i < to; i++ {
+		store.set(c, chunkName(name, i), "", -1)
+	}
+}
+
+func (store *CookieStore) set(c *znet.Context, name, value string, maxAge int) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   store.secure,
+		SameSite: store.sameSite,
+		MaxAge:   maxAge,
+	}
+	c.Writer.Header().Add("Set-Cookie", cookie.String())
+}
+
+func chunkName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+	return name + "_" + strconv.Itoa(i)
+}
@@ -0,0 +1,133 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/session"
+)
+
+type cookieClient struct {
+	r       *znet.Engine
+	cookies map[string]string
+}
+
+func (cl *cookieClient) get(path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	for k, v := range cl.cookies {
+		req.AddCookie(&http.Cookie{Name: k, Value: v})
+	}
+	cl.r.ServeHTTP(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 {
+			delete(cl.cookies, c.Name)
+		} else {
+			cl.cookies[c.Name] = c.Value
+		}
+	}
+	return w
+}
+
+func newCookieEngine(name string, store session.Store) *znet.Engine {
+	r := znet.New(name)
+	r.SetMode(znet.QuietMode)
+	r.Use(session.New(store, func(c *session.Config) {
+		c.ExpiresAt = time.Hour
+	}))
+	r.GET("/count", func(c *znet.Context) {
+		s, _ := session.Get(c)
+		s.Set("count", s.Get("count").Int()+1)
+		c.String(200, s.Get("count").String())
+	})
+	r.GET("/read", func(c *znet.Context) {
+		s, _ := session.Get(c)
+		c.String(200, s.Get("count").String())
+	})
+	r.GET("/big", func(c *znet.Context) {
+		s, _ := session.Get(c)
+		s.Set("big", strings.Repeat("z", 6000))
+		c.String(200, "ok")
+	})
+	r.GET("/small", func(c *znet.Context) {
+		s, _ := session.Get(c)
+		_ = s.Delete("big")
+		c.String(200, s.Get("big").String())
+	})
+	r.GET("/huge", func(c *znet.Context) {
+		s, _ := session.Get(c)
+		s.Set("huge", strings.Repeat("z", 60000))
+		c.String(200, "ok")
+	})
+	r.GET("/logout", func(c *znet.Context) {
+		s, _ := session.Get(c)
+		_ = s.Destroy()
+		c.String(200, "ok")
+	})
+	return r
+}
+
+func TestCookieStore(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	_, err := session.NewCookieStore("short")
+	tt.EqualTrue(err != nil)
+
+	store, err := session.NewCookieStore("0123456789abcdef")
+	tt.NoError(err, true)
+	cl := &cookieClient{r: newCookieEngine("cookie-session-test", store), cookies: map[string]string{}}
+
+	w := cl.get("/read")
+	tt.Equal("", w.Header().Get("Set-Cookie"))
+	tt.Equal(0, len(cl.cookies))
+
+	for i := 1; i <= 3; i++ {
+		tt.Equal(string(rune('0'+i)), cl.get("/count").Body.String())
+	}
+	tt.EqualFalse(strings.Contains(cl.cookies["session_id"], "count"))
+
+	cl.get("/big")
+	tt.Equal(3, len(cl.cookies))
+	tt.Equal("3", cl.get("/read").Body.String())
+	tt.Equal("", cl.get("/small").Body.String())
+	tt.Equal(1, len(cl.cookies))
+	tt.Equal("3", cl.get("/read").Body.String())
+
+	tt.Equal(500, cl.get("/huge").Code)
+
+	value := cl.cookies["session_id"]
+	cl.cookies["session_id"] = value[:len(value)-2] + "AA"
+	tt.Equal("", cl.get("/read").Body.String())
+	cl.cookies["session_id"] = value
+
+	cl.get("/logout")
+	tt.Equal(0, len(cl.cookies))
+	tt.Equal("", cl.get("/read").Body.String())
+}
+
+func TestCookieStoreRotation(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	old, _ := session.NewCookieStore("0123456789abcdef")
+	cl := &cookieClient{r: newCookieEngine("cookie-session-old", old), cookies: map[string]string{}}
+	cl.get("/count")
+	cookies := map[string]string{"session_id": cl.cookies["session_id"]}
+
+	rotated, _ := session.NewCookieStore("fedcba9876543210", func(o *session.CookieStoreOptions) {
+		o.DecryptKeys = []string{"0123456789abcdef"}
+	})
+	cl = &cookieClient{r: newCookieEngine("cookie-session-rotated", rotated), cookies: cookies}
+	tt.Equal("2", cl.get("/count").Body.String())
+
+	replaced, _ := session.NewCookieStore("fedcba9876543210")
+	cl2 := &cookieClient{r: newCookieEngine("cookie-session-replaced", replaced), cookies: map[string]string{"session_id": cookies["session_id"]}}
+	tt.Equal("3", cl2.get("/count").Body.String())
+
+	cl2.cookies["session_id"] = "invalid"
+	tt.Equal("1", cl2.get("/count").Body.String())
+}
@@ -0,0 +1,434 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/zfile"
+	"github.com/sohaha/zlsgo/zstring"
+	"github.com/sohaha/zlsgo/ztype"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+// File implements the Session interface on top of FileStore.
+// Changes are kept in memory until Save, which merges them into the file
+// so concurrent requests of other processes do not lose their keys.
+type File struct {
+	expiresAt time.Time
+	store     *FileStore
+	data      map[string]interface{}
+	changes   map[string]bool
+	id        string
+	mu        sync.RWMutex
+}
+
+var _ Session = (*File)(nil)
+
+// ID returns the session ID.
+func (s *File) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// Get retrieves a value from the session by key.
+func (s *File) Get(key string) ztype.Type {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if value, ok := s.data[key]; ok {
+		return ztype.New(value)
+	}
+	return ztype.New(nil)
+}
+
+// Set stores a value in the session with the specified key.
+func (s *File) Set(key string, value interface{}) {
+	s.mu.Lock()
+	s.data[key] = value
+	s.changes[key] = true
+	s.mu.Unlock()
+}
+
+// Delete removes a value from the session by key.
+func (s *File) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.data, key)
+	s.changes[key] = false
+	s.mu.Unlock()
+	return nil
+}
+
+// Save writes the changed keys to the session file.
+func (s *File) Save() error {
+	return s.store.Save(s)
+}
+
+// ExpiresAt returns the time when the session will expire.
+func (s *File) ExpiresAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.expiresAt
+}
+
+// Destroy removes all data from the session and deletes its file.
+func (s *File) Destroy() error {
+	s.mu.Lock()
+	s.data = make(map[string]interface{})
+	s.changes = make(map[string]bool)
+	id := s.id
+	s.mu.Unlock()
+	return s.store.Delete(id)
+}
+
+// Regenerate moves the session to a new ID and file, keeping its data.
+func (s *File) Regenerate() error {
+	id, err := generateSessionID()
+	if err != nil {
+		return err
+	}
+	if err = s.Save(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	old := s.id
+	name, shard := s.store.file(id)
+	if err = s.store.lock(shard); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	err = s.store.write(name, s.expiresAt, s.data)
+	s.store.unlock(shard)
+	if err == nil {
+		s.id = id
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.store.Delete(old)
+}
+
+// FileStore implements the Store interface with one file per session,
+// so several processes on the same host can share sessions.
+// Files are named by the hashed session ID, written atomically and
+// serialized across processes with zfile.ShardedFileLock.
+type FileStore struct {
+	stop     chan struct{}
+	locks    *zfile.ShardedFileLock
+	dir      string
+	key      string
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+var _ Store = (*FileStore)(nil)
+
+// FileStoreOptions config of the file store.
+// EncryptKey: AES key (16, 24 or 32 bytes) to encrypt the session data with AES-GCM.
+// CollectInterval: interval of removing expired files, zero disables it.
+type FileStoreOptions struct {
+	EncryptKey      string
+	Shards          int
+	CollectInterval time.Duration
+	LockTimeout     time.Duration
+}
+
+// fileRecord is the on-disk representation of one session,
+// Payload holds the encrypted persistedSession when encryption is enabled.
+type fileRecord struct {
+	ExpiresAt time.Time              `json:"expires_at"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Payload   []byte                 `json:"payload,omitempty"`
+}
+
+var (
+	errSessionNotFound = errors.New("session not found")
+	errSessionExpired  = errors.New("session expired")
+	// ErrLockTimeout is returned when the session file lock was not acquired in time
+	ErrLockTimeout = errors.New("session: lock timeout")
+)
+
+const fileExt = ".session"
+
+// NewFileStore creates a file store in dir.
+func NewFileStore(dir string, opt ...func(*FileStoreOptions)) (*FileStore, error) {
+	cfg := zutil.Optional(FileStoreOptions{
+		Shards:          16,
+		CollectInterval: 10 * time.Minute,
+		LockTimeout:     3 * time.Second,
+	}, opt...)
+	if cfg.Shards <= 0 {
+		cfg.Shards = 1
+	}
+	switch len(cfg.EncryptKey) {
+	case 0, 16, 24, 32:
+	default:
+		return nil, errors.New("session: encrypt key must be 16, 24 or 32 bytes")
+	}
+
+	dir = zfile.RealPathMkdir(dir, true)
+	if !zfile.DirExist(dir) {
+		return nil, errors.New("session: can not create directory " + dir)
+	}
+
+	store := &FileStore{
+		dir:   dir,
+		key:   cfg.EncryptKey,
+		locks: zfile.NewShardedFileLock(dir, cfg.Shards, cfg.LockTimeout),
+	}
+
+	if cfg.CollectInterval > 0 {
+		store.stop = make(chan struct{})
+		store.wg.Add(1)
+		go store.collectLoop(cfg.CollectInterval)
+	}
+	return store, nil
+}
+
+// New creates a new session with the specified ID and expiration time.
+func (store *FileStore) New(sessionID string, expiresAt time.Time) (Session, error) {
+	s := store.session(sessionID, expiresAt, nil)
+	name, shard := store.file(sessionID)
+	if err := store.lock(shard); err != nil {
+		return nil, err
+	}
+	defer store.unlock(shard)
+
+	if err := store.write(name, expiresAt, s.data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get reads a session by its ID from its file.
+func (store *FileStore) Get(sessionID string) (Session, error) {
+	name, shard := store.file(sessionID)
+	if err := store.lock(shard); err != nil {
+		return nil, err
+	}
+	defer store.unlock(shard)
+
+	expiresAt, data, err := store.read(name)
+	if err != nil {
+		return nil, err
+	}
+	if !expiresAt.IsZero() && time.Now().After(expiresAt) {
+		_ = os.Remove(name)
+		return nil, errSessionExpired
+	}
+	return store.session(sessionID, expiresAt, data), nil
+}
+
+// Save merges the changed keys of the session into its file.
+func (store *FileStore) Save(session Session) error {
+	s, ok := session.(*File)
+	if !ok {
+		return errors.New("session: not a file session")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.changes) == 0 {
+		return nil
+	}
+
+	name, shard := store.file(s.id)
+	if err := store.lock(shard); err != nil {
+		return err
+	}
+	defer store.unlock(shard)
+
+	expiresAt, data, err := store.read(name)
+	if err == errSessionNotFound {
+		expiresAt, data = s.expiresAt, make(map[string]interface{})
+	} else if err != nil {
+		return err
+	}
+	for k, set := range s.changes {
+		if set {
+			data[k] = s.data[k]
+		} else {
+			delete(data, k)
+		}
+	}
+	if err = store.write(name, expiresAt, data); err != nil {
+		return err
+	}
+
+	s.data, s.expiresAt = data, expiresAt
+	s.changes = make(map[string]bool)
+	return nil
+}
+
+// Delete removes the session file.
+func (store *FileStore) Delete(sessionID string) error {
+	name, shard := store.file(sessionID)
+	if err := store.lock(shard); err != nil {
+		return err
+	}
+	defer store.unlock(shard)
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Collect removes the files of all expired sessions.
+func (store *FileStore) Collect() error {
+	files, err := filepath.Glob(filepath.Join(store.dir, "*"+fileExt))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range files {
+		shard := store.locks.Shard(filepath.Base(name))
+		if err := store.lock(shard); err != nil {
+			return err
+		}
+		b, err := os.ReadFile(name)
+		if err == nil {
+			var r fileRecord
+			if json.Unmarshal(b, &r) != nil || (!r.ExpiresAt.IsZero() && now.After(r.ExpiresAt)) {
+				_ = os.Remove(name)
+			}
+		}
+		store.unlock(shard)
+	}
+	return nil
+}
+
+// Renew extends the expiration time of an existing session.
+func (store *FileStore) Renew(sessionID string, expiresAt time.Time) error {
+	name, shard := store.file(sessionID)
+	if err := store.lock(shard); err != nil {
+		return err
+	}
+	defer store.unlock(shard)
+
+	_, data, err := store.read(name)
+	if err != nil {
+		return err
+	}
+	return store.write(name, expiresAt, data)
+}
+
+// Close stops the background collection.
+func (store *FileStore) Close() error {
+	if store == nil || store.stop == nil {
+		return nil
+	}
+	store.stopOnce.Do(func() {
+		close(store.stop)
+	})
+	store.wg.Wait()
+	return nil
+}
+
+func (store *FileStore) collectLoop(interval time.Duration) {
+	defer store.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-store.stop:
+			return
+		case <-ticker.C:
+			_ = store.Collect()
+		}
+	}
+}
+
+func (store *FileStore) session(sessionID string, expiresAt time.Time, data map[string]interface{}) *File {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	return &File{
+		id:        sessionID,
+		expiresAt: expiresAt,
+		store:     store,
+		data:      data,
+		changes:   make(map[string]bool),
+	}
+}
+
+func (store *FileStore) file(sessionID string) (string, int) {
+	base := hashSessionID(sessionID) + fileExt
+	return filepath.Join(store.dir, base), store.locks.Shard(base)
+}
+
+func (store *FileStore) read(name string) (time.Time, map[string]interface{}, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errSessionNotFound
+		}
+		return time.Time{}, nil, err
+	}
+
+	var r fileRecord
+	if err = json.Unmarshal(b, &r); err != nil {
+		return time.Time{}, nil, err
+	}
+	if store.key == "" {
+		if r.Data == nil {
+			r.Data = make(map[string]interface{})
+		}
+		return r.ExpiresAt, r.Data, nil
+	}
+
+	plain, err := zstring.AesGCMDecrypt(r.Payload, store.key)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	var ps persistedSession
+	if err = json.Unmarshal(plain, &ps); err != nil {
+		return time.Time{}, nil, err
+	}
+	if ps.Data == nil {
+		ps.Data = make(map[string]interface{})
+	}
+	return ps.ExpiresAt, ps.Data, nil
+}
+
+func (store *FileStore) write(name string, expiresAt time.Time, data map[string]interface{}) error {
+	r := fileRecord{ExpiresAt: expiresAt, Data: data}
+	if store.key != "" {
+		plain, err := json.Marshal(persistedSession{ExpiresAt: expiresAt, Data: data})
+		if err != nil {
+			return err
+		}
+		if r.Payload, err = zstring.AesGCMEncrypt(plain, store.key); err != nil {
+			return err
+		}
+		r.Data = nil
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (store *FileStore) lock(shard int) error {
+	err := store.locks.Lock(shard)
+	if err == zfile.ErrLocked {
+		return ErrLockTimeout
+	}
+	return err
+}
+
+func (store *FileStore) unlock(shard int) {
+	store.locks.Unlock(shard)
+}
@@ -0,0 +1,188 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/session"
+)
+
+func TestFileSession(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	dir := t.TempDir()
+
+	a, err := session.NewFileStore(dir)
+	tt.NoError(err, true)
+	defer a.Close()
+	b, err := session.NewFileStore(dir, func(o *session.FileStoreOptions) {
+		o.CollectInterval = 0
+	})
+	tt.NoError(err, true)
+
+	tt.Run("Shared", func(tt *zlsgo.TestUtil) {
+		id := "file-session-id-1234567890abcdef"
+		s, err := a.New(id, time.Now().Add(time.Hour))
+		tt.NoError(err, true)
+		s.Set("name", "zls")
+		tt.NoError(s.Save())
+
+		s2, err := b.Get(id)
+		tt.NoError(err, true)
+		tt.Equal("zls", s2.Get("name").String())
+
+		s.Set("a", 1)
+		s2.Set("b", 2)
+		tt.NoError(s.Save())
+		tt.NoError(s2.Save())
+		s3, _ := a.Get(id)
+		tt.Equal(1, s3.Get("a").Int())
+		tt.Equal(2, s3.Get("b").Int())
+		tt.Equal("zls", s3.Get("name").String())
+
+		tt.NoError(s3.Delete("name"))
+		tt.NoError(s3.Save())
+		s, _ = b.Get(id)
+		tt.EqualFalse(s.Get("name").Exists())
+
+		tt.NoError(s.Destroy())
+		_, err = a.Get(id)
+		tt.EqualTrue(err != nil)
+	})
+
+	tt.Run("Concurrent", func(tt *zlsgo.TestUtil) {
+		id := "file-session-id-2234567890abcdef"
+		_, _ = a.New(id, time.Now().Add(time.Hour))
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				store := a
+				if i%2 == 0 {
+					store = b
+				}
+				s, err := store.Get(id)
+				if err != nil {
+					return
+				}
+				s.Set("k"+strings.Repeat("x", i), i)
+				_ = s.Save()
+			}(i)
+		}
+		wg.Wait()
+		s, _ := a.Get(id)
+		for i := 0; i < 20; i++ {
+			tt.Equal(i, s.Get("k"+strings.Repeat("x", i)).Int())
+		}
+	})
+
+	tt.Run("Expired", func(tt *zlsgo.TestUtil) {
+		id := "file-session-id-3234567890abcdef"
+		_, _ = a.New(id, time.Now().Add(time.Hour))
+		tt.NoError(a.Renew(id, time.Now().Add(-time.Second)))
+		_, err := b.Get(id)
+		tt.EqualTrue(err != nil)
+
+		_, _ = a.New(id, time.Now().Add(-time.Second))
+		tt.NoError(a.Collect())
+		files, _ := filepath.Glob(filepath.Join(dir, "*.session"))
+		for _, f := range files {
+			tt.EqualFalse(strings.Contains(f, "3234567890"))
+		}
+	})
+
+	tt.Run("Corrupt", func(tt *zlsgo.TestUtil) {
+		dir := t.TempDir()
+		c, err := session.NewFileStore(dir, func(o *session.FileStoreOptions) {
+			o.CollectInterval = 0
+		})
+		tt.NoError(err, true)
+		s, err := c.New("file-session-id-4234567890abcdef", time.Now().Add(time.Hour))
+		tt.NoError(err, true)
+		s.Set("name", "zls")
+		tt.NoError(s.Save())
+
+		files, _ := filepath.Glob(filepath.Join(dir, "*.session"))
+		tt.Equal(1, len(files), true)
+		tt.NoError(os.WriteFile(files[0], []byte("{broken"), 0o600))
+		s.Set("a", 1)
+		tt.EqualTrue(s.Save() != nil)
+		b, _ := os.ReadFile(files[0])
+		tt.Equal("{broken", string(b))
+	})
+}
+
+func TestFileSessionEncrypt(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	dir := t.TempDir()
+
+	_, err := session.NewFileStore(dir, func(o *session.FileStoreOptions) {
+		o.EncryptKey = "short"
+	})
+	tt.EqualTrue(err != nil)
+
+	store, err := session.NewFileStore(dir, func(o *session.FileStoreOptions) {
+		o.EncryptKey = "0123456789abcdef0123456789abcdef"
+	})
+	tt.NoError(err, true)
+	defer store.Close()
+
+	id := "file-session-id-4234567890abcdef"
+	s, _ := store.New(id, time.Now().Add(time.Hour))
+	s.Set("secret", "plain-value")
+	tt.NoError(s.Save())
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.session"))
+	tt.Equal(1, len(files))
+	tt.EqualFalse(strings.Contains(filepath.Base(files[0]), id))
+	raw, _ := os.ReadFile(files[0])
+	tt.EqualFalse(strings.Contains(string(raw), "plain-value"))
+
+	s, err = store.Get(id)
+	tt.NoError(err, true)
+	tt.Equal("plain-value", s.Get("secret").String())
+
+	other, _ := session.NewFileStore(dir, func(o *session.FileStoreOptions) {
+		o.EncryptKey = "fedcba9876543210fedcba9876543210"
+	})
+	_, err = other.Get(id)
+	tt.EqualTrue(err != nil)
+}
+
+func TestFileSessionMiddleware(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	store, err := session.NewFileStore(t.TempDir())
+	tt.NoError(err, true)
+	r := znet.New("file-session-test")
+	r.SetMode(znet.QuietMode)
+	r.Use(session.New(store))
+	r.GET("/", func(c *znet.Context) {
+		s, _ := session.Get(c)
+		s.Set("count", s.Get("count").Int()+1)
+		c.String(200, s.Get("count").String())
+	})
+
+	var cookie string
+	for i := 1; i <= 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		r.ServeHTTP(w, req)
+		if c := w.Header().Get("Set-Cookie"); c != "" {
+			cookie = strings.Split(c, ";")[0]
+		}
+		tt.Equal(200, w.Code)
+		tt.Equal(string(rune('0'+i)), w.Body.String())
+	}
+}
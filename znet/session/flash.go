@@ -0,0 +1,99 @@
+package session
+
+import (
+	"github.com/sohaha/zlsgo/znet"
+)
+
+// Flash is a message kept in the session until it is read once.
+type Flash struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// Common flash levels
+const (
+	FlashInfo    = "info"
+	FlashSuccess = "success"
+	FlashWarning = "warning"
+	FlashError   = "error"
+)
+
+const flashKey = "_flash"
+
+// AddFlash adds a flash message to the current session,
+// it is usually read by Flashes in the next request.
+func AddFlash(c *znet.Context, level, message string) error {
+	s, err := Get(c)
+	if err != nil {
+		return err
+	}
+	flashes := append(readFlashes(s), Flash{Level: level, Message: message})
+	s.Set(flashKey, encodeFlashes(flashes))
+	return nil
+}
+
+// Flashes returns and removes the flash messages of the given levels,
+// all messages are returned when no level is given.
+func Flashes(c *znet.Context, levels ...string) []Flash {
+	s, err := Get(c)
+	if err != nil {
+		return nil
+	}
+	all := readFlashes(s)
+	if len(all) == 0 {
+		return nil
+	}
+
+	var got, rest []Flash
+	for _, f := range all {
+		if matchLevel(f.Level, levels) {
+			got = append(got, f)
+		} else {
+			rest = append(rest, f)
+		}
+	}
+	if len(got) == 0 {
+		return nil
+	}
+	if len(rest) == 0 {
+		_ = s.Delete(flashKey)
+	} else {
+		s.Set(flashKey, encodeFlashes(rest))
+	}
+	return got
+}
+
+func matchLevel(level string, levels []string) bool {
+	if len(levels) == 0 {
+		return true
+	}
+	for i := range levels {
+		if levels[i] == level {
+			return true
+		}
+	}
+	return false
+}
+
+// readFlashes decodes the messages, stores that serialize the session
+// return them as generic maps.
+func readFlashes(s Session) []Flash {
+	v := s.Get(flashKey)
+	if !v.Exists() {
+		return nil
+	}
+	maps := v.Maps()
+	flashes := make([]Flash, 0, len(maps))
+	for _, m := range maps {
+		flashes = append(flashes, Flash{Level: m.Get("level").String(), Message: m.Get("message").String()})
+	}
+	return flashes
+}
+
+func encodeFlashes(flashes []Flash) []map[string]interface{} {
+	v := make([]map[string]interface{}, 0, len(flashes))
+	for _, f := range flashes {
+		v = append(v, map[string]interface{}{"level": f.Level, "message": f.Message})
+	}
+	return v
+}
@@ -0,0 +1,86 @@
+package session_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/session"
+)
+
+func TestFlashAndRegenerate(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	fileStore, err := session.NewFileStore(t.TempDir())
+	tt.NoError(err, true)
+	cookieStore, _ := session.NewCookieStore("0123456789abcdef")
+	stores := map[string]session.Store{
+		"memory": session.NewMemoryStore(),
+		"file":   fileStore,
+		"cookie": cookieStore,
+	}
+
+	for name, store := range stores {
+		tt.Run(name, func(tt *zlsgo.TestUtil) {
+			r := znet.New("session-flash-" + name)
+			r.SetMode(znet.QuietMode)
+			r.Use(session.New(store))
+			r.GET("/login", func(c *znet.Context) {
+				s, _ := session.Get(c)
+				s.Set("user", "zls")
+				old := s.ID()
+				tt.NoError(s.Regenerate())
+				tt.EqualTrue(old != s.ID())
+				tt.NoError(session.AddFlash(c, session.FlashSuccess, "welcome"))
+				tt.NoError(session.AddFlash(c, session.FlashError, "oops"))
+				c.String(200, s.ID())
+			})
+			r.GET("/flash", func(c *znet.Context) {
+				s, _ := session.Get(c)
+				var msgs []string
+				for _, f := range session.Flashes(c, c.DefaultQuery("level", "")) {
+					msgs = append(msgs, f.Level+":"+f.Message)
+				}
+				c.String(200, s.Get("user").String()+" "+strings.Join(msgs, ","))
+			})
+			r.GET("/all", func(c *znet.Context) {
+				var msgs []string
+				for _, f := range session.Flashes(c) {
+					msgs = append(msgs, f.Level+":"+f.Message)
+				}
+				c.String(200, strings.Join(msgs, ","))
+			})
+
+			cl := &cookieClient{r: r, cookies: map[string]string{}}
+			if name != "cookie" {
+				cl.cookies["session_id"] = "fixated-session-id-1234567890abcdef"
+			}
+			w := cl.get("/login")
+			if name != "cookie" {
+				tt.Equal(w.Body.String(), cl.cookies["session_id"])
+			}
+
+			tt.Equal("zls success:welcome", cl.get("/flash?level=success").Body.String())
+			tt.Equal("zls ", cl.get("/flash?level=success").Body.String())
+			tt.Equal("error:oops", cl.get("/all").Body.String())
+			tt.Equal("", cl.get("/all").Body.String())
+		})
+	}
+}
+
+func TestRegenerateFixation(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	store := session.NewMemoryStore()
+	s, _ := store.New("fixated-session-id-1234567890abcdef", time.Now().Add(time.Hour))
+	s.Set("k", "v")
+	tt.NoError(s.Regenerate())
+
+	_, err := store.Get("fixated-session-id-1234567890abcdef")
+	tt.EqualTrue(err != nil)
+	s2, err := store.Get(s.ID())
+	tt.NoError(err, true)
+	tt.Equal("v", s2.Get("k").String())
+}
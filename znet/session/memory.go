@@ -92,6 +92,23 @@ func (s *Memory) Destroy() error {
 	return nil
 }
 
+// Regenerate moves the session to a new ID and keeps its data.
+func (s *Memory) Regenerate() error {
+	id, err := generateSessionID()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	old := s.id
+	s.id = id
+	s.mu.Unlock()
+	if s.store != nil {
+		s.store.sessions.Delete(old)
+		s.store.sessions.Store(id, s)
+	}
+	return nil
+}
+
 // MemoryStore implements the Store interface using an in-memory map.
 // It provides a simple, non-persistent session storage solution
 // suitable for development, testing, or single-instance applications.
@@ -19,6 +19,12 @@ type (
 		ExpiresAt  time.Duration
 		AutoRenew  bool
 	}
+
+	// requestStore is implemented by stores that keep the session in the request itself
+	requestStore interface {
+		load(c *znet.Context, name string, expiresAt time.Duration) (Session, error)
+		flush(c *znet.Context, name string, s Session, renew time.Time) error
+	}
 )
 
 // Default creates a new session handler with the default memory store.
@@ -46,6 +52,22 @@ func New(stores Store, opt ...func(*Config)) znet.Handler {
 				})
 			}
 		})
+		if rs, ok := stores.(requestStore); ok {
+			s, err := rs.load(c, conf.CookieName, conf.ExpiresAt)
+			if err != nil {
+				return err
+			}
+			_ = c.Injector().Map(s)
+
+			_ = c.Next()
+
+			var renew time.Time
+			if conf.AutoRenew && time.Until(s.ExpiresAt()) < conf.ExpiresAt/2 {
+				renew = time.Now().Add(conf.ExpiresAt)
+			}
+			return rs.flush(c, conf.CookieName, s, renew)
+		}
+
 		id := c.GetCookie(conf.CookieName)
 		if id == "" {
 			var err error
@@ -76,6 +98,15 @@ func New(stores Store, opt ...func(*Config)) znet.Handler {
 
 		_ = c.Next()
 
+		if err := stores.Save(s); err != nil {
+			c.Log.Error("session:", err)
+		}
+
+		if newID := s.ID(); newID != id {
+			id = newID
+			c.SetCookie(conf.CookieName, id, int(time.Until(s.ExpiresAt()).Seconds()))
+		}
+
 		if conf.AutoRenew && time.Until(s.ExpiresAt()) < conf.ExpiresAt/2 {
 			stores.Renew(id, time.Now().Add(conf.ExpiresAt))
 		}
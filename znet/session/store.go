@@ -19,6 +19,9 @@ type Session interface {
 	Save() error
 	Destroy() error
 	ExpiresAt() time.Time
+	// Regenerate issues a new session ID and keeps the data, call it after
+	// login or privilege changes to defend against session fixation.
+	Regenerate() error
 }
 
 // Store defines the interface for session storage backends.
@@ -0,0 +1,377 @@
+package znet
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/sohaha/zlsgo/zjson"
+	"github.com/sohaha/zlsgo/ztype"
+)
+
+type (
+	// TestClient executes requests against the engine in memory without a listener,
+	// cookies set by responses are sent with the following requests
+	TestClient struct {
+		engine  *Engine
+		tt      TestAssert
+		cookies map[string]*http.Cookie
+		header  http.Header
+		mu      sync.Mutex
+	}
+	// TestRequest is a request built by TestClient
+	TestRequest struct {
+		client      *TestClient
+		query       url.Values
+		header      http.Header
+		form        url.Values
+		body        io.Reader
+		method      string
+		path        string
+		contentType string
+		files       []testFile
+		cookies     []*http.Cookie
+		err         error
+	}
+	// TestAssert reports the failed expectations of TestResponse, *zlsgo.TestUtil implements it
+	TestAssert interface {
+		Equal(expected, actual interface{}, exit ...bool) bool
+		Contains(expected, actual string, exit ...bool) bool
+		Fatal(v ...interface{})
+	}
+	// TestResponse is the recorded response of a TestRequest
+	TestResponse struct {
+		tt          TestAssert
+		header      http.Header
+		Body        *bytes.Buffer
+		Code        int
+		wroteHeader bool
+	}
+	testFile struct {
+		field    string
+		filename string
+		content  []byte
+	}
+)
+
+// Test returns an in-memory client of the engine, pass tt to use the assertion helpers of TestResponse
+func (e *Engine) Test(tt ...TestAssert) *TestClient {
+	c := &TestClient{engine: e, cookies: make(map[string]*http.Cookie), header: make(http.Header)}
+	if len(tt) > 0 {
+		c.tt = tt[0]
+	}
+	return c
+}
+
+// SetHeader sets a header sent with every request of the client
+func (tc *TestClient) SetHeader(key, value string) *TestClient {
+	tc.mu.Lock()
+	tc.header.Set(key, value)
+	tc.mu.Unlock()
+	return tc
+}
+
+// Cookie returns the value of a cookie kept by the client
+func (tc *TestClient) Cookie(name string) string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if c, ok := tc.cookies[name]; ok {
+		return c.Value
+	}
+	return ""
+}
+
+// ClearCookies removes the cookies kept by the client
+func (tc *TestClient) ClearCookies() *TestClient {
+	tc.mu.Lock()
+	tc.cookies = make(map[string]*http.Cookie)
+	tc.mu.Unlock()
+	return tc
+}
+
+// Request starts a request with the method and path, path may contain a query
+func (tc *TestClient) Request(method, path string) *TestRequest {
+	return &TestRequest{
+		client: tc,
+		method: strings.ToUpper(method),
+		path:   path,
+		query:  url.Values{},
+		header: http.Header{},
+		form:   url.Values{},
+	}
+}
+
+// GET starts a GET request
+func (tc *TestClient) GET(path string) *TestRequest {
+	return tc.Request(http.MethodGet, path)
+}
+
+// POST starts a POST request
+func (tc *TestClient) POST(path string) *TestRequest {
+	return tc.Request(http.MethodPost, path)
+}
+
+// PUT starts a PUT request
+func (tc *TestClient) PUT(path string) *TestRequest {
+	return tc.Request(http.MethodPut, path)
+}
+
+// PATCH starts a PATCH request
+func (tc *TestClient) PATCH(path string) *TestRequest {
+	return tc.Request(http.MethodPatch, path)
+}
+
+// DELETE starts a DELETE request
+func (tc *TestClient) DELETE(path string) *TestRequest {
+	return tc.Request(http.MethodDelete, path)
+}
+
+// HEAD starts a HEAD request
+func (tc *TestClient) HEAD(path string) *TestRequest {
+	return tc.Request(http.MethodHead, path)
+}
+
+// OPTIONS starts an OPTIONS request
+func (tc *TestClient) OPTIONS(path string) *TestRequest {
+	return tc.Request(http.MethodOptions, path)
+}
+
+// Query adds a query parameter
+func (r *TestRequest) Query(key, value string) *TestRequest {
+	r.query.Add(key, value)
+	return r
+}
+
+// Header sets a request header
+func (r *TestRequest) Header(key, value string) *TestRequest {
+	r.header.Set(key, value)
+	return r
+}
+
+// Cookie adds a cookie to this request only
+func (r *TestRequest) Cookie(name, value string) *TestRequest {
+	r.cookies = append(r.cookies, &http.Cookie{Name: name, Value: value})
+	return r
+}
+
+// JSON sets the body to the JSON encoding of v
+func (r *TestRequest) JSON(v interface{}) *TestRequest {
+	var b []byte
+	switch val := v.(type) {
+	case string:
+		b = []byte(val)
+	case []byte:
+		b = val
+	default:
+		b, r.err = zjson.Marshal(v)
+	}
+	return r.Body(b, mimeJSON)
+}
+
+// Form adds a form field, the body is url encoded unless a file is added
+func (r *TestRequest) Form(key, value string) *TestRequest {
+	r.form.Add(key, value)
+	return r
+}
+
+// File adds a file, the body is sent as multipart/form-data
+func (r *TestRequest) File(field, filename string, content []byte) *TestRequest {
+	r.files = append(r.files, testFile{field: field, filename: filename, content: content})
+	return r
+}
+
+// Body sets the raw body and its content type
+func (r *TestRequest) Body(body []byte, contentType string) *TestRequest {
+	r.body = bytes.NewReader(body)
+	r.contentType = contentType
+	return r
+}
+
+// Do executes the request against the engine
+func (r *TestRequest) Do() *TestResponse {
+	tc := r.client
+	res := &TestResponse{header: make(http.Header), Body: new(bytes.Buffer), Code: http.StatusOK, tt: tc.tt}
+
+	body, contentType, err := r.encodeBody()
+	if err == nil {
+		err = r.err
+	}
+	if err != nil {
+		tc.fail(err)
+		return res
+	}
+
+	target := r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	req, err := http.NewRequest(r.method, target, body)
+	if err != nil {
+		tc.fail(err)
+		return res
+	}
+	req.Host, req.RequestURI, req.RemoteAddr = "example.com", target, "192.0.2.1:1234"
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	tc.mu.Lock()
+	for k, v := range tc.header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	for _, c := range tc.cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	tc.mu.Unlock()
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	for _, c := range r.cookies {
+		req.AddCookie(c)
+	}
+
+	tc.engine.ServeHTTP(res, req)
+
+	tc.mu.Lock()
+	for _, c := range res.Cookies() {
+		if c.MaxAge < 0 || c.Value == "" {
+			delete(tc.cookies, c.Name)
+		} else {
+			tc.cookies[c.Name] = c
+		}
+	}
+	tc.mu.Unlock()
+	return res
+}
+
+func (r *TestRequest) encodeBody() (io.Reader, string, error) {
+	if len(r.files) == 0 {
+		if len(r.form) > 0 && r.body == nil {
+			return strings.NewReader(r.form.Encode()), mimePOSTForm, nil
+		}
+		return r.body, r.contentType, nil
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, values := range r.form {
+		for _, v := range values {
+			if err := w.WriteField(k, v); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for _, f := range r.files {
+		part, err := w.CreateFormFile(f.field, f.filename)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(f.content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+func (tc *TestClient) fail(err error) {
+	if tc.tt == nil {
+		panic(err)
+	}
+	tc.tt.Fatal(err)
+}
+
+// Header returns the response header
+func (res *TestResponse) Header() http.Header {
+	return res.header
+}
+
+// WriteHeader records the status code
+func (res *TestResponse) WriteHeader(code int) {
+	if res.wroteHeader {
+		return
+	}
+	res.wroteHeader = true
+	res.Code = code
+}
+
+// Write records the body
+func (res *TestResponse) Write(b []byte) (int, error) {
+	res.WriteHeader(http.StatusOK)
+	return res.Body.Write(b)
+}
+
+// WriteString records the body
+func (res *TestResponse) WriteString(s string) (int, error) {
+	res.WriteHeader(http.StatusOK)
+	return res.Body.WriteString(s)
+}
+
+// Flush implements http.Flusher
+func (res *TestResponse) Flush() {
+	res.WriteHeader(http.StatusOK)
+}
+
+// Cookies returns the cookies set by the response
+func (res *TestResponse) Cookies() []*http.Cookie {
+	return (&http.Response{Header: res.header}).Cookies()
+}
+
+// JSON returns the value of the JSON body at path, see zjson.Get
+func (res *TestResponse) JSON(path string) *zjson.Res {
+	return zjson.GetBytes(res.Body.Bytes(), path)
+}
+
+// ExpectStatus asserts the status code
+func (res *TestResponse) ExpectStatus(code int) *TestResponse {
+	res.assert().Equal(code, res.Code)
+	return res
+}
+
+// ExpectHeader asserts the value of a response header
+func (res *TestResponse) ExpectHeader(key, value string) *TestResponse {
+	res.assert().Equal(value, res.Header().Get(key))
+	return res
+}
+
+// ExpectBody asserts the body
+func (res *TestResponse) ExpectBody(body string) *TestResponse {
+	res.assert().Equal(body, res.Body.String())
+	return res
+}
+
+// ExpectBodyContains asserts the body contains s
+func (res *TestResponse) ExpectBodyContains(s string) *TestResponse {
+	res.assert().Contains(s, res.Body.String())
+	return res
+}
+
+// ExpectJSON asserts the value at the JSON path, values are compared as strings
+// so numbers match regardless of their Go type
+func (res *TestResponse) ExpectJSON(path string, value interface{}) *TestResponse {
+	v := res.JSON(path)
+	tt := res.assert()
+	if !v.Exists() {
+		tt.Equal(value, nil)
+		return res
+	}
+	tt.Equal(ztype.ToString(value), v.String())
+	return res
+}
+
+func (res *TestResponse) assert() TestAssert {
+	if res.tt == nil {
+		panic("znet: the test client is created without a TestAssert")
+	}
+	return res.tt
+}
@@ -0,0 +1,104 @@
+package znet
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/ztype"
+)
+
+type recordAssert struct {
+	failures []string
+}
+
+func (a *recordAssert) Equal(expected, actual interface{}, _ ...bool) bool {
+	if fmt.Sprint(expected) != fmt.Sprint(actual) {
+		a.failures = append(a.failures, fmt.Sprint(expected, " != ", actual))
+		return false
+	}
+	return true
+}
+
+func (a *recordAssert) Contains(expected, actual string, _ ...bool) bool {
+	if !strings.Contains(actual, expected) {
+		a.failures = append(a.failures, expected)
+		return false
+	}
+	return true
+}
+
+func (a *recordAssert) Fatal(v ...interface{}) {
+	a.failures = append(a.failures, fmt.Sprint(v...))
+}
+
+func TestTestClient(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	r := New("test-client-test")
+	r.SetMode(QuietMode)
+	r.GET("/login", func(c *Context) {
+		c.SetCookie("user", c.DefaultQuery("name", ""))
+		c.String(200, "ok")
+	})
+	r.GET("/logout", func(c *Context) {
+		c.SetCookie("user", "", -1)
+		c.String(200, "bye")
+	})
+	r.GET("/me", func(c *Context) {
+		tags, _ := c.GetQueryArray("tag")
+		c.SetHeader("X-Token", c.GetHeader("Authorization"))
+		c.JSON(200, ztype.Map{"user": c.GetCookie("user"), "tags": tags})
+	})
+	r.POST("/json", func(c *Context) {
+		c.JSON(201, ztype.Map{"name": c.GetJSON("name").String(), "age": c.GetJSON("age").Int()})
+	})
+	r.PUT("/form", func(c *Context) {
+		c.String(200, c.DefaultPostForm("a", "")+c.DefaultPostForm("b", ""))
+	})
+	r.POST("/upload", func(c *Context) {
+		f, err := c.FormFile("file")
+		if err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		fd, _ := f.Open()
+		defer fd.Close()
+		b, _ := io.ReadAll(fd)
+		c.String(200, c.DefaultPostForm("dir", "")+"/"+f.Filename+":"+string(b))
+	})
+
+	client := r.Test(tt).SetHeader("Authorization", "Bearer x")
+
+	client.GET("/login").Query("name", "zls").Do().ExpectStatus(200).ExpectBody("ok")
+	tt.Equal("zls", client.Cookie("user"))
+
+	res := client.GET("/me").Query("tag", "a").Query("tag", "b").Do()
+	res.ExpectStatus(200).
+		ExpectHeader("X-Token", "Bearer x").
+		ExpectJSON("user", "zls").
+		ExpectJSON("tags.1", "b")
+	tt.Equal(2, len(res.JSON("tags").Array()))
+
+	client.GET("/me").Cookie("user", "other").Do().ExpectJSON("user", "zls")
+	client.GET("/logout").Do().ExpectBody("bye")
+	tt.Equal("", client.Cookie("user"))
+	client.GET("/me").Do().ExpectJSON("user", "")
+
+	client.POST("/json").JSON(map[string]interface{}{"name": "zlsgo", "age": 18}).Do().
+		ExpectStatus(201).ExpectJSON("name", "zlsgo").ExpectJSON("age", 18)
+	client.PUT("/form").Form("a", "1").Form("b", "2").Do().ExpectBody("12")
+	client.POST("/upload").Form("dir", "tmp").File("file", "a.txt", []byte("hello")).Do().
+		ExpectStatus(200).ExpectBody("tmp/a.txt:hello")
+	client.Request("delete", "/none").Do().ExpectStatus(404)
+
+	tt.Panics(func() {
+		r.Test().GET("/me").Do().ExpectStatus(200)
+	})
+
+	assert := &recordAssert{}
+	r.Test(assert).GET("/me").Do().ExpectStatus(200).ExpectStatus(500).ExpectBodyContains("none")
+	tt.Equal([]string{"500 != 200", "none"}, assert.failures)
+}
@@ -0,0 +1,60 @@
+package trace
+
+import (
+	"sync"
+
+	"github.com/sohaha/zlsgo/zlog"
+)
+
+type (
+	// Exporter receives the spans once they ended
+	Exporter interface {
+		Export(span *Span)
+	}
+	// ExporterFunc adapts a function to the Exporter interface
+	ExporterFunc func(span *Span)
+	// MemoryExporter keeps the exported spans in memory, mainly for tests
+	MemoryExporter struct {
+		spans []*Span
+		mu    sync.RWMutex
+	}
+)
+
+// Export calls the function
+func (f ExporterFunc) Export(span *Span) {
+	f(span)
+}
+
+// NewMemoryExporter creates an in-memory exporter
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// Export stores the span
+func (m *MemoryExporter) Export(span *Span) {
+	m.mu.Lock()
+	m.spans = append(m.spans, span)
+	m.mu.Unlock()
+}
+
+// Spans returns the exported spans in export order
+func (m *MemoryExporter) Spans() []*Span {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*Span(nil), m.spans...)
+}
+
+// Reset removes all exported spans
+func (m *MemoryExporter) Reset() {
+	m.mu.Lock()
+	m.spans = nil
+	m.mu.Unlock()
+}
+
+// LogExporter writes every span as a debug line of the logger
+func LogExporter(log *zlog.Logger) Exporter {
+	return ExporterFunc(func(span *Span) {
+		log.Debugf("trace=%s span=%s parent=%s name=%q status=%d duration=%s attributes=%v\n",
+			span.TraceID, span.SpanID, span.ParentID, span.Name, span.Status, span.Duration, span.Attributes())
+	})
+}
@@ -0,0 +1,178 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Span is a timed operation within a trace
+	Span struct {
+		Start      time.Time
+		attributes map[string]interface{}
+		exporter   Exporter
+		Name       string
+		RequestID  string
+		TraceID    string
+		SpanID     string
+		ParentID   string
+		Duration   time.Duration
+		Status     int
+		mu         sync.RWMutex
+		Sampled    bool
+		ended      bool
+	}
+	spanKey struct{}
+)
+
+// NewTraceID returns a random W3C trace ID
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID returns a random W3C span ID
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for {
+		_, _ = rand.Read(b)
+		for i := range b {
+			if b[i] != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
+
+// StartSpan starts a span as a child of the span in ctx, or a new trace when there is none,
+// the span is exported when it ends
+func StartSpan(ctx context.Context, name string, exporter ...Exporter) (*Span, context.Context) {
+	span := &Span{
+		Name:    name,
+		SpanID:  NewSpanID(),
+		Start:   time.Now(),
+		Sampled: true,
+	}
+	if parent, ok := FromContext(ctx); ok {
+		span.TraceID = parent.TraceID
+		span.RequestID = parent.RequestID
+		span.ParentID = parent.SpanID
+		span.Sampled = parent.Sampled
+		span.exporter = parent.exporter
+	} else {
+		span.TraceID = NewTraceID()
+		span.RequestID = span.TraceID
+	}
+	if len(exporter) > 0 {
+		span.exporter = exporter[0]
+	}
+	return span, ContextWithSpan(ctx, span)
+}
+
+// ContextWithSpan returns a copy of ctx carrying the span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// FromContext returns the span carried by ctx
+func FromContext(ctx context.Context) (*Span, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	span, ok := ctx.Value(spanKey{}).(*Span)
+	return span, ok
+}
+
+// SetAttribute records a key value pair on the span
+func (s *Span) SetAttribute(key string, value interface{}) *Span {
+	s.mu.Lock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{}, 4)
+	}
+	s.attributes[key] = value
+	s.mu.Unlock()
+	return s
+}
+
+// Attributes returns a copy of the span attributes
+func (s *Span) Attributes() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attributes := make(map[string]interface{}, len(s.attributes))
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+	return attributes
+}
+
+// SetStatus sets the status of the span, HTTP spans use the response status code
+func (s *Span) SetStatus(status int) *Span {
+	s.mu.Lock()
+	s.Status = status
+	s.mu.Unlock()
+	return s
+}
+
+// End finishes the span and hands it to the exporter, calling End again has no effect
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.Duration = time.Since(s.Start)
+	s.mu.Unlock()
+
+	if s.exporter != nil && s.Sampled {
+		s.exporter.Export(s)
+	}
+}
+
+// Traceparent formats the span as a W3C traceparent header value
+func (s *Span) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value
+func ParseTraceparent(value string) (traceID, parentID string, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", "", false, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false, false
+	}
+	if !isHex(parts[0], 2) || !isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+		return "", "", false, false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false, false
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return parts[1], parts[2], flags[0]&1 == 1, true
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+// Package trace provides request ID and W3C trace context propagation for znet and zhttp
+package trace
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sohaha/zlsgo/zhttp"
+	"github.com/sohaha/zlsgo/znet"
+)
+
+type (
+	// Config configuration
+	Config struct {
+		// Exporter receives the server spans, spans are not exported when nil
+		Exporter Exporter
+		// Name of the server span, default is the method and path of the request
+		Name func(c *znet.Context) string
+		// Skipper skips tracing for the request when it returns true
+		Skipper func(c *znet.Context) bool
+		// Header carrying the request ID, default is X-Request-Id
+		Header string
+		// IgnoreIncoming starts a new trace instead of trusting the incoming headers
+		IgnoreIncoming bool
+		// DisableLogPrefix stops adding the request ID to Context.Log
+		DisableLogPrefix bool
+	}
+)
+
+const (
+	// TraceparentHeader is the W3C trace context header
+	TraceparentHeader = "traceparent"
+	// ContextKey is the key of the span stored on znet.Context
+	ContextKey  = "trace_span"
+	maxIDLength = 128
+)
+
+// New returns a middleware that continues the incoming trace or starts a new one,
+// the request ID is written to the response header and prefixes every Context.Log line
+func New(opt ...func(conf *Config)) znet.HandlerFunc {
+	conf := Config{
+		Header: "X-Request-Id",
+		Name: func(c *znet.Context) string {
+			return c.Request.Method + " " + c.Request.URL.Path
+		},
+	}
+	for _, o := range opt {
+		o(&conf)
+	}
+
+	return func(c *znet.Context) {
+		if conf.Skipper != nil && conf.Skipper(c) {
+			c.Next()
+			return
+		}
+
+		span := &Span{
+			Name:     conf.Name(c),
+			SpanID:   NewSpanID(),
+			Start:    time.Now(),
+			Sampled:  true,
+			exporter: conf.Exporter,
+		}
+		if !conf.IgnoreIncoming {
+			if traceID, parentID, sampled, ok := ParseTraceparent(c.GetHeader(TraceparentHeader)); ok {
+				span.TraceID, span.ParentID, span.Sampled = traceID, parentID, sampled
+			}
+			if id := c.GetHeader(conf.Header); validRequestID(id) {
+				span.RequestID = id
+			}
+		}
+		if span.TraceID == "" {
+			span.TraceID = NewTraceID()
+		}
+		if span.RequestID == "" {
+			span.RequestID = span.TraceID
+		}
+
+		c.WithValue(ContextKey, span)
+		c.Request = c.Request.WithContext(ContextWithSpan(c.Request.Context(), span))
+		c.SetHeader(conf.Header, span.RequestID)
+		if !conf.DisableLogPrefix && c.Log != nil {
+			c.Log = c.Log.WithPrefix("[" + span.RequestID + "] ")
+		}
+
+		defer func() {
+			status := int(c.PrevContent().Code.Load())
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.method", c.Request.Method).
+				SetAttribute("http.target", c.Request.URL.RequestURI()).
+				SetAttribute("http.client_ip", c.GetClientIP()).
+				SetStatus(status).
+				End()
+		}()
+		c.Next()
+	}
+}
+
+// Get returns the span of the request
+func Get(c *znet.Context) (*Span, bool) {
+	if v, ok := c.Value(ContextKey); ok {
+		span, ok := v.(*Span)
+		return span, ok
+	}
+	return FromContext(c.Request.Context())
+}
+
+// RequestID returns the request ID of the request, empty when it is not traced
+func RequestID(c *znet.Context) string {
+	if span, ok := Get(c); ok {
+		return span.RequestID
+	}
+	return ""
+}
+
+// Propagate makes the zhttp engine forward the trace of the request context
+// on outgoing calls, pass the incoming request context as a request argument
+func Propagate(e *zhttp.Engine, header ...string) {
+	h := "X-Request-Id"
+	if len(header) > 0 && header[0] != "" {
+		h = header[0]
+	}
+	e.BeforeRequest(func(req *http.Request) {
+		span, ok := FromContext(req.Context())
+		if !ok {
+			return
+		}
+		req.Header.Set(TraceparentHeader, span.Traceparent())
+		if span.RequestID != "" {
+			req.Header.Set(h, span.RequestID)
+		}
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
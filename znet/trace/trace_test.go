@@ -0,0 +1,108 @@
+package trace_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/zhttp"
+	"github.com/sohaha/zlsgo/zlog"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/trace"
+)
+
+func TestTrace(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	var received http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer downstream.Close()
+
+	client := zhttp.New()
+	trace.Propagate(client)
+
+	var buf bytes.Buffer
+	exporter := trace.NewMemoryExporter()
+	r := znet.New("trace-test")
+	r.SetMode(znet.QuietMode)
+	r.Log = zlog.NewZLog(&buf, "", 0, zlog.LogDump, false, 3)
+	r.Use(trace.New(func(conf *trace.Config) {
+		conf.Exporter = exporter
+	}))
+	r.GET("/user", func(c *znet.Context) {
+		c.Log.Info("handling")
+		span, ctx := trace.StartSpan(c.Request.Context(), "load user")
+		span.SetAttribute("user.id", 1)
+		_, _ = client.Get(downstream.URL, ctx)
+		span.End()
+		c.String(201, trace.RequestID(c))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-Id", "req-1")
+	r.ServeHTTP(w, req)
+
+	tt.Equal(201, w.Code)
+	tt.Equal("req-1", w.Header().Get("X-Request-Id"))
+	tt.Equal("req-1", w.Body.String())
+	tt.Equal("[req-1] handling\n", buf.String())
+
+	spans := exporter.Spans()
+	tt.Equal(2, len(spans))
+	child, server := spans[0], spans[1]
+	tt.Equal("GET /user", server.Name)
+	tt.Equal(201, server.Status)
+	tt.Equal("4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID)
+	tt.Equal("00f067aa0ba902b7", server.ParentID)
+	tt.Equal("GET", server.Attributes()["http.method"])
+	tt.Equal("load user", child.Name)
+	tt.Equal(server.TraceID, child.TraceID)
+	tt.Equal(server.SpanID, child.ParentID)
+	tt.Equal(1, child.Attributes()["user.id"])
+
+	tt.Equal(child.Traceparent(), received.Get("traceparent"))
+	tt.Equal("req-1", received.Get("X-Request-Id"))
+
+	exporter.Reset()
+	buf.Reset()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/user", nil)
+	req.Header.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	r.ServeHTTP(w, req)
+	id := w.Header().Get("X-Request-Id")
+	tt.Equal(32, len(id))
+	tt.EqualTrue(strings.HasPrefix(buf.String(), "["+id+"] "))
+	spans = exporter.Spans()
+	tt.Equal(id, spans[1].TraceID)
+	tt.Equal("", spans[1].ParentID)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	traceID, parentID, sampled, ok := trace.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	tt.EqualTrue(ok)
+	tt.EqualFalse(sampled)
+	tt.Equal("4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	tt.Equal("00f067aa0ba902b7", parentID)
+
+	for _, v := range []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, _, _, ok = trace.ParseTraceparent(v)
+		tt.EqualFalse(ok)
+	}
+	_, _, _, ok = trace.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	tt.EqualTrue(ok)
+}
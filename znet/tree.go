@@ -1,6 +1,7 @@
 package znet
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -19,6 +20,7 @@ type (
 	// Nodes can represent static path segments or pattern parameters.
 	Node struct {
 		value      interface{}      // Custom data associated with this node
+		action     Handler          // Handler as registered, before adaptation
 		handle     handlerFn        // Handler function for this route
 		children   map[string]*Node // Child nodes indexed by path segment
 		engine     *Engine          // Reference to the engine instance
@@ -62,6 +64,12 @@ func (t *Node) Handle() handlerFn {
 	return t.handle
 }
 
+// Action returns the handler as it was registered for this route,
+// which keeps its original signature for inspection.
+func (t *Node) Action() Handler {
+	return t.action
+}
+
 // NewTree creates a new routing tree with a root node.
 // The root node represents the '/' path and serves as the starting point
 // for all route matching operations.
@@ -169,3 +177,23 @@ func (t *Tree) Find(pattern string, isRegex bool) (nodes []*Node) {
 	}
 	return
 }
+
+// Nodes returns all nodes of the tree that have a handler, sorted by path.
+func (t *Tree) Nodes() []*Node {
+	nodes := make([]*Node, 0)
+	queue := []*Node{t.root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.isPattern && n.handle != nil {
+			nodes = append(nodes, n)
+		}
+		for _, child := range n.children {
+			queue = append(queue, child)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].path < nodes[j].path
+	})
+	return nodes
+}
@@ -0,0 +1,553 @@
+// Package tus implements the server side of the tus 1.0 resumable upload protocol
+// (core, creation, creation-with-upload, termination and expiration) on a local directory
+package tus
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/zfile"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+const (
+	// Version the supported protocol version
+	Version = "1.0.0"
+	// Extensions the supported protocol extensions
+	Extensions = "creation,creation-with-upload,termination,expiration"
+	// ContentType of PATCH request bodies
+	ContentType = "application/offset+octet-stream"
+)
+
+const (
+	infoExt = ".info"
+	dataExt = ".bin"
+	lockExt = ".lock"
+)
+
+type (
+	// Config tus configuration
+	// MaxSize: the maximum size of an upload, zero means unlimited
+	// Expiration: how long an unfinished upload is kept after its last PATCH, zero disables it
+	// CollectInterval: interval of removing expired uploads, zero disables it
+	// LockTimeout: how long a request waits for an upload locked by another request
+	// OnComplete: called in the request that finished the upload, the data is at Tus.Path(info.ID)
+	Config struct {
+		OnComplete      func(c *znet.Context, info *Info) error
+		MaxSize         int64
+		Expiration      time.Duration
+		CollectInterval time.Duration
+		LockTimeout     time.Duration
+	}
+
+	// Info the state of an upload
+	Info struct {
+		ExpiresAt time.Time         `json:"expires_at"`
+		Metadata  map[string]string `json:"metadata,omitempty"`
+		ID        string            `json:"id"`
+		Size      int64             `json:"size"`
+		Offset    int64             `json:"offset"`
+	}
+
+	// Tus stores uploads as a data and an info file in a directory, requests on
+	// the same upload are serialized across processes with zfile.FileLock
+	Tus struct {
+		stop     chan struct{}
+		dir      string
+		conf     Config
+		wg       sync.WaitGroup
+		stopOnce sync.Once
+	}
+)
+
+var (
+	// ErrNotFound the upload does not exist or has expired
+	ErrNotFound = errors.New("tus: upload not found")
+	// ErrLocked the upload is being written by another request
+	ErrLocked = errors.New("tus: upload is locked")
+	// ErrOffset the Upload-Offset does not match the offset of the upload
+	ErrOffset = errors.New("tus: upload offset mismatch")
+	// ErrTooLarge the upload exceeds its length or MaxSize
+	ErrTooLarge = errors.New("tus: upload too large")
+)
+
+// Complete reports whether all bytes of the upload are received
+func (info *Info) Complete() bool {
+	return info.Offset == info.Size
+}
+
+// New creates a tus handler storing uploads in dir
+func New(dir string, opt ...func(conf *Config)) (*Tus, error) {
+	conf := zutil.Optional(Config{
+		Expiration:      24 * time.Hour,
+		CollectInterval: 10 * time.Minute,
+		LockTimeout:     3 * time.Second,
+	}, opt...)
+
+	dir = zfile.RealPathMkdir(dir, true)
+	if !zfile.DirExist(dir) {
+		return nil, errors.New("tus: can not create directory " + dir)
+	}
+
+	t := &Tus{dir: dir, conf: conf}
+	if conf.Expiration > 0 && conf.CollectInterval > 0 {
+		t.stop = make(chan struct{})
+		t.wg.Add(1)
+		go t.collectLoop(conf.CollectInterval)
+	}
+	return t, nil
+}
+
+// Register registers the tus endpoints under path, uploads are created with
+// POST path and addressed by the returned Location path/:upload, clients that can
+// only send GET and POST need Engine.SetCustomMethodField("X-HTTP-Method-Override")
+func (t *Tus) Register(e *znet.Engine, path string) {
+	path = strings.TrimSuffix(path, "/")
+	e.OPTIONS(path, t.options)
+	e.POST(path, t.handle)
+	e.OPTIONS(path+"/:upload", t.options)
+	e.HEAD(path+"/:upload", t.handle)
+	e.PATCH(path+"/:upload", t.handle)
+	e.DELETE(path+"/:upload", t.handle)
+}
+
+// Path returns the data file of the upload
+func (t *Tus) Path(id string) string {
+	return filepath.Join(t.dir, id+dataExt)
+}
+
+// Get returns the state of the upload
+func (t *Tus) Get(id string) (*Info, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	info, err := t.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return info, nil
+}
+
+// Terminate removes the upload and its data
+func (t *Tus) Terminate(id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	l, err := t.lock(id)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = l.Unlock() }()
+
+	if _, err = t.read(id); err != nil {
+		return err
+	}
+	t.remove(id)
+	return nil
+}
+
+// Collect removes the files of all expired uploads
+func (t *Tus) Collect() error {
+	files, err := filepath.Glob(filepath.Join(t.dir, "*"+infoExt))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range files {
+		id := strings.TrimSuffix(filepath.Base(name), infoExt)
+		info, err := t.read(id)
+		if err != nil || !info.expired(now) {
+			continue
+		}
+		l := zfile.NewFileLock(filepath.Join(t.dir, id+lockExt))
+		if l.Lock() != nil {
+			continue
+		}
+		if info, err = t.read(id); err == nil && info.expired(now) {
+			t.remove(id)
+		}
+		_ = l.Unlock()
+	}
+	return nil
+}
+
+// Close stops the background collection
+func (t *Tus) Close() error {
+	if t == nil || t.stop == nil {
+		return nil
+	}
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+	t.wg.Wait()
+	return nil
+}
+
+func (t *Tus) collectLoop(interval time.Duration) {
+	defer t.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			_ = t.Collect()
+		}
+	}
+}
+
+func (t *Tus) options(c *znet.Context) {
+	c.SetHeader("Tus-Resumable", Version)
+	c.SetHeader("Tus-Version", Version)
+	c.SetHeader("Tus-Extension", Extensions)
+	if t.conf.MaxSize > 0 {
+		c.SetHeader("Tus-Max-Size", strconv.FormatInt(t.conf.MaxSize, 10))
+	}
+	c.Abort(http.StatusNoContent)
+}
+
+func (t *Tus) handle(c *znet.Context) {
+	c.SetHeader("Tus-Resumable", Version)
+	if c.GetHeader("Tus-Resumable") != Version {
+		c.SetHeader("Tus-Version", Version)
+		c.String(http.StatusPreconditionFailed, "unsupported version")
+		return
+	}
+
+	id := c.GetParam("upload")
+	if id == "" {
+		t.create(c)
+		return
+	}
+
+	if !validID(id) {
+		c.String(http.StatusNotFound, ErrNotFound.Error())
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodHead:
+		t.head(c, id)
+	case http.MethodPatch:
+		t.patch(c, id)
+	case http.MethodDelete:
+		t.terminate(c, id)
+	default:
+		c.String(http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+func (t *Tus) create(c *znet.Context) {
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	if t.conf.MaxSize > 0 && size > t.conf.MaxSize {
+		c.String(http.StatusRequestEntityTooLarge, ErrTooLarge.Error())
+		return
+	}
+	metadata, err := parseMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info := &Info{ID: newID(), Size: size, Metadata: metadata}
+	l, err := t.lock(info.ID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer func() { _ = l.Unlock() }()
+
+	f, err := os.OpenFile(t.Path(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	_ = f.Close()
+	t.touch(info)
+	if err = t.write(info); err != nil {
+		t.remove(info.ID)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.SetHeader("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+info.ID)
+	if c.GetHeader("Content-Type") == ContentType {
+		if !t.append(c, info) {
+			return
+		}
+		c.SetHeader("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	} else if info.Complete() && !t.complete(c, info) {
+		return
+	}
+	t.setExpires(c, info)
+	c.Abort(http.StatusCreated)
+}
+
+func (t *Tus) head(c *znet.Context, id string) {
+	info, err := t.Get(id)
+	if err != nil {
+		t.fail(c, err)
+		return
+	}
+	c.SetHeader("Cache-Control", "no-store")
+	c.SetHeader("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	c.SetHeader("Upload-Length", strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		c.SetHeader("Upload-Metadata", formatMetadata(info.Metadata))
+	}
+	t.setExpires(c, info)
+	c.Abort(http.StatusOK)
+}
+
+func (t *Tus) patch(c *znet.Context, id string) {
+	if c.GetHeader("Content-Type") != ContentType {
+		c.String(http.StatusUnsupportedMediaType, "Content-Type must be "+ContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+
+	l, err := t.lock(id)
+	if err != nil {
+		t.fail(c, err)
+		return
+	}
+	defer func() { _ = l.Unlock() }()
+
+	info, err := t.Get(id)
+	if err != nil {
+		t.fail(c, err)
+		return
+	}
+	if info.Offset != offset {
+		c.SetHeader("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+		t.fail(c, ErrOffset)
+		return
+	}
+	if !t.append(c, info) {
+		return
+	}
+	c.SetHeader("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	t.setExpires(c, info)
+	c.Abort(http.StatusNoContent)
+}
+
+func (t *Tus) terminate(c *znet.Context, id string) {
+	if err := t.Terminate(id); err != nil {
+		t.fail(c, err)
+		return
+	}
+	c.Abort(http.StatusNoContent)
+}
+
+// append writes the request body at the offset of the upload, the received
+// bytes are kept even if the client goes away so it can resume from there
+func (t *Tus) append(c *znet.Context, info *Info) bool {
+	remaining := info.Size - info.Offset
+	if c.Request.ContentLength > remaining {
+		t.fail(c, ErrTooLarge)
+		return false
+	}
+
+	f, err := os.OpenFile(t.Path(info.ID), os.O_WRONLY, 0o600)
+	if err != nil {
+		t.fail(c, err)
+		return false
+	}
+	if _, err = f.Seek(info.Offset, io.SeekStart); err == nil {
+		var n int64
+		n, err = io.Copy(f, io.LimitReader(c.Request.Body, remaining))
+		info.Offset += n
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	t.touch(info)
+	if werr := t.write(info); err == nil {
+		err = werr
+	}
+	if err != nil {
+		t.fail(c, err)
+		return false
+	}
+	return !info.Complete() || t.complete(c, info)
+}
+
+func (t *Tus) complete(c *znet.Context, info *Info) bool {
+	if t.conf.OnComplete == nil {
+		return true
+	}
+	if err := t.conf.OnComplete(c, info); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+func (t *Tus) fail(c *znet.Context, err error) {
+	switch err {
+	case ErrNotFound:
+		c.String(http.StatusNotFound, err.Error())
+	case ErrLocked:
+		c.String(http.StatusLocked, err.Error())
+	case ErrOffset:
+		c.String(http.StatusConflict, err.Error())
+	case ErrTooLarge:
+		c.String(http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+// touch moves the expiration of an unfinished upload, finished uploads are kept
+func (t *Tus) touch(info *Info) {
+	if t.conf.Expiration > 0 && !info.Complete() {
+		info.ExpiresAt = time.Now().Add(t.conf.Expiration)
+	} else {
+		info.ExpiresAt = time.Time{}
+	}
+}
+
+func (t *Tus) setExpires(c *znet.Context, info *Info) {
+	if !info.ExpiresAt.IsZero() {
+		c.SetHeader("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// lock acquires the file lock of the upload, every call opens its own
+// lock file so requests of the same process exclude each other as well
+func (t *Tus) lock(id string) (*zfile.FileLock, error) {
+	l := zfile.NewFileLock(filepath.Join(t.dir, id+lockExt))
+	deadline := time.Now().Add(t.conf.LockTimeout)
+	for {
+		err := l.Lock()
+		if err == nil {
+			return l, nil
+		}
+		if err != zfile.ErrLocked {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (t *Tus) read(id string) (*Info, error) {
+	b, err := os.ReadFile(filepath.Join(t.dir, id+infoExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = ErrNotFound
+		}
+		return nil, err
+	}
+	info := &Info{}
+	if err = json.Unmarshal(b, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (t *Tus) write(info *Info) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(t.dir, info.ID+infoExt)
+	tmp := name + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (t *Tus) remove(id string) {
+	_ = os.Remove(filepath.Join(t.dir, id+infoExt))
+	_ = os.Remove(t.Path(id))
+	_ = os.Remove(filepath.Join(t.dir, id+lockExt))
+}
+
+func (info *Info) expired(now time.Time) bool {
+	return !info.ExpiresAt.IsZero() && now.After(info.ExpiresAt)
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// parseMetadata parses the Upload-Metadata header, comma separated pairs
+// of a key and an optional base64 encoded value
+func parseMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+		var value []byte
+		if len(fields) == 2 {
+			var err error
+			if value, err = base64.StdEncoding.DecodeString(fields[1]); err != nil {
+				return nil, errors.New("invalid Upload-Metadata")
+			}
+		}
+		metadata[fields[0]] = string(value)
+	}
+	return metadata, nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := metadata[k]; v != "" {
+			pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+		} else {
+			pairs = append(pairs, k)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,128 @@
+package tus_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/zfile"
+	"github.com/sohaha/zlsgo/znet"
+	"github.com/sohaha/zlsgo/znet/tus"
+)
+
+func TestTus(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	dir := t.TempDir()
+
+	var completed *tus.Info
+	u, err := tus.New(dir, func(conf *tus.Config) {
+		conf.MaxSize = 1024
+		conf.LockTimeout = 50 * time.Millisecond
+		conf.OnComplete = func(c *znet.Context, info *tus.Info) error {
+			completed = info
+			return nil
+		}
+	})
+	tt.NoError(err, true)
+	defer u.Close()
+
+	r := znet.New("tus-test")
+	r.SetMode(znet.QuietMode)
+	r.SetCustomMethodField("X-HTTP-Method-Override")
+	u.Register(r, "/files")
+
+	client := r.Test(tt)
+	client.OPTIONS("/files").Do().
+		ExpectStatus(204).
+		ExpectHeader("Tus-Version", tus.Version).
+		ExpectHeader("Tus-Extension", tus.Extensions).
+		ExpectHeader("Tus-Max-Size", "1024")
+
+	client.POST("/files").Header("Upload-Length", "10").Do().
+		ExpectStatus(412).ExpectHeader("Tus-Version", tus.Version)
+
+	client.SetHeader("Tus-Resumable", tus.Version)
+	client.POST("/files").Header("Upload-Length", "2048").Do().ExpectStatus(413)
+	client.POST("/files").Header("Upload-Length", "x").Do().ExpectStatus(400)
+
+	res := client.POST("/files").Header("Upload-Length", "10").
+		Header("Upload-Metadata", "filename d29ybGQudHh0,is_confidential").Do().
+		ExpectStatus(201).
+		ExpectHeader("Tus-Resumable", tus.Version)
+	location := res.Header().Get("Location")
+	tt.EqualTrue(strings.HasPrefix(location, "/files/"))
+	tt.EqualTrue(res.Header().Get("Upload-Expires") != "")
+	id := strings.TrimPrefix(location, "/files/")
+
+	client.HEAD(location).Do().
+		ExpectStatus(200).
+		ExpectHeader("Upload-Offset", "0").
+		ExpectHeader("Upload-Length", "10").
+		ExpectHeader("Upload-Metadata", "filename d29ybGQudHh0,is_confidential").
+		ExpectHeader("Cache-Control", "no-store")
+
+	client.PATCH(location).Header("Upload-Offset", "0").Body([]byte("hello"), "text/plain").Do().ExpectStatus(415)
+	client.PATCH(location).Header("Upload-Offset", "0").Body([]byte("hello"), tus.ContentType).Do().
+		ExpectStatus(204).ExpectHeader("Upload-Offset", "5")
+	client.PATCH(location).Header("Upload-Offset", "0").Body([]byte("hello"), tus.ContentType).Do().
+		ExpectStatus(409).ExpectHeader("Upload-Offset", "5")
+	client.PATCH(location).Header("Upload-Offset", "5").Body([]byte("world!"), tus.ContentType).Do().ExpectStatus(413)
+
+	l := zfile.NewFileLock(filepath.Join(dir, id+".lock"))
+	tt.NoError(l.Lock(), true)
+	client.PATCH(location).Header("Upload-Offset", "5").Body([]byte("world"), tus.ContentType).Do().ExpectStatus(423)
+	tt.NoError(l.Unlock())
+
+	tt.Equal(true, completed == nil)
+	client.Request("POST", location).Header("X-HTTP-Method-Override", "PATCH").
+		Header("Upload-Offset", "5").Body([]byte("world"), tus.ContentType).Do().
+		ExpectStatus(204).ExpectHeader("Upload-Offset", "10").ExpectHeader("Upload-Expires", "")
+	tt.Equal(id, completed.ID)
+	tt.Equal("world.txt", completed.Metadata["filename"])
+	b, err := os.ReadFile(u.Path(id))
+	tt.NoError(err)
+	tt.Equal("helloworld", string(b))
+
+	client.DELETE(location).Do().ExpectStatus(204)
+	client.HEAD(location).Do().ExpectStatus(404)
+	client.DELETE(location).Do().ExpectStatus(404)
+	client.HEAD("/files/../tus_test.go").Do().ExpectStatus(404)
+
+	res = client.POST("/files").Header("Upload-Length", "3").Body([]byte("abc"), tus.ContentType).Do().
+		ExpectStatus(201).ExpectHeader("Upload-Offset", "3")
+	info, err := u.Get(strings.TrimPrefix(res.Header().Get("Location"), "/files/"))
+	tt.NoError(err, true)
+	tt.EqualTrue(info.Complete())
+	tt.Equal(info.ID, completed.ID)
+}
+
+func TestTusExpiration(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	dir := t.TempDir()
+
+	u, err := tus.New(dir, func(conf *tus.Config) {
+		conf.Expiration = 50 * time.Millisecond
+		conf.CollectInterval = 0
+	})
+	tt.NoError(err, true)
+
+	r := znet.New("tus-expiration-test")
+	r.SetMode(znet.QuietMode)
+	u.Register(r, "/files/")
+
+	client := r.Test(tt)
+	client.SetHeader("Tus-Resumable", tus.Version)
+	location := client.POST("/files").Header("Upload-Length", "10").Do().ExpectStatus(201).Header().Get("Location")
+	client.HEAD(location).Do().ExpectStatus(200)
+
+	time.Sleep(100 * time.Millisecond)
+	client.HEAD(location).Do().ExpectStatus(404)
+	client.PATCH(location).Header("Upload-Offset", "0").Body([]byte("hello"), tus.ContentType).Do().ExpectStatus(404)
+
+	tt.NoError(u.Collect())
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	tt.Equal(0, len(files))
+}
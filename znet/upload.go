@@ -0,0 +1,448 @@
+package znet
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/sohaha/zlsgo/zfile"
+	"github.com/sohaha/zlsgo/zstring"
+)
+
+type (
+	// UploadConfig configuration of Context.Upload
+	UploadConfig struct {
+		// Storage stores the uploaded files
+		Storage UploadStorage
+		// FileName returns the name a file is stored under, default is a random
+		// name with the extension of the original file name
+		FileName func(field, filename string) string
+		// AllowTypes lists the allowed MIME types sniffed from the content, wildcards such as image/* are supported
+		AllowTypes []string
+		// Hashes lists the digests computed while streaming: md5, sha1, sha256 or sha512
+		Hashes []string
+		// MaxFileSize limits the size of each file, 0 is unlimited
+		MaxFileSize int64
+		// MaxTotalSize limits the size of all files, 0 uses the MaxRequestBodySize
+		// of the engine or 10MB when it is not set, a negative value is unlimited
+		MaxTotalSize int64
+		// MaxFiles limits the number of files, 0 is unlimited
+		MaxFiles int
+	}
+	// UploadStorage stores the uploaded files
+	UploadStorage interface {
+		// Save stores the content of r under name, nothing is kept when it fails
+		Save(name string, r io.Reader) error
+		// Remove deletes a stored file, it is called for the stored files when an upload fails
+		Remove(name string) error
+	}
+	// UploadedFile is a file stored by Context.Upload
+	UploadedFile struct {
+		// Hashes holds the hex digests by algorithm
+		Hashes map[string]string
+		// Field is the form field name
+		Field string
+		// Filename is the original file name sent by the client
+		Filename string
+		// Name is the name the file is stored under
+		Name string
+		// MimeType is sniffed from the content
+		MimeType string
+		Size     int64
+	}
+	// UploadResult holds the files and form values of an upload
+	UploadResult struct {
+		Values url.Values
+		Files  []*UploadedFile
+	}
+	// LocalStorage stores files in a directory, names escaping the directory are rejected
+	LocalStorage struct {
+		dir string
+	}
+	// MemoryStorage stores files in memory, it is meant for tests
+	MemoryStorage struct {
+		files map[string][]byte
+		mu    sync.RWMutex
+	}
+	uploadLimitReader struct {
+		r        io.Reader
+		total    *int64
+		n        int64
+		max      int64
+		maxTotal int64
+	}
+)
+
+var (
+	// ErrUploadTooLarge the file or the whole upload exceeds the size limit
+	ErrUploadTooLarge = errors.New("upload exceeds the size limit")
+	// ErrUploadTooManyFiles the upload contains more files than allowed
+	ErrUploadTooManyFiles = errors.New("too many upload files")
+	// ErrUploadType the sniffed MIME type of the file is not allowed
+	ErrUploadType = errors.New("upload file type is not allowed")
+	// ErrUploadStorage no storage is configured
+	ErrUploadStorage = errors.New("upload storage is not set")
+	// ErrUnsafePath the file name escapes the storage directory
+	ErrUnsafePath = errors.New("unsafe file path")
+	// ErrUnsupportedHash the hash algorithm is not supported
+	ErrUnsupportedHash = errors.New("unsupported hash algorithm")
+)
+
+const uploadKey = "upload_result"
+
+var uploadHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Upload reads the multipart body part by part without buffering the files, each
+// file is checked and hashed while it is streamed to the storage. Form values are
+// available through PostForm as they are read, so FileName sees the fields sent
+// before the file, and the files can be bound by Bind into *UploadedFile or
+// []*UploadedFile fields. Stored files are removed when it fails.
+func (c *Context) Upload(opt ...func(conf *UploadConfig)) (*UploadResult, error) {
+	conf := UploadConfig{FileName: uploadFileName}
+	for _, o := range opt {
+		o(&conf)
+	}
+	if conf.Storage == nil {
+		return nil, ErrUploadStorage
+	}
+	if conf.MaxTotalSize == 0 {
+		conf.MaxTotalSize = c.Engine.MaxRequestBodySize
+		if conf.MaxTotalSize <= 0 {
+			conf.MaxTotalSize = 10 << 20
+		}
+	}
+	for _, h := range conf.Hashes {
+		if _, ok := uploadHashes[h]; !ok {
+			return nil, ErrUnsupportedHash
+		}
+	}
+
+	mr, err := c.streamMultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UploadResult{Values: url.Values{}}
+	c.Request.PostForm = result.Values
+	c.cacheForm = result.Values
+	fail := func(err error) (*UploadResult, error) {
+		for _, f := range result.Files {
+			_ = conf.Storage.Remove(f.Name)
+		}
+		return nil, err
+	}
+
+	maxValueSize := c.Engine.MaxMultipartMemory
+	if maxValueSize <= 0 {
+		maxValueSize = defaultMultipartMemory
+	}
+	var total, valueSize int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		field := part.FormName()
+		if field == "" {
+			_ = part.Close()
+			continue
+		}
+
+		if part.FileName() == "" {
+			b, err := io.ReadAll(io.LimitReader(part, maxValueSize-valueSize+1))
+			_ = part.Close()
+			if err != nil {
+				return fail(err)
+			}
+			if valueSize += int64(len(b)); valueSize > maxValueSize {
+				return fail(ErrUploadTooLarge)
+			}
+			result.Values.Add(field, string(b))
+			continue
+		}
+
+		if conf.MaxFiles > 0 && len(result.Files) >= conf.MaxFiles {
+			_ = part.Close()
+			return fail(ErrUploadTooManyFiles)
+		}
+		f, err := conf.store(part, &total)
+		_ = part.Close()
+		if err != nil {
+			return fail(err)
+		}
+		result.Files = append(result.Files, f)
+	}
+
+	c.WithValue(uploadKey, result)
+	return result, nil
+}
+
+// streamMultipartReader reads the parts from the request body as they arrive,
+// the size is limited by the upload config instead of MaxRequestBodySize
+func (c *Context) streamMultipartReader() (*multipart.Reader, error) {
+	boundary, err := c.multipartBoundary(false)
+	if err != nil {
+		return nil, err
+	}
+	if c.rawData != nil {
+		return multipart.NewReader(bytes.NewReader(c.rawData), boundary), nil
+	}
+	if c.Request.Body == nil {
+		return nil, errors.New("request body is nil")
+	}
+	return multipart.NewReader(c.Request.Body, boundary), nil
+}
+
+func (conf *UploadConfig) store(part *multipart.Part, total *int64) (*UploadedFile, error) {
+	f := &UploadedFile{Field: part.FormName(), Filename: part.FileName()}
+
+	br := bufio.NewReaderSize(part, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	f.MimeType = mediaTypeOf(zfile.GetMimeType(f.Filename, head))
+	if len(conf.AllowTypes) > 0 {
+		allowed := false
+		for _, t := range conf.AllowTypes {
+			if zstring.Match(f.MimeType, t) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, ErrUploadType
+		}
+	}
+
+	limit := &uploadLimitReader{r: br, max: conf.MaxFileSize, total: total, maxTotal: conf.MaxTotalSize}
+	var r io.Reader = limit
+	hashes := make(map[string]hash.Hash, len(conf.Hashes))
+	if len(conf.Hashes) > 0 {
+		writers := make([]io.Writer, 0, len(conf.Hashes))
+		for _, name := range conf.Hashes {
+			h := uploadHashes[name]()
+			hashes[name] = h
+			writers = append(writers, h)
+		}
+		r = io.TeeReader(limit, io.MultiWriter(writers...))
+	}
+
+	f.Name = conf.FileName(f.Field, f.Filename)
+	if err = conf.Storage.Save(f.Name, r); err != nil {
+		return nil, err
+	}
+
+	f.Size = limit.n
+	f.Hashes = make(map[string]string, len(hashes))
+	for name, h := range hashes {
+		f.Hashes[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return f, nil
+}
+
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	*l.total += int64(n)
+	if (l.max > 0 && l.n > l.max) || (l.maxTotal > 0 && *l.total > l.maxTotal) {
+		return n, ErrUploadTooLarge
+	}
+	return n, err
+}
+
+func uploadFileName(_, filename string) string {
+	return zstring.UUID() + strings.ToLower(filepath.Ext(filename))
+}
+
+// File returns the first file of the field
+func (r *UploadResult) File(field string) *UploadedFile {
+	for _, f := range r.Files {
+		if f.Field == field {
+			return f
+		}
+	}
+	return nil
+}
+
+// FilesOf returns the files of the field
+func (r *UploadResult) FilesOf(field string) []*UploadedFile {
+	files := make([]*UploadedFile, 0, 1)
+	for _, f := range r.Files {
+		if f.Field == field {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// NewLocalStorage returns a storage saving files into dir
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: zfile.RealPathMkdir(dir, true)}
+}
+
+func (s *LocalStorage) path(name string) (string, error) {
+	path := zfile.RealPath(filepath.Join(s.dir, name))
+	if name == "" || !strings.HasPrefix(path, s.dir) {
+		return "", ErrUnsafePath
+	}
+	return path, nil
+}
+
+// Save writes the content to a temporary file and renames it to name
+func (s *LocalStorage) Save(name string, r io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Remove deletes the file
+func (s *LocalStorage) Remove(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Path returns the full path of a stored file
+func (s *LocalStorage) Path(name string) (string, error) {
+	return s.path(name)
+}
+
+// NewMemoryStorage returns a storage keeping files in memory
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string][]byte)}
+}
+
+// Save reads the content into memory
+func (s *MemoryStorage) Save(name string, r io.Reader) error {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.files[name] = buf.Bytes()
+	s.mu.Unlock()
+	return nil
+}
+
+// Remove deletes the file
+func (s *MemoryStorage) Remove(name string) error {
+	s.mu.Lock()
+	delete(s.files, name)
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns the content of a stored file
+func (s *MemoryStorage) Get(name string) ([]byte, bool) {
+	s.mu.RLock()
+	b, ok := s.files[name]
+	s.mu.RUnlock()
+	return b, ok
+}
+
+// Len returns the number of stored files
+func (s *MemoryStorage) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.files)
+}
+
+var (
+	fileHeaderType    = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType   = reflect.TypeOf([]*multipart.FileHeader(nil))
+	uploadedFileType  = reflect.TypeOf((*UploadedFile)(nil))
+	uploadedFilesType = reflect.TypeOf([]*UploadedFile(nil))
+)
+
+func isFileField(t reflect.Type) bool {
+	return t == fileHeaderType || t == fileHeadersType || t == uploadedFileType || t == uploadedFilesType
+}
+
+// bindFiles sets the file fields of obj by their field index
+func (c *Context) bindFiles(obj interface{}, fields map[int]string) {
+	if len(fields) == 0 {
+		return
+	}
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	upload, _ := c.MustValue(uploadKey).(*UploadResult)
+	for index, name := range fields {
+		field := v.Field(index)
+		if !field.CanSet() {
+			continue
+		}
+		switch field.Type() {
+		case uploadedFileType:
+			if upload != nil {
+				if f := upload.File(name); f != nil {
+					field.Set(reflect.ValueOf(f))
+				}
+			}
+		case uploadedFilesType:
+			if upload != nil {
+				field.Set(reflect.ValueOf(upload.FilesOf(name)))
+			}
+		case fileHeaderType:
+			if upload == nil {
+				if files, err := c.FormFiles(name); err == nil {
+					field.Set(reflect.ValueOf(files[0]))
+				}
+			}
+		case fileHeadersType:
+			if upload == nil {
+				if files, err := c.FormFiles(name); err == nil {
+					field.Set(reflect.ValueOf(files))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package znet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sohaha/zlsgo"
+)
+
+func TestUpload(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+	text := []byte(strings.Repeat("zlsgo", 100))
+	sum := sha256.Sum256(text)
+
+	store := NewMemoryStorage()
+	dir := t.TempDir()
+	local := NewLocalStorage(dir)
+
+	r := New("upload-test")
+	r.SetMode(QuietMode)
+	r.POST("/memory", func(c *Context) {
+		res, err := c.Upload(func(conf *UploadConfig) {
+			conf.Storage = store
+			conf.Hashes = []string{"sha256", "md5"}
+			conf.MaxFiles = 3
+		})
+		tt.NoError(err, true)
+		tt.Equal(2, len(res.Files))
+
+		var form struct {
+			Avatar *UploadedFile   `json:"avatar"`
+			Docs   []*UploadedFile `json:"docs"`
+			Title  string          `json:"title"`
+		}
+		tt.NoError(c.Bind(&form), true)
+		tt.Equal("hi", form.Title)
+		tt.Equal("hi", c.DefaultPostForm("title", ""))
+		tt.Equal("image/png", form.Avatar.MimeType)
+		tt.Equal("a.png", form.Avatar.Filename)
+		tt.EqualTrue(strings.HasSuffix(form.Avatar.Name, ".png"))
+		tt.Equal(1, len(form.Docs))
+		tt.Equal(int64(len(text)), form.Docs[0].Size)
+		tt.Equal(hex.EncodeToString(sum[:]), form.Docs[0].Hashes["sha256"])
+		tt.Equal(32, len(form.Docs[0].Hashes["md5"]))
+		b, _ := store.Get(form.Docs[0].Name)
+		tt.Equal(text, b)
+		c.String(200, "ok")
+	})
+	r.POST("/limit", func(c *Context) {
+		_, err := c.Upload(func(conf *UploadConfig) {
+			conf.Storage = store
+			conf.MaxFileSize = 200
+			conf.AllowTypes = []string{"image/*", "text/*"}
+		})
+		c.String(400, err.Error())
+	})
+	r.POST("/type", func(c *Context) {
+		_, err := c.Upload(func(conf *UploadConfig) {
+			conf.Storage = store
+			conf.AllowTypes = []string{"image/*"}
+		})
+		c.String(400, err.Error())
+	})
+	r.POST("/local", func(c *Context) {
+		res, err := c.Upload(func(conf *UploadConfig) {
+			conf.Storage = local
+			conf.FileName = func(field, filename string) string {
+				return c.DefaultPostForm("dir", "") + "/" + filename
+			}
+		})
+		if err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		c.String(200, res.Files[0].Name)
+	})
+	r.POST("/header", func(c *Context) {
+		var form struct {
+			File  *multipart.FileHeader   `json:"file"`
+			Files []*multipart.FileHeader `json:"files"`
+		}
+		tt.NoError(c.Bind(&form), true)
+		c.String(200, form.File.Filename+","+form.Files[1].Filename)
+	})
+
+	client := r.Test(tt)
+
+	client.POST("/memory").Form("title", "hi").
+		File("avatar", "a.png", png).File("docs", "a.txt", text).Do().
+		ExpectStatus(200)
+	tt.Equal(2, store.Len())
+
+	client.POST("/limit").File("avatar", "a.png", png).File("docs", "a.txt", text).Do().
+		ExpectBody(ErrUploadTooLarge.Error())
+	tt.Equal(2, store.Len())
+
+	client.POST("/type").File("avatar", "a.png", []byte("<html><script>alert(1)</script></html>")).Do().ExpectBody(ErrUploadType.Error())
+
+	client.POST("/local").Form("dir", "2026").File("file", "a.txt", text).Do().ExpectBody("2026/a.txt")
+	b, err := os.ReadFile(filepath.Join(dir, "2026", "a.txt"))
+	tt.NoError(err)
+	tt.Equal(text, b)
+	client.POST("/local").Form("dir", "../..").File("file", "a.txt", text).Do().ExpectBody(ErrUnsafePath.Error())
+
+	client.POST("/header").File("file", "1.txt", text).File("files", "2.txt", text).File("files", "3.txt", text).Do().
+		ExpectBody("1.txt,3.txt")
+}
+
+type discardStorage struct {
+	size    int64
+	removed int
+}
+
+func (s *discardStorage) Save(_ string, r io.Reader) error {
+	n, err := io.Copy(io.Discard, r)
+	s.size += n
+	return err
+}
+
+func (s *discardStorage) Remove(string) error {
+	s.removed++
+	return nil
+}
+
+func TestUploadLarge(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	size := int64(11 << 20)
+	store := &discardStorage{}
+	r := New("upload-large-test")
+	r.SetMode(QuietMode)
+	r.POST("/", func(c *Context) {
+		res, err := c.Upload(func(conf *UploadConfig) {
+			conf.Storage = store
+			conf.MaxFileSize = size + 1
+			conf.MaxTotalSize = size + 1
+		})
+		if err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		c.String(200, c.DefaultPostForm("title", "")+":"+strconv.FormatInt(res.Files[0].Size, 10))
+	})
+	r.POST("/unset", func(c *Context) {
+		_, err := c.Upload(func(conf *UploadConfig) {
+			conf.Storage = store
+		})
+		c.String(400, err.Error())
+	})
+	r.POST("/limit", func(c *Context) {
+		_, err := c.Upload(func(conf *UploadConfig) {
+			conf.Storage = store
+			conf.MaxTotalSize = 1 << 20
+		})
+		c.String(400, err.Error())
+	})
+
+	data := make([]byte, size)
+	client := r.Test(tt)
+	client.POST("/").File("file", "big.bin", data).Form("title", "big").Do().
+		ExpectStatus(200).
+		ExpectBody("big:" + strconv.FormatInt(size, 10))
+	tt.Equal(size, store.size)
+
+	client.POST("/unset").File("file", "big.bin", data).Do().
+		ExpectStatus(400).
+		ExpectBody(ErrUploadTooLarge.Error())
+
+	client.POST("/limit").File("file", "big.bin", data).Do().
+		ExpectStatus(400).
+		ExpectBody(ErrUploadTooLarge.Error())
+	tt.Equal(0, store.removed)
+}
@@ -104,6 +104,9 @@ func (_ utils) URLMatchAndParse(requestURL string, path string) (matchParams map
 				}
 			}
 		}
+		if !checkParamTypes(path, matchParams) {
+			return nil, false
+		}
 		return
 	}
 
@@ -218,7 +221,9 @@ func parseBracePlaceholder(s string) (string, string) {
 		name = s[:idx]
 		expr = s[idx+1:]
 	}
-	if expr == "" {
+	if t, ok := GetParamType(expr); ok {
+		expr = t.Pattern
+	} else if expr == "" {
 		switch name {
 		case idKey:
 			expr = idPattern
@@ -291,12 +296,18 @@ func getHostname(addr string, isTls bool) string {
 // It returns the engine, handler function, middleware stack, and a boolean
 // indicating whether a match was found.
 func (u utils) TreeFind(t *Tree, path string) (*Engine, handlerFn, []handlerFn, bool) {
+	_, engine, handler, middleware, ok := u.treeFind(t, path)
+	return engine, handler, middleware, ok
+}
+
+// treeFind is TreeFind that also returns the pattern of the matched route
+func (u utils) treeFind(t *Tree, path string) (string, *Engine, handlerFn, []handlerFn, bool) {
 	nodes := t.Find(path, false)
 	for i := range nodes {
 		node := nodes[i]
 		if node.handle != nil {
 			if node.path == path {
-				return node.engine, node.handle, node.middleware, true
+				return node.path, node.engine, node.handle, node.middleware, true
 			}
 		}
 	}
@@ -313,8 +324,15 @@ func (u utils) TreeFind(t *Tree, path string) (*Engine, handlerFn, []handlerFn,
 		for i := range nodes {
 			if handler := nodes[i].handle; handler != nil && nodes[i].path != path {
 				if matchParamsMap, ok := u.URLMatchAndParse(path, nodes[i].path); ok {
-					return nodes[i].engine, func(c *Context) error {
+					return nodes[i].path, nodes[i].engine, func(c *Context) error {
 						req := c.Request
+						if hostParams, ok := req.Context().Value(u.ContextKey).(map[string]string); ok {
+							for k, v := range hostParams {
+								if _, exists := matchParamsMap[k]; !exists {
+									matchParamsMap[k] = v
+								}
+							}
+						}
 						ctx := context.WithValue(req.Context(), u.ContextKey, matchParamsMap)
 						c.Request = req.WithContext(ctx)
 						return nodes[i].Handle()(c)
@@ -323,7 +341,7 @@ func (u utils) TreeFind(t *Tree, path string) (*Engine, handlerFn, []handlerFn,
 			}
 		}
 	}
-	return nil, nil, nil, false
+	return "", nil, nil, nil, false
 }
 
 // CompletionPath ensures a path has the correct prefix and format.
@@ -460,6 +478,7 @@ func (e *Engine) acquireContext(w http.ResponseWriter, r *http.Request) *Context
 	c.Engine = e
 	c.Request = r
 	c.Writer = w
+	c.Log = e.Log
 	c.injector = zdi.New(c.Engine.injector)
 	c.injector.Maps(c)
 	c.startTime = time.Now()
@@ -492,6 +511,8 @@ func (e *Engine) releaseContext(c *Context) {
 	c.rawData = nil
 	c.Engine = nil
 	c.ip = ""
+	c.route = ""
+	c.inFlight = false
 	c.prevData.Content = c.prevData.Content[0:0]
 	c.prevData.Type = ContentTypePlain
 	c.mu.Unlock()
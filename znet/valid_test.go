@@ -1,6 +1,7 @@
 package znet
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/sohaha/zlsgo"
@@ -27,6 +28,17 @@ func TestBindValid(t *testing.T) {
 		tt.Equal(1, s.IDs[0].Gg.P[0].ID)
 	})
 
+	_ = newRequest(r, "POST", []string{"/TestBindValidFields", `{"id":3}`, mimeJSON}, "/TestBindValidFields", func(c *Context) {
+		var s SS
+		err := c.BindValid(&s, map[string]zvalid.Engine{
+			"id":   c.ValidRule().MinInt(10, "id must be at least 10"),
+			"name": c.ValidRule().Required("name is required"),
+		})
+		var fields zvalid.FieldErrors
+		tt.EqualTrue(errors.As(err, &fields))
+		tt.Equal(zvalid.FieldErrors{{Field: "name", Message: "name is required"}, {Field: "id", Message: "id must be at least 10"}}, fields)
+		tt.Equal("name is required", err.Error())
+	})
 }
 
 func TestContextValid(t *testing.T) {
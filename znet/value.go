@@ -23,8 +23,9 @@ const (
 
 // valid is an internal helper function that validates struct fields using the provided validation rules.
 // It supports validation for basic types like string, bool, numeric types, etc.
+// The errors of all invalid fields are returned as zvalid.FieldErrors.
 func (c *Context) valid(obj interface{}, v map[string]zvalid.Engine) error {
-	r := make([]*zvalid.ValidEle, 0, len(v))
+	var errs zvalid.FieldErrors
 	val := zreflect.ValueOf(obj)
 	if val.Kind() != reflect.Ptr {
 		return errors.New("result must be a pointer")
@@ -39,7 +40,9 @@ func (c *Context) valid(obj interface{}, v map[string]zvalid.Engine) error {
 		case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
 			value := field.Interface()
 			if rv, ok := v[name]; ok {
-				r = append(r, zvalid.BatchVar(field, rv.VerifiAny(value)))
+				if err := zvalid.Var(field, rv.VerifiAny(value)); err != nil {
+					errs = append(errs, zvalid.FieldError{Field: name, Message: err.Error()})
+				}
 			}
 		case reflect.Struct:
 		case reflect.Slice:
@@ -48,7 +51,10 @@ func (c *Context) valid(obj interface{}, v map[string]zvalid.Engine) error {
 		}
 	}
 
-	return zvalid.Batch(r...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 // BindValid binds request data to the provided object and validates it using the provided validation rules.
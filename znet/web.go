@@ -14,6 +14,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sohaha/zlsgo/zdi"
@@ -51,9 +52,11 @@ type (
 		renderError   ErrHandlerFunc
 		cacheQuery    url.Values
 		ip            string
+		route         string
 		rawData       []byte
 		middleware    []handlerFn
 		mu            zsync.RBMutex
+		inFlight      bool
 	}
 	// Engine is the core of the web framework, providing HTTP routing and server functionality.
 	// It manages routes, middleware, templates, and server configuration.
@@ -76,6 +79,9 @@ type (
 		addr                 []addrSt
 		shutdownMu           sync.Mutex
 		shutdowns            []func()
+		health               *health
+		trustedProxies       *atomic.Value
+		cacheControls        *cacheControls
 		MaxMultipartMemory   int64
 		webMode              int
 		writeTimeout         time.Duration
@@ -106,6 +112,7 @@ type (
 	// router manages the HTTP route trees and middleware stack.
 	router struct {
 		trees      map[string]*Tree
+		hosts      *vhosts
 		notFound   handlerFn
 		prefix     string
 		parameters Parameters
@@ -202,6 +209,7 @@ func New(serverName ...string) *Engine {
 	route := &router{
 		prefix: "/",
 		trees:  make(map[string]*Tree),
+		hosts:  &vhosts{static: make(map[string]*vhost)},
 	}
 	r := &Engine{
 		Log:                 log,
@@ -218,6 +226,9 @@ func New(serverName ...string) *Engine {
 		templateFuncMap:     template.FuncMap{},
 		injector:            zdi.New(),
 		customRenderings:    make([]reflect.Type, 0),
+		trustedProxies:      &atomic.Value{},
+		cacheControls:       &cacheControls{},
+		health:              &health{},
 		shutdowns:           make([]func(), 0),
 	}
 	r.pool.New = func() interface{} {
@@ -414,6 +425,7 @@ func (e *Engine) SetTimeout(Timeout time.Duration, WriteTimeout ...time.Duration
 // It configures all servers according to the Engine settings and begins listening
 // on all configured addresses. Returns the server instances that were started.
 func (e *Engine) StartUp() []*serverMap {
+	e.health.draining.Store(false)
 	var wg sync.WaitGroup
 	var srvMap sync.Map
 	for _, cfg := range e.addr {
@@ -523,6 +535,8 @@ func Shutdown() error {
 // If sigkill is true, it forces immediate termination rather than waiting
 // for connections to complete gracefully.
 func shutdown(sigkill bool) {
+	drain(sigkill)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
@@ -558,6 +572,35 @@ func shutdown(sigkill bool) {
 	}
 }
 
+// drain fails the readiness of all engines and drains the ones configured with
+// SetDrain concurrently, keep-alive is disabled so clients reconnect to other instances
+func drain(sigkill bool) {
+	engines := make(map[*Engine]struct{})
+	for _, s := range srvs {
+		s.engine.health.draining.Store(true)
+		if s.engine.health.grace <= 0 && s.engine.health.delay <= 0 {
+			continue
+		}
+		s.srv.SetKeepAlivesEnabled(false)
+		engines[s.engine] = struct{}{}
+	}
+
+	var dwg sync.WaitGroup
+	for e := range engines {
+		dwg.Add(1)
+		go func(e *Engine) {
+			defer dwg.Done()
+			if sigkill {
+				e.Log.Info("Draining server ...")
+			}
+			if !e.Drain() && sigkill {
+				e.Log.Warnf("Drain timeout, %d requests in flight", e.InFlight())
+			}
+		}(e)
+	}
+	dwg.Wait()
+}
+
 var (
 	srvs []*serverMap
 	wg   sync.WaitGroup
@@ -0,0 +1,698 @@
+package znet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+// WebSocket message types, as defined in RFC 6455 section 11.8.
+const (
+	// TextMessage denotes a UTF-8 encoded text data message.
+	TextMessage = 1
+	// BinaryMessage denotes a binary data message.
+	BinaryMessage = 2
+	// CloseMessage denotes a close control message.
+	CloseMessage = 8
+	// PingMessage denotes a ping control message.
+	PingMessage = 9
+	// PongMessage denotes a pong control message.
+	PongMessage = 10
+)
+
+// WebSocket close codes, as defined in RFC 6455 section 11.7.
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseAbnormalClosure    = 1006
+	CloseInvalidPayloadData = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseInternalServerErr  = 1011
+)
+
+const (
+	websocketGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	websocketVersion        = "13"
+	websocketDeflate        = "permessage-deflate"
+	websocketMaxControlSize = 125
+	continuationFrame       = 0
+)
+
+var (
+	// ErrWebSocketHandshake is returned when the request is not a valid WebSocket handshake.
+	ErrWebSocketHandshake = errors.New("websocket: not a websocket handshake")
+	// ErrWebSocketVersion is returned when the client requests an unsupported protocol version.
+	ErrWebSocketVersion = errors.New("websocket: unsupported version")
+	// ErrWebSocketOrigin is returned when the request origin is rejected.
+	ErrWebSocketOrigin = errors.New("websocket: request origin not allowed")
+	// ErrWebSocketClosed is returned when writing to a connection that has been closed.
+	ErrWebSocketClosed = errors.New("websocket: connection closed")
+	// ErrWebSocketMessageTooBig is returned when a message exceeds the read limit.
+	ErrWebSocketMessageTooBig = errors.New("websocket: message too big")
+	// ErrWebSocketProtocol is returned when the peer violates the protocol.
+	ErrWebSocketProtocol = errors.New("websocket: protocol error")
+
+	websocketDeflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+	websocketFlateWriter = sync.Pool{New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	}}
+)
+
+type (
+	// WebSocketOption defines configuration options for a WebSocket connection.
+	WebSocketOption struct {
+		// CheckOrigin validates the Origin header, by default only same-host origins are accepted
+		CheckOrigin func(c *Context) bool
+		// Subprotocols lists the server supported subprotocols in order of preference
+		Subprotocols []string
+		// MaxMessageSize limits the size of a received message, including decompressed data
+		MaxMessageSize int64
+		// PingInterval enables keepalive pings when greater than zero
+		PingInterval time.Duration
+		// PongWait is the time allowed to read the next frame from the peer
+		PongWait time.Duration
+		// WriteWait is the time allowed to write a frame to the peer
+		WriteWait time.Duration
+		// CloseWait is the time allowed for the peer to answer a close frame
+		CloseWait time.Duration
+		// CompressionLevel is the flate level used for outgoing compressed messages
+		CompressionLevel int
+		// EnableCompression negotiates per-message deflate when the client offers it
+		EnableCompression bool
+	}
+
+	// WebSocket represents a server side WebSocket connection.
+	// ReadMessage must be called from a single goroutine, write methods are safe for concurrent use.
+	WebSocket struct {
+		conn        net.Conn
+		br          *bufio.Reader
+		option      *WebSocketOption
+		done        chan struct{}
+		pongHandler func(data []byte)
+		closeTimer  *time.Timer
+		subprotocol string
+		writeBuf    []byte
+		closeOnce   sync.Once
+		writeMu     sync.Mutex
+		closeSent   *zutil.Bool
+		compress    bool
+	}
+
+	// WebSocketCloseError is returned by ReadMessage when the peer closes the connection.
+	WebSocketCloseError struct {
+		Text string
+		Code int
+	}
+)
+
+// Error implements the error interface.
+func (e *WebSocketCloseError) Error() string {
+	s := "websocket: close " + strconv.Itoa(e.Code)
+	if e.Text != "" {
+		s += " " + e.Text
+	}
+	return s
+}
+
+// IsWebSocketClose reports whether err is a close error with one of the given codes.
+// When no codes are provided any close error matches.
+func IsWebSocketClose(err error, codes ...int) bool {
+	var e *WebSocketCloseError
+	if !errors.As(err, &e) {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Upgrade upgrades the HTTP connection to the WebSocket protocol.
+// On failure an HTTP error response is prepared and the error is returned.
+// After a successful upgrade the context no longer writes a response,
+// the returned connection stays usable after the handler returns and is not
+// counted as in flight, so Drain does not wait for it.
+func (c *Context) Upgrade(opts ...func(o *WebSocketOption)) (*WebSocket, error) {
+	o := zutil.Optional(WebSocketOption{
+		MaxMessageSize:   10 << 20,
+		WriteWait:        10 * time.Second,
+		CloseWait:        5 * time.Second,
+		CompressionLevel: flate.BestSpeed,
+	}, opts...)
+	if o.PingInterval > 0 && o.PongWait <= 0 {
+		o.PongWait = o.PingInterval * 2
+	}
+
+	if c.Request.Method != http.MethodGet || !c.IsWebsocket() {
+		c.String(http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+		return nil, ErrWebSocketHandshake
+	}
+
+	if c.GetHeader("Sec-WebSocket-Version") != websocketVersion {
+		c.SetHeader("Sec-WebSocket-Version", websocketVersion, true)
+		c.String(http.StatusUpgradeRequired, http.StatusText(http.StatusUpgradeRequired))
+		return nil, ErrWebSocketVersion
+	}
+
+	key := c.GetHeader("Sec-WebSocket-Key")
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		c.String(http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+		return nil, ErrWebSocketHandshake
+	}
+
+	checkOrigin := o.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(c) {
+		c.String(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return nil, ErrWebSocketOrigin
+	}
+
+	hijacker, ok := c.Writer.(http.Hijacker)
+	if !ok {
+		c.String(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return nil, http.ErrNotSupported
+	}
+
+	ws := &WebSocket{
+		option:    &o,
+		done:      make(chan struct{}),
+		closeSent: zutil.NewBool(false),
+	}
+	ws.subprotocol = selectSubprotocol(c.GetHeader("Sec-WebSocket-Protocol"), o.Subprotocols)
+	ws.compress = o.EnableCompression && offersDeflate(c.Request.Header.Values("Sec-WebSocket-Extensions"))
+
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		c.String(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return nil, err
+	}
+	c.Abort(http.StatusSwitchingProtocols)
+	c.done.Store(true)
+	c.untrack()
+
+	ws.conn = conn
+	ws.br = brw.Reader
+
+	b := brw.Writer
+	_, _ = b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	_, _ = b.WriteString(websocketAcceptKey(key))
+	_, _ = b.WriteString("\r\n")
+	if ws.subprotocol != "" {
+		_, _ = b.WriteString("Sec-WebSocket-Protocol: " + ws.subprotocol + "\r\n")
+	}
+	if ws.compress {
+		_, _ = b.WriteString("Sec-WebSocket-Extensions: " + websocketDeflate + "; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	c.mu.Lock()
+	for k, v := range c.header {
+		for i := range v {
+			_, _ = b.WriteString(k + ": " + v[i] + "\r\n")
+		}
+	}
+	c.mu.Unlock()
+	_, _ = b.WriteString("\r\n")
+
+	if o.WriteWait > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(o.WriteWait))
+	}
+	if err = b.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetWriteDeadline(time.Time{})
+
+	if o.PongWait > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(o.PongWait))
+	} else {
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+	if o.PingInterval > 0 {
+		go ws.keepalive()
+	}
+
+	return ws, nil
+}
+
+// sameOrigin accepts requests without an Origin header or whose origin host matches the request host.
+func sameOrigin(c *Context) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, c.Request.Host)
+}
+
+// websocketAcceptKey computes the Sec-WebSocket-Accept value for a client key.
+func websocketAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// selectSubprotocol returns the first server subprotocol requested by the client.
+func selectSubprotocol(header string, supported []string) string {
+	if header == "" || len(supported) == 0 {
+		return ""
+	}
+	requested := strings.Split(header, ",")
+	for _, s := range supported {
+		for i := range requested {
+			if strings.TrimSpace(requested[i]) == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// offersDeflate reports whether the client offers the per-message deflate extension.
+func offersDeflate(headers []string) bool {
+	for _, header := range headers {
+		for _, ext := range strings.Split(header, ",") {
+			name := strings.TrimSpace(strings.SplitN(ext, ";", 2)[0])
+			if strings.EqualFold(name, websocketDeflate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (ws *WebSocket) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr returns the remote network address.
+func (ws *WebSocket) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// Done returns a channel that's closed when the connection is terminated.
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.done
+}
+
+// SetPongHandler sets a function called for every pong received from the peer.
+func (ws *WebSocket) SetPongHandler(fn func(data []byte)) {
+	ws.pongHandler = fn
+}
+
+// keepalive periodically sends ping frames until the connection is closed.
+func (ws *WebSocket) keepalive() {
+	ticker := time.NewTicker(ws.option.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.done:
+			return
+		case <-ticker.C:
+			if err := ws.Ping(nil); err != nil {
+				ws.closeConn()
+				return
+			}
+		}
+	}
+}
+
+// ReadMessage reads the next complete data message from the peer.
+// Ping, pong and close control frames are handled transparently,
+// a close frame from the peer is reported as *WebSocketCloseError.
+func (ws *WebSocket) ReadMessage() (messageType int, data []byte, err error) {
+	var (
+		buf        bytes.Buffer
+		compressed bool
+	)
+	for {
+		fin, rsv1, op, payload, err := ws.readFrame()
+		if err != nil {
+			if errors.Is(err, ErrWebSocketMessageTooBig) {
+				ws.fail(CloseMessageTooBig, err)
+			} else if errors.Is(err, ErrWebSocketProtocol) {
+				ws.fail(CloseProtocolError, err)
+			} else {
+				ws.closeConn()
+			}
+			return 0, nil, err
+		}
+
+		if ws.option.PongWait > 0 {
+			_ = ws.conn.SetReadDeadline(time.Now().Add(ws.option.PongWait))
+		}
+
+		switch op {
+		case PingMessage:
+			if err = ws.writeFrame(PongMessage, payload, false); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if ws.pongHandler != nil {
+				ws.pongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, ws.handleClose(payload)
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, ErrWebSocketProtocol)
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, ErrWebSocketProtocol)
+			}
+			messageType = int(op)
+			compressed = rsv1
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, ErrWebSocketProtocol)
+		}
+
+		if ws.option.MaxMessageSize > 0 && int64(buf.Len()+len(payload)) > ws.option.MaxMessageSize {
+			return 0, nil, ws.fail(CloseMessageTooBig, ErrWebSocketMessageTooBig)
+		}
+		buf.Write(payload)
+
+		if !fin {
+			continue
+		}
+
+		data = buf.Bytes()
+		if compressed {
+			if data, err = ws.inflate(data); err != nil {
+				if errors.Is(err, ErrWebSocketMessageTooBig) {
+					return 0, nil, ws.fail(CloseMessageTooBig, err)
+				}
+				return 0, nil, ws.fail(CloseInvalidPayloadData, err)
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(data) {
+			return 0, nil, ws.fail(CloseInvalidPayloadData, errors.New("websocket: invalid utf8 payload"))
+		}
+		return messageType, data, nil
+	}
+}
+
+// ReadJSON reads the next message and unmarshals it into v.
+func (ws *WebSocket) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// readFrame reads a single frame from the connection and unmasks its payload.
+func (ws *WebSocket) readFrame() (fin, rsv1 bool, op byte, payload []byte, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(ws.br, header[:2]); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	rsv1 = header[0]&0x40 != 0
+	op = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7f)
+
+	if header[0]&0x30 != 0 || (rsv1 && !ws.compress) || !masked {
+		err = ErrWebSocketProtocol
+		return
+	}
+
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(ws.br, header[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(header[:2]))
+	case 127:
+		if _, err = io.ReadFull(ws.br, header[:8]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(header[:8]))
+		if length < 0 {
+			err = ErrWebSocketProtocol
+			return
+		}
+	}
+
+	if op >= CloseMessage {
+		if !fin || length > websocketMaxControlSize || rsv1 {
+			err = ErrWebSocketProtocol
+			return
+		}
+	} else if ws.option.MaxMessageSize > 0 && length > ws.option.MaxMessageSize {
+		err = ErrWebSocketMessageTooBig
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+		return
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// handleClose answers a close frame received from the peer and closes the connection.
+func (ws *WebSocket) handleClose(payload []byte) error {
+	closeErr := &WebSocketCloseError{Code: CloseNoStatusReceived}
+	if len(payload) == 1 {
+		ws.closeConn()
+		return ErrWebSocketProtocol
+	}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !utf8.ValidString(closeErr.Text) {
+			ws.closeConn()
+			return ErrWebSocketProtocol
+		}
+	}
+	if ws.closeSent.CAS(false, true) {
+		code := closeErr.Code
+		if code == CloseNoStatusReceived {
+			code = CloseNormalClosure
+		}
+		_ = ws.writeFrame(CloseMessage, closePayload(code, ""), false)
+	}
+	ws.closeConn()
+	return closeErr
+}
+
+// fail sends a close frame with the given code and terminates the connection.
+func (ws *WebSocket) fail(code int, err error) error {
+	if ws.closeSent.CAS(false, true) {
+		_ = ws.writeFrame(CloseMessage, closePayload(code, ""), false)
+	}
+	ws.closeConn()
+	return err
+}
+
+// inflate decompresses a per-message deflate payload while honoring the message size limit.
+func (ws *WebSocket) inflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(websocketDeflateTail)))
+	defer r.Close()
+
+	var reader io.Reader = r
+	limit := ws.option.MaxMessageSize
+	if limit > 0 {
+		reader = io.LimitReader(r, limit+1)
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, ErrWebSocketMessageTooBig
+	}
+	return out, nil
+}
+
+// deflate compresses a message payload for per-message deflate.
+func (ws *WebSocket) deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w *flate.Writer
+	if ws.option.CompressionLevel == flate.BestSpeed {
+		w = websocketFlateWriter.Get().(*flate.Writer)
+		w.Reset(&buf)
+		defer websocketFlateWriter.Put(w)
+	} else {
+		var err error
+		if w, err = flate.NewWriter(&buf, ws.option.CompressionLevel); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), websocketDeflateTail), nil
+}
+
+// WriteMessage writes a data message to the peer.
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("websocket: invalid message type")
+	}
+	if ws.closeSent.Load() {
+		return ErrWebSocketClosed
+	}
+	compressed := false
+	if ws.compress && len(data) > 0 {
+		d, err := ws.deflate(data)
+		if err != nil {
+			return err
+		}
+		data, compressed = d, true
+	}
+	return ws.writeFrame(byte(messageType), data, compressed)
+}
+
+// WriteText writes a text message to the peer.
+func (ws *WebSocket) WriteText(data string) error {
+	return ws.WriteMessage(TextMessage, []byte(data))
+}
+
+// WriteJSON marshals v to JSON and writes it as a text message.
+func (ws *WebSocket) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, data)
+}
+
+// Ping sends a ping control frame to the peer.
+func (ws *WebSocket) Ping(data []byte) error {
+	if len(data) > websocketMaxControlSize {
+		return errors.New("websocket: control frame too large")
+	}
+	return ws.writeFrame(PingMessage, data, false)
+}
+
+// Close starts the close handshake with the given code and reason.
+// The connection is terminated once the peer answers or the close wait elapses.
+func (ws *WebSocket) Close(code int, reason string) error {
+	if !ws.closeSent.CAS(false, true) {
+		return nil
+	}
+	if len(reason) > websocketMaxControlSize-2 {
+		reason = reason[:websocketMaxControlSize-2]
+	}
+	err := ws.writeFrame(CloseMessage, closePayload(code, reason), false)
+	if err != nil {
+		ws.closeConn()
+		return err
+	}
+	ws.writeMu.Lock()
+	ws.closeTimer = time.AfterFunc(ws.option.CloseWait, ws.closeConn)
+	ws.writeMu.Unlock()
+	return nil
+}
+
+// closePayload builds the payload of a close frame.
+func closePayload(code int, reason string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	b := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	copy(b[2:], reason)
+	return b
+}
+
+// closeConn terminates the underlying network connection.
+func (ws *WebSocket) closeConn() {
+	ws.closeOnce.Do(func() {
+		ws.closeSent.Store(true)
+		close(ws.done)
+		ws.writeMu.Lock()
+		if ws.closeTimer != nil {
+			ws.closeTimer.Stop()
+		}
+		ws.writeMu.Unlock()
+		_ = ws.conn.Close()
+	})
+}
+
+// writeFrame writes a single unmasked frame to the connection.
+func (ws *WebSocket) writeFrame(op byte, payload []byte, compressed bool) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	select {
+	case <-ws.done:
+		return ErrWebSocketClosed
+	default:
+	}
+
+	b := ws.writeBuf[:0]
+	first := 0x80 | op
+	if compressed {
+		first |= 0x40
+	}
+	b = append(b, first)
+
+	l := len(payload)
+	switch {
+	case l <= 125:
+		b = append(b, byte(l))
+	case l <= 0xffff:
+		b = append(b, 126, byte(l>>8), byte(l))
+	default:
+		b = append(b, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[len(b)-8:], uint64(l))
+	}
+	b = append(b, payload...)
+	if cap(b) <= 4096 {
+		ws.writeBuf = b
+	}
+
+	if ws.option.WriteWait > 0 {
+		_ = ws.conn.SetWriteDeadline(time.Now().Add(ws.option.WriteWait))
+	}
+	_, err := ws.conn.Write(b)
+	return err
+}
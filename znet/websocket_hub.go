@@ -0,0 +1,470 @@
+package znet
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sohaha/zlsgo/zstring"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+// WebSocketPolicy decides what happens when a client's send queue is full.
+type WebSocketPolicy int
+
+const (
+	// WebSocketPolicyDrop discards the message for the slow client.
+	WebSocketPolicyDrop WebSocketPolicy = iota
+	// WebSocketPolicyBlock waits for queue space, up to BlockTimeout when set.
+	WebSocketPolicyBlock
+	// WebSocketPolicyDisconnect closes the slow client's connection.
+	WebSocketPolicyDisconnect
+)
+
+var (
+	// ErrWebSocketHubClosed is returned when using a hub that has been closed.
+	ErrWebSocketHubClosed = errors.New("websocket: hub closed")
+	// ErrWebSocketSlowConsumer is returned when a message could not be queued for a client.
+	ErrWebSocketSlowConsumer = errors.New("websocket: client send queue is full")
+)
+
+type (
+	// WebSocketHubOption defines configuration options for a WebSocketHub.
+	WebSocketHubOption struct {
+		// OnConnect is called after a client has been registered
+		OnConnect func(client *WebSocketClient)
+		// OnDisconnect is called after a client has been removed
+		OnDisconnect func(client *WebSocketClient)
+		// SendBuffer is the size of each client's outgoing message queue
+		SendBuffer int
+		// BlockTimeout limits how long WebSocketPolicyBlock waits, zero waits until the client leaves
+		BlockTimeout time.Duration
+		// Policy is the backpressure policy applied to slow clients
+		Policy WebSocketPolicy
+	}
+
+	// WebSocketHub tracks connected WebSocket clients and fans messages out to them.
+	WebSocketHub struct {
+		clients map[*WebSocketClient]struct{}
+		rooms   map[string]map[*WebSocketClient]struct{}
+		option  WebSocketHubOption
+		closed  *zutil.Bool
+		wg      sync.WaitGroup
+		mu      sync.RWMutex
+	}
+
+	// WebSocketClient is a WebSocket connection registered in a hub.
+	WebSocketClient struct {
+		ws      *WebSocket
+		hub     *WebSocketHub
+		send    chan wsHubMessage
+		quit    chan struct{}
+		rooms   map[string]struct{}
+		values  sync.Map
+		id      string
+		reason  string
+		code    int
+		stop    sync.Once
+		mu      sync.RWMutex
+		dropped *zutil.Uint64
+	}
+
+	wsHubMessage struct {
+		data        []byte
+		messageType int
+	}
+)
+
+// NewWebSocketHub creates a hub whose clients are closed gracefully on engine shutdown.
+func NewWebSocketHub(e *Engine, opts ...func(o *WebSocketHubOption)) *WebSocketHub {
+	o := zutil.Optional(WebSocketHubOption{
+		SendBuffer: 256,
+		Policy:     WebSocketPolicyDrop,
+	}, opts...)
+	if o.SendBuffer <= 0 {
+		o.SendBuffer = 1
+	}
+
+	h := &WebSocketHub{
+		clients: make(map[*WebSocketClient]struct{}),
+		rooms:   make(map[string]map[*WebSocketClient]struct{}),
+		option:  o,
+		closed:  zutil.NewBool(false),
+	}
+	if e != nil {
+		e.AddShutdown(h.Close)
+	}
+	return h
+}
+
+// Handle upgrades the request, registers the client and reads messages until it disconnects.
+// onMessage is called for every data message received from the client.
+func (h *WebSocketHub) Handle(c *Context, onMessage func(client *WebSocketClient, messageType int, data []byte), opts ...func(o *WebSocketOption)) error {
+	if h.closed.Load() {
+		return ErrWebSocketHubClosed
+	}
+
+	ws, err := c.Upgrade(opts...)
+	if err != nil {
+		return err
+	}
+
+	client, err := h.Register(ws)
+	if err != nil {
+		_ = ws.Close(CloseGoingAway, "")
+		return err
+	}
+	defer h.Unregister(client)
+
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			if IsWebSocketClose(err) {
+				return nil
+			}
+			return err
+		}
+		if onMessage != nil {
+			onMessage(client, messageType, data)
+		}
+	}
+}
+
+// Register adds an upgraded connection to the hub and starts its writer.
+func (h *WebSocketHub) Register(ws *WebSocket) (*WebSocketClient, error) {
+	client := &WebSocketClient{
+		ws:      ws,
+		hub:     h,
+		id:      zstring.UUID(),
+		send:    make(chan wsHubMessage, h.option.SendBuffer),
+		quit:    make(chan struct{}),
+		rooms:   make(map[string]struct{}),
+		dropped: zutil.NewUint64(0),
+	}
+
+	h.mu.Lock()
+	if h.closed.Load() {
+		h.mu.Unlock()
+		return nil, ErrWebSocketHubClosed
+	}
+	h.clients[client] = struct{}{}
+	h.wg.Add(1)
+	h.mu.Unlock()
+
+	go client.writeLoop()
+
+	if h.option.OnConnect != nil {
+		h.option.OnConnect(client)
+	}
+	return client, nil
+}
+
+// Unregister removes a client from the hub and all of its rooms,
+// its connection is closed once the queued messages are flushed.
+func (h *WebSocketHub) Unregister(client *WebSocketClient) {
+	h.mu.Lock()
+	if _, ok := h.clients[client]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.clients, client)
+	client.mu.Lock()
+	for room := range client.rooms {
+		h.removeFromRoom(room, client)
+	}
+	client.rooms = make(map[string]struct{})
+	client.mu.Unlock()
+	h.mu.Unlock()
+
+	client.shutdown(CloseNormalClosure, "")
+
+	if h.option.OnDisconnect != nil {
+		h.option.OnDisconnect(client)
+	}
+}
+
+// removeFromRoom deletes a client from a room, the hub lock must be held.
+func (h *WebSocketHub) removeFromRoom(room string, client *WebSocketClient) {
+	members, ok := h.rooms[room]
+	if !ok {
+		return
+	}
+	delete(members, client)
+	if len(members) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// Join adds the client to the named rooms.
+func (h *WebSocketHub) Join(client *WebSocketClient, rooms ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	client.mu.Lock()
+	for _, room := range rooms {
+		members, ok := h.rooms[room]
+		if !ok {
+			members = make(map[*WebSocketClient]struct{})
+			h.rooms[room] = members
+		}
+		members[client] = struct{}{}
+		client.rooms[room] = struct{}{}
+	}
+	client.mu.Unlock()
+}
+
+// Leave removes the client from the named rooms.
+func (h *WebSocketHub) Leave(client *WebSocketClient, rooms ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.mu.Lock()
+	for _, room := range rooms {
+		h.removeFromRoom(room, client)
+		delete(client.rooms, room)
+	}
+	client.mu.Unlock()
+}
+
+// Broadcast sends a message to every connected client.
+// It returns the number of clients the message was queued for.
+func (h *WebSocketHub) Broadcast(messageType int, data []byte, except ...*WebSocketClient) int {
+	h.mu.RLock()
+	targets := make([]*WebSocketClient, 0, len(h.clients))
+	for client := range h.clients {
+		targets = append(targets, client)
+	}
+	h.mu.RUnlock()
+	return h.deliver(targets, messageType, data, except)
+}
+
+// BroadcastTo sends a message to every client in the named room.
+// It returns the number of clients the message was queued for.
+func (h *WebSocketHub) BroadcastTo(room string, messageType int, data []byte, except ...*WebSocketClient) int {
+	h.mu.RLock()
+	members := h.rooms[room]
+	targets := make([]*WebSocketClient, 0, len(members))
+	for client := range members {
+		targets = append(targets, client)
+	}
+	h.mu.RUnlock()
+	return h.deliver(targets, messageType, data, except)
+}
+
+// deliver queues a message for each target applying the backpressure policy.
+func (h *WebSocketHub) deliver(targets []*WebSocketClient, messageType int, data []byte, except []*WebSocketClient) int {
+	n := 0
+	for _, client := range targets {
+		skip := false
+		for i := range except {
+			if except[i] == client {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+		if client.Send(messageType, data) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Clients returns the number of connected clients.
+func (h *WebSocketHub) Clients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// RoomClients returns the number of clients in the named room.
+func (h *WebSocketHub) RoomClients(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Rooms returns the names of all rooms with at least one client, sorted.
+func (h *WebSocketHub) Rooms() []string {
+	h.mu.RLock()
+	rooms := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.RUnlock()
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Close flushes pending messages, closes every client with CloseGoingAway
+// and rejects new registrations. It is registered as an engine shutdown hook.
+func (h *WebSocketHub) Close() {
+	if !h.closed.CAS(false, true) {
+		return
+	}
+	h.mu.RLock()
+	clients := make([]*WebSocketClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.shutdown(CloseGoingAway, "server shutdown")
+	}
+	h.wg.Wait()
+}
+
+// ID returns the unique identifier of the client.
+func (c *WebSocketClient) ID() string {
+	return c.id
+}
+
+// Conn returns the underlying WebSocket connection.
+func (c *WebSocketClient) Conn() *WebSocket {
+	return c.ws
+}
+
+// Set stores a value on the client, for example the authenticated user.
+func (c *WebSocketClient) Set(key string, value interface{}) {
+	c.values.Store(key, value)
+}
+
+// Get retrieves a value stored on the client.
+func (c *WebSocketClient) Get(key string) (interface{}, bool) {
+	return c.values.Load(key)
+}
+
+// Rooms returns the rooms the client has joined, sorted.
+func (c *WebSocketClient) Rooms() []string {
+	c.mu.RLock()
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	c.mu.RUnlock()
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Join adds the client to the named rooms.
+func (c *WebSocketClient) Join(rooms ...string) {
+	c.hub.Join(c, rooms...)
+}
+
+// Leave removes the client from the named rooms.
+func (c *WebSocketClient) Leave(rooms ...string) {
+	c.hub.Leave(c, rooms...)
+}
+
+// Dropped returns the number of messages discarded for this client.
+func (c *WebSocketClient) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// Send queues a message for the client according to the hub backpressure policy.
+func (c *WebSocketClient) Send(messageType int, data []byte) error {
+	msg := wsHubMessage{messageType: messageType, data: data}
+	select {
+	case <-c.quit:
+		return ErrWebSocketClosed
+	default:
+	}
+
+	select {
+	case c.send <- msg:
+		return nil
+	default:
+	}
+
+	switch c.hub.option.Policy {
+	case WebSocketPolicyBlock:
+		var timeout <-chan time.Time
+		if c.hub.option.BlockTimeout > 0 {
+			timer := time.NewTimer(c.hub.option.BlockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case c.send <- msg:
+			return nil
+		case <-c.quit:
+			return ErrWebSocketClosed
+		case <-timeout:
+		}
+	case WebSocketPolicyDisconnect:
+		c.dropped.Add(1)
+		go c.hub.disconnect(c, ClosePolicyViolation, "slow consumer")
+		return ErrWebSocketSlowConsumer
+	}
+
+	c.dropped.Add(1)
+	return ErrWebSocketSlowConsumer
+}
+
+// Close removes the client from the hub and closes its connection.
+func (c *WebSocketClient) Close() {
+	c.hub.disconnect(c, CloseNormalClosure, "")
+}
+
+// disconnect closes the client connection with the given code and unregisters it.
+func (h *WebSocketHub) disconnect(client *WebSocketClient, code int, reason string) {
+	_ = client.ws.Close(code, reason)
+	h.Unregister(client)
+}
+
+// shutdown stops the writer after it has flushed the queued messages,
+// the connection is then closed with code and reason, only the first call counts.
+func (c *WebSocketClient) shutdown(code int, reason string) {
+	c.stop.Do(func() {
+		c.code, c.reason = code, reason
+		close(c.quit)
+	})
+}
+
+// writeLoop writes queued messages to the connection until the client stops.
+func (c *WebSocketClient) writeLoop() {
+	defer c.hub.wg.Done()
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.ws.WriteMessage(msg.messageType, msg.data); err != nil {
+				go c.hub.Unregister(c)
+				c.drain()
+				return
+			}
+		case <-c.ws.Done():
+			go c.hub.Unregister(c)
+			c.drain()
+			return
+		case <-c.quit:
+			for {
+				select {
+				case msg := <-c.send:
+					if c.ws.WriteMessage(msg.messageType, msg.data) != nil {
+						return
+					}
+				default:
+					_ = c.ws.Close(c.code, c.reason)
+					return
+				}
+			}
+		}
+	}
+}
+
+// drain discards queued messages once the connection is gone.
+func (c *WebSocketClient) drain() {
+	for {
+		select {
+		case <-c.send:
+			c.dropped.Add(1)
+		default:
+			return
+		}
+	}
+}
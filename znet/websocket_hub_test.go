@@ -0,0 +1,148 @@
+package znet
+
+import (
+	"encoding/binary"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+	"github.com/sohaha/zlsgo/zutil"
+)
+
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestWebSocketHub(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("websocket-hub-test-" + t.Name())
+	r.SetMode(QuietMode)
+
+	hub := NewWebSocketHub(r)
+	r.GET("/ws", func(c *Context) error {
+		room := c.DefaultQuery("room", "")
+		return hub.Handle(c, func(client *WebSocketClient, messageType int, data []byte) {
+			hub.BroadcastTo(room, messageType, data, client)
+		}, func(o *WebSocketOption) {
+			o.CheckOrigin = func(c *Context) bool { return true }
+		})
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	joined := make(chan *WebSocketClient, 3)
+	hub.option.OnConnect = func(client *WebSocketClient) {
+		joined <- client
+	}
+
+	a := dialWebSocket(t, addr, "/ws?room=lobby", nil)
+	defer a.conn.Close()
+	ca := <-joined
+	ca.Join("lobby")
+	b := dialWebSocket(t, addr, "/ws?room=lobby", nil)
+	defer b.conn.Close()
+	cb := <-joined
+	cb.Join("lobby", "vip")
+	c := dialWebSocket(t, addr, "/ws?room=other", nil)
+	defer c.conn.Close()
+	<-joined
+
+	tt.Equal(3, hub.Clients())
+	tt.Equal(2, hub.RoomClients("lobby"))
+	tt.Equal([]string{"lobby", "vip"}, hub.Rooms())
+	tt.Equal([]string{"lobby", "vip"}, cb.Rooms())
+
+	a.writeFrame(TextMessage, []byte("hi lobby"), true, false)
+	op, _, payload, err := b.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(TextMessage), op)
+	tt.Equal("hi lobby", string(payload))
+
+	tt.Equal(3, hub.Broadcast(TextMessage, []byte("all")))
+	for _, client := range []*wsTestClient{a, b, c} {
+		_, _, payload, err = client.readFrame()
+		tt.NoError(err, true)
+		tt.Equal("all", string(payload))
+	}
+
+	b.writeFrame(CloseMessage, closePayload(CloseNormalClosure, ""), true, false)
+	_, _, _, _ = b.readFrame()
+	tt.EqualTrue(waitFor(func() bool { return hub.Clients() == 2 }))
+	tt.Equal(1, hub.RoomClients("lobby"))
+	tt.Equal([]string{"lobby"}, hub.Rooms())
+
+	d := dialWebSocket(t, addr, "/ws?room=other", nil)
+	defer d.conn.Close()
+	hub.Unregister(<-joined)
+	op, _, payload, err = d.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(CloseMessage), op)
+	tt.Equal(CloseNormalClosure, int(binary.BigEndian.Uint16(payload)))
+	d.writeFrame(CloseMessage, payload[:2], true, false)
+	tt.EqualTrue(waitFor(func() bool { return hub.Clients() == 2 }))
+
+	hub.Close()
+	for _, client := range []*wsTestClient{a, c} {
+		op, _, payload, err = client.readFrame()
+		tt.NoError(err, true)
+		tt.Equal(byte(CloseMessage), op)
+		tt.Equal(CloseGoingAway, int(binary.BigEndian.Uint16(payload)))
+		client.writeFrame(CloseMessage, payload[:2], true, false)
+	}
+	tt.EqualTrue(waitFor(func() bool { return hub.Clients() == 0 }))
+	_, err = hub.Register(&WebSocket{})
+	tt.Equal(ErrWebSocketHubClosed, err)
+}
+
+func TestWebSocketHubPolicy(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+
+	newClient := func(policy WebSocketPolicy) *WebSocketClient {
+		hub := NewWebSocketHub(nil, func(o *WebSocketHubOption) {
+			o.SendBuffer = 1
+			o.Policy = policy
+			o.BlockTimeout = 10 * time.Millisecond
+		})
+		ws := &WebSocket{done: make(chan struct{}), option: &WebSocketOption{}}
+		client := &WebSocketClient{
+			ws:      ws,
+			hub:     hub,
+			send:    make(chan wsHubMessage, 1),
+			quit:    make(chan struct{}),
+			rooms:   map[string]struct{}{},
+			dropped: zutil.NewUint64(0),
+		}
+		hub.clients[client] = struct{}{}
+		return client
+	}
+
+	client := newClient(WebSocketPolicyDrop)
+	tt.NoError(client.Send(TextMessage, []byte("1")))
+	tt.Equal(ErrWebSocketSlowConsumer, client.Send(TextMessage, []byte("2")))
+	tt.Equal(uint64(1), client.Dropped())
+
+	client = newClient(WebSocketPolicyBlock)
+	tt.NoError(client.Send(TextMessage, []byte("1")))
+	go func() {
+		time.Sleep(2 * time.Millisecond)
+		<-client.send
+	}()
+	tt.NoError(client.Send(TextMessage, []byte("2")))
+	tt.Equal(ErrWebSocketSlowConsumer, client.Send(TextMessage, []byte("3")))
+
+	client = newClient(WebSocketPolicyDisconnect)
+	client.ws.closeSent = zutil.NewBool(true)
+	tt.NoError(client.Send(TextMessage, []byte("1")))
+	tt.Equal(ErrWebSocketSlowConsumer, client.Send(TextMessage, []byte("2")))
+}
@@ -0,0 +1,294 @@
+package znet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+)
+
+type wsTestClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dialWebSocket(t *testing.T, addr, path string, header http.Header) *wsTestClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "http://"+addr+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err = req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsTestClient{conn: conn, br: br, resp: resp}
+}
+
+func (w *wsTestClient) writeFrame(op byte, payload []byte, fin, rsv1 bool) {
+	first := op
+	if fin {
+		first |= 0x80
+	}
+	if rsv1 {
+		first |= 0x40
+	}
+	b := []byte{first}
+	l := len(payload)
+	switch {
+	case l <= 125:
+		b = append(b, 0x80|byte(l))
+	case l <= 0xffff:
+		b = append(b, 0x80|126, byte(l>>8), byte(l))
+	default:
+		b = append(b, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[len(b)-8:], uint64(l))
+	}
+	mask := []byte{1, 2, 3, 4}
+	b = append(b, mask...)
+	for i := range payload {
+		b = append(b, payload[i]^mask[i%4])
+	}
+	_, _ = w.conn.Write(b)
+}
+
+func (w *wsTestClient) readFrame() (op byte, rsv1 bool, payload []byte, err error) {
+	_ = w.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var h [2]byte
+	if _, err = io.ReadFull(w.br, h[:]); err != nil {
+		return
+	}
+	op = h[0] & 0x0f
+	rsv1 = h[0]&0x40 != 0
+	l := int(h[1] & 0x7f)
+	switch l {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(w.br, ext[:])
+		l = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(w.br, ext[:])
+		l = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload = make([]byte, l)
+	_, err = io.ReadFull(w.br, payload)
+	return
+}
+
+func TestWebSocketUpgrade(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("websocket-test-" + t.Name())
+	r.SetMode(QuietMode)
+
+	r.GET("/ws", func(c *Context) {
+		ws, err := c.Upgrade(func(o *WebSocketOption) {
+			o.Subprotocols = []string{"chat"}
+		})
+		if err != nil {
+			return
+		}
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = ws.WriteMessage(mt, append([]byte("echo:"), data...))
+		}
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	client := dialWebSocket(t, addr, "/ws", http.Header{"Sec-Websocket-Protocol": {"superchat, chat"}})
+	defer client.conn.Close()
+	tt.Equal(http.StatusSwitchingProtocols, client.resp.StatusCode)
+	tt.Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", client.resp.Header.Get("Sec-WebSocket-Accept"))
+	tt.Equal("chat", client.resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	client.writeFrame(TextMessage, []byte("hello"), true, false)
+	op, _, payload, err := client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(TextMessage), op)
+	tt.Equal("echo:hello", string(payload))
+
+	client.writeFrame(BinaryMessage, []byte("frag"), false, false)
+	client.writeFrame(PingMessage, []byte("p"), true, false)
+	client.writeFrame(continuationFrame, []byte("ment"), true, false)
+	op, _, payload, err = client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(PongMessage), op)
+	tt.Equal("p", string(payload))
+	op, _, payload, err = client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(BinaryMessage), op)
+	tt.Equal("echo:fragment", string(payload))
+
+	client.writeFrame(CloseMessage, closePayload(CloseNormalClosure, "bye"), true, false)
+	op, _, payload, err = client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(CloseMessage), op)
+	tt.Equal(CloseNormalClosure, int(binary.BigEndian.Uint16(payload)))
+}
+
+// deadlineHijacker leaves a read deadline on the hijacked connection,
+// as servers that do not clear their ReadTimeout on hijack do
+type deadlineHijacker struct {
+	http.ResponseWriter
+}
+
+func (w deadlineHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	}
+	return conn, brw, err
+}
+
+func TestWebSocketIdleReadDeadline(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("websocket-test-" + t.Name())
+	r.SetMode(QuietMode)
+
+	r.GET("/ws", func(c *Context) {
+		c.Writer = deadlineHijacker{c.Writer}
+		ws, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		mt, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = ws.WriteMessage(mt, data)
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := dialWebSocket(t, strings.TrimPrefix(srv.URL, "http://"), "/ws", nil)
+	defer client.conn.Close()
+	tt.Equal(http.StatusSwitchingProtocols, client.resp.StatusCode)
+
+	time.Sleep(150 * time.Millisecond)
+	client.writeFrame(TextMessage, []byte("idle"), true, false)
+	_, _, payload, err := client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal("idle", string(payload))
+}
+
+func TestWebSocketCompressionAndLimit(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("websocket-test-" + t.Name())
+	r.SetMode(QuietMode)
+
+	errs := make(chan error, 1)
+	r.GET("/ws", func(c *Context) {
+		ws, err := c.Upgrade(func(o *WebSocketOption) {
+			o.EnableCompression = true
+			o.MaxMessageSize = 64
+		})
+		if err != nil {
+			return
+		}
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			_ = ws.WriteMessage(mt, data)
+		}
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	client := dialWebSocket(t, addr, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}})
+	defer client.conn.Close()
+	tt.Contains("permessage-deflate", client.resp.Header.Get("Sec-WebSocket-Extensions"))
+
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	_, _ = fw.Write([]byte("compressed hello"))
+	_ = fw.Flush()
+	client.writeFrame(TextMessage, bytes.TrimSuffix(buf.Bytes(), websocketDeflateTail), true, true)
+
+	op, rsv1, payload, err := client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(TextMessage), op)
+	tt.EqualTrue(rsv1)
+	data, _ := io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(websocketDeflateTail))))
+	tt.Equal("compressed hello", string(data))
+
+	client.writeFrame(TextMessage, bytes.Repeat([]byte("a"), 100), true, false)
+	op, _, payload, err = client.readFrame()
+	tt.NoError(err, true)
+	tt.Equal(byte(CloseMessage), op)
+	tt.Equal(CloseMessageTooBig, int(binary.BigEndian.Uint16(payload)))
+	tt.Equal(ErrWebSocketMessageTooBig, <-errs)
+}
+
+func TestWebSocketHandshakeErrors(t *testing.T) {
+	tt := zlsgo.NewTest(t)
+	r := New("websocket-test-" + t.Name())
+	r.SetMode(QuietMode)
+
+	r.GET("/ws", func(c *Context) error {
+		_, err := c.Upgrade()
+		if err != nil {
+			c.Log.Debug(err)
+		}
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/ws", nil)
+	r.ServeHTTP(w, req)
+	tt.Equal(http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Connection", "upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	r.ServeHTTP(w, req)
+	tt.Equal(http.StatusUpgradeRequired, w.Code)
+	tt.Equal("13", w.Header().Get("Sec-WebSocket-Version"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Connection", "upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://evil.com")
+	r.ServeHTTP(w, req)
+	tt.Equal(http.StatusForbidden, w.Code)
+
+	tt.EqualTrue(IsWebSocketClose(&WebSocketCloseError{Code: CloseGoingAway}, CloseGoingAway))
+	tt.EqualFalse(IsWebSocketClose(&WebSocketCloseError{Code: CloseGoingAway}, CloseNormalClosure))
+}
@@ -29,6 +29,51 @@ type SystemInfo struct {
 	HeapInuse    string // heap memory in use
 }
 
+// SystemStats numeric runtime statistics, suitable for metrics collectors
+type SystemStats struct {
+	LastGC       time.Time
+	Uptime       time.Duration
+	Goroutines   int
+	CPUs         int
+	HeapInuse    uint64
+	Alloc        uint64
+	TotalAlloc   uint64
+	Sys          uint64
+	Lookups      uint64
+	Mallocs      uint64
+	Frees        uint64
+	NextGC       uint64
+	PauseTotalNs uint64
+	PauseNs      uint64
+	NumGC        uint32
+}
+
+// NewSystemStats reads the current runtime statistics
+func NewSystemStats(startTime time.Time) *SystemStats {
+	mstat := &runtime.MemStats{}
+	runtime.ReadMemStats(mstat)
+	s := &SystemStats{
+		Uptime:       time.Since(startTime),
+		Goroutines:   runtime.NumGoroutine(),
+		CPUs:         runtime.NumCPU(),
+		HeapInuse:    mstat.HeapInuse,
+		Alloc:        mstat.Alloc,
+		TotalAlloc:   mstat.TotalAlloc,
+		Sys:          mstat.Sys,
+		Lookups:      mstat.Lookups,
+		Mallocs:      mstat.Mallocs,
+		Frees:        mstat.Frees,
+		NextGC:       mstat.NextGC,
+		PauseTotalNs: mstat.PauseTotalNs,
+		PauseNs:      mstat.PauseNs[(mstat.NumGC+255)%256],
+		NumGC:        mstat.NumGC,
+	}
+	if mstat.LastGC != 0 {
+		s.LastGC = time.Unix(0, int64(mstat.LastGC))
+	}
+	return s
+}
+
 func NewSystemInfo(startTime time.Time) *SystemInfo {
 	var afterLastGC string
 	mstat := &runtime.MemStats{}
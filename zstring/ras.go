@@ -193,10 +193,24 @@ func pubKey(publicKey []byte) (*rsa.PublicKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	pub, _ := publicKeyInterface.(*rsa.PublicKey)
+	pub, ok := publicKeyInterface.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
 	return pub, nil
 }
 
+// ParseRSAPublicKey parses a PEM encoded PKIX RSA public key.
+func ParseRSAPublicKey(publicKey []byte) (*rsa.PublicKey, error) {
+	return pubKey(publicKey)
+}
+
+// ParseRSAPrivateKey parses a PEM encoded RSA private key.
+// Supports both PKCS#1 and PKCS#8 formats.
+func ParseRSAPrivateKey(privateKey []byte) (*rsa.PrivateKey, error) {
+	return priKey(privateKey)
+}
+
 // priKey parses a PEM encoded RSA private key.
 // Supports both PKCS#1 and PKCS#8 formats.
 func priKey(privateKey []byte) (*rsa.PrivateKey, error) {
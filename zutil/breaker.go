@@ -0,0 +1,384 @@
+package zutil
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int32
+
+const (
+	// BreakerClosed lets all calls through and counts their failures
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects all calls until OpenTimeout passes
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probe calls through
+	BreakerHalfOpen
+)
+
+// ErrBreakerOpen is returned when the circuit breaker rejects a call
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// String returns the name of the state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConf holds configuration options for the circuit breaker.
+type BreakerConf struct {
+	// OnStateChange is called after the state of the breaker changed
+	OnStateChange func(name string, from, to BreakerState)
+	// IsFailure reports whether the error of a call counts as a failure,
+	// default is any non-nil error
+	IsFailure func(err error) bool
+	// Window is the length of the rolling window failures are counted in
+	Window time.Duration
+	// Buckets is the number of buckets the window is split into
+	Buckets int
+	// OpenTimeout is how long the breaker stays open before probing
+	OpenTimeout time.Duration
+	// FailureRatio trips the breaker when the failure ratio of the window reaches it,
+	// once the window has MinRequests calls, zero disables it
+	FailureRatio float64
+	// MinRequests is the number of calls in the window before FailureRatio applies
+	MinRequests int64
+	// ConsecutiveFailures trips the breaker after that many failures in a row, zero disables it
+	ConsecutiveFailures int64
+	// HalfOpenProbes is the number of concurrent probes in the half-open state,
+	// the breaker closes once that many probes succeeded
+	HalfOpenProbes int64
+}
+
+// BreakerLogger receives the state changes logged by LogBreakerStateChange, *zlog.Logger implements it
+type BreakerLogger interface {
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+}
+
+// LogBreakerStateChange returns an OnStateChange that logs the state changes to log
+// before calling next, opening is logged as a warning, a nil log only calls next
+func LogBreakerStateChange(log BreakerLogger, next func(name string, from, to BreakerState)) func(name string, from, to BreakerState) {
+	return func(name string, from, to BreakerState) {
+		if log != nil {
+			if to == BreakerOpen {
+				log.Warnf("circuit breaker %s: %s -> %s", name, from, to)
+			} else {
+				log.Infof("circuit breaker %s: %s -> %s", name, from, to)
+			}
+		}
+		if next != nil {
+			next(name, from, to)
+		}
+	}
+}
+
+// BreakerCounts are the calls counted in the rolling window
+type BreakerCounts struct {
+	Requests            int64
+	Failures            int64
+	ConsecutiveFailures int64
+}
+
+type breakerBucket struct {
+	epoch    int64
+	requests int64
+	failures int64
+}
+
+// Breaker is a circuit breaker that stops calling a failing dependency
+// and probes it again after a while.
+type Breaker struct {
+	openedAt    time.Time
+	conf        BreakerConf
+	name        string
+	buckets     []breakerBucket
+	bucketSize  time.Duration
+	consecutive int64
+	probes      int64
+	successes   int64
+	generation  uint64
+	mu          sync.Mutex
+	state       BreakerState
+}
+
+// DefaultBreakerConf returns the default configuration, the breaker opens after 5
+// consecutive failures or a failure ratio of 50% over at least 10 calls in 10 seconds
+func DefaultBreakerConf() BreakerConf {
+	return BreakerConf{
+		Window:              10 * time.Second,
+		Buckets:             10,
+		OpenTimeout:         5 * time.Second,
+		FailureRatio:        0.5,
+		MinRequests:         10,
+		ConsecutiveFailures: 5,
+		HalfOpenProbes:      1,
+	}
+}
+
+// NewBreaker creates a circuit breaker with DefaultBreakerConf changed by opt
+func NewBreaker(name string, opt ...func(*BreakerConf)) *Breaker {
+	conf := Optional(DefaultBreakerConf(), opt...)
+	if conf.Buckets <= 0 {
+		conf.Buckets = 1
+	}
+	if conf.Window <= 0 {
+		conf.Window = 10 * time.Second
+	}
+	if conf.HalfOpenProbes <= 0 {
+		conf.HalfOpenProbes = 1
+	}
+	if conf.IsFailure == nil {
+		conf.IsFailure = func(err error) bool { return err != nil }
+	}
+
+	bucketSize := conf.Window / time.Duration(conf.Buckets)
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	return &Breaker{
+		name:       name,
+		conf:       conf,
+		buckets:    make([]breakerBucket, conf.Buckets),
+		bucketSize: bucketSize,
+	}
+}
+
+// Name returns the name of the breaker
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.conf.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Counts returns the calls counted in the rolling window
+func (b *Breaker) Counts() BreakerCounts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	requests, failures := b.window(time.Now())
+	return BreakerCounts{Requests: requests, Failures: failures, ConsecutiveFailures: b.consecutive}
+}
+
+// Allow reports whether a call may proceed, done must be called with
+// the result of the call when it is allowed
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	now := time.Now()
+	from := b.state
+	if b.state == BreakerOpen {
+		if now.Sub(b.openedAt) < b.conf.OpenTimeout {
+			b.mu.Unlock()
+			return nil, ErrBreakerOpen
+		}
+		b.setState(BreakerHalfOpen, now)
+	}
+	to := b.state
+	if to == BreakerHalfOpen {
+		if b.probes >= b.conf.HalfOpenProbes {
+			b.mu.Unlock()
+			b.notify(from, to)
+			return nil, ErrBreakerOpen
+		}
+		b.probes++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(from, to)
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.done(generation, b.conf.IsFailure(err))
+		})
+	}, nil
+}
+
+// Do calls fn if the breaker allows it and records its result
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			done(errors.New("panic"))
+			panic(r)
+		}
+	}()
+	err = fn()
+	done(err)
+	return err
+}
+
+// Reset closes the breaker and clears its counts
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	from := b.state
+	b.setState(BreakerClosed, time.Now())
+	b.mu.Unlock()
+	b.notify(from, BreakerClosed)
+}
+
+func (b *Breaker) done(generation uint64, failed bool) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	from := b.state
+	switch b.state {
+	case BreakerClosed:
+		bucket := b.bucket(now)
+		bucket.requests++
+		if failed {
+			bucket.failures++
+			b.consecutive++
+		} else {
+			b.consecutive = 0
+		}
+		if failed && b.shouldTrip(now) {
+			b.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		b.probes--
+		if failed {
+			b.setState(BreakerOpen, now)
+		} else if b.successes++; b.successes >= b.conf.HalfOpenProbes {
+			b.setState(BreakerClosed, now)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	if b.conf.ConsecutiveFailures > 0 && b.consecutive >= b.conf.ConsecutiveFailures {
+		return true
+	}
+	if b.conf.FailureRatio <= 0 {
+		return false
+	}
+	requests, failures := b.window(now)
+	return requests >= b.conf.MinRequests && requests > 0 &&
+		float64(failures)/float64(requests) >= b.conf.FailureRatio
+}
+
+func (b *Breaker) setState(state BreakerState, now time.Time) {
+	b.state = state
+	b.generation++
+	b.probes, b.successes, b.consecutive = 0, 0, 0
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+	for i := range b.buckets {
+		b.buckets[i] = breakerBucket{}
+	}
+}
+
+func (b *Breaker) notify(from, to BreakerState) {
+	if from != to && b.conf.OnStateChange != nil {
+		b.conf.OnStateChange(b.name, from, to)
+	}
+}
+
+func (b *Breaker) bucket(now time.Time) *breakerBucket {
+	epoch := now.UnixNano() / int64(b.bucketSize)
+	bucket := &b.buckets[epoch%int64(len(b.buckets))]
+	if bucket.epoch != epoch {
+		*bucket = breakerBucket{epoch: epoch}
+	}
+	return bucket
+}
+
+func (b *Breaker) window(now time.Time) (requests, failures int64) {
+	epoch := now.UnixNano() / int64(b.bucketSize)
+	for i := range b.buckets {
+		if epoch-b.buckets[i].epoch < int64(len(b.buckets)) {
+			requests += b.buckets[i].requests
+			failures += b.buckets[i].failures
+		}
+	}
+	return
+}
+
+// Breakers is a set of circuit breakers keyed by name, e.g. one per host,
+// created on first use with the same options.
+type Breakers struct {
+	breakers map[string]*Breaker
+	opt      []func(*BreakerConf)
+	mu       sync.RWMutex
+}
+
+// NewBreakers creates a set of circuit breakers
+func NewBreakers(opt ...func(*BreakerConf)) *Breakers {
+	return &Breakers{breakers: make(map[string]*Breaker), opt: opt}
+}
+
+// Get returns the breaker of name, creating it if needed
+func (s *Breakers) Get(name string) *Breaker {
+	s.mu.RLock()
+	b, ok := s.breakers[name]
+	s.mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok = s.breakers[name]; !ok {
+		b = NewBreaker(name, s.opt...)
+		s.breakers[name] = b
+	}
+	return b
+}
+
+// States returns the state of every breaker by name
+func (s *Breakers) States() map[string]BreakerState {
+	states := make(map[string]BreakerState)
+	s.Range(func(b *Breaker) bool {
+		states[b.Name()] = b.State()
+		return true
+	})
+	return states
+}
+
+// Range calls fn for every breaker sorted by name until it returns false
+func (s *Breakers) Range(fn func(b *Breaker) bool) {
+	s.mu.RLock()
+	breakers := make([]*Breaker, 0, len(s.breakers))
+	for _, b := range s.breakers {
+		breakers = append(breakers, b)
+	}
+	s.mu.RUnlock()
+	sort.Slice(breakers, func(i, j int) bool {
+		return breakers[i].name < breakers[j].name
+	})
+
+	for _, b := range breakers {
+		if !fn(b) {
+			return
+		}
+	}
+}
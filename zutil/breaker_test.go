@@ -0,0 +1,126 @@
+package zutil
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sohaha/zlsgo"
+)
+
+func TestBreaker(tt *testing.T) {
+	t := zlsgo.NewTest(tt)
+	fail := errors.New("fail")
+
+	t.Run("Consecutive", func(t *zlsgo.TestUtil) {
+		var changes []string
+		b := NewBreaker("db", func(c *BreakerConf) {
+			c.ConsecutiveFailures = 3
+			c.FailureRatio = 0
+			c.OpenTimeout = 50 * time.Millisecond
+			c.HalfOpenProbes = 2
+			c.OnStateChange = func(name string, from, to BreakerState) {
+				changes = append(changes, name+":"+from.String()+">"+to.String())
+			}
+		})
+
+		t.NoError(b.Do(func() error { return nil }))
+		for i := 0; i < 2; i++ {
+			t.Equal(fail, b.Do(func() error { return fail }))
+		}
+		t.Equal(BreakerClosed, b.State())
+		t.Equal(int64(2), b.Counts().ConsecutiveFailures)
+		t.Equal(int64(3), b.Counts().Requests)
+		t.Equal(fail, b.Do(func() error { return fail }))
+		t.Equal(BreakerOpen, b.State())
+		t.Equal(ErrBreakerOpen, b.Do(func() error { return nil }))
+
+		time.Sleep(60 * time.Millisecond)
+		t.Equal(BreakerHalfOpen, b.State())
+		done1, err := b.Allow()
+		t.NoError(err, true)
+		done2, err := b.Allow()
+		t.NoError(err, true)
+		_, err = b.Allow()
+		t.Equal(ErrBreakerOpen, err)
+
+		done1(nil)
+		t.Equal(BreakerHalfOpen, b.State())
+		done2(fail)
+		t.Equal(BreakerOpen, b.State())
+
+		time.Sleep(60 * time.Millisecond)
+		t.NoError(b.Do(func() error { return nil }))
+		t.NoError(b.Do(func() error { return nil }))
+		t.Equal(BreakerClosed, b.State())
+		t.Equal([]string{
+			"db:closed>open", "db:open>half-open", "db:half-open>open",
+			"db:open>half-open", "db:half-open>closed",
+		}, changes)
+	})
+
+	t.Run("Ratio", func(t *zlsgo.TestUtil) {
+		b := NewBreaker("api", func(c *BreakerConf) {
+			c.ConsecutiveFailures = 0
+			c.MinRequests = 4
+			c.FailureRatio = 0.5
+			c.Window = 100 * time.Millisecond
+			c.Buckets = 2
+			c.IsFailure = func(err error) bool {
+				return err == fail
+			}
+		})
+
+		_ = b.Do(func() error { return fail })
+		_ = b.Do(func() error { return errors.New("ignored") })
+		_ = b.Do(func() error { return fail })
+		t.Equal(BreakerClosed, b.State())
+		time.Sleep(150 * time.Millisecond)
+		t.Equal(int64(0), b.Counts().Requests)
+
+		_ = b.Do(func() error { return nil })
+		_ = b.Do(func() error { return nil })
+		_ = b.Do(func() error { return fail })
+		t.Equal(BreakerClosed, b.State())
+		_ = b.Do(func() error { return fail })
+		t.Equal(BreakerOpen, b.State())
+
+		b.Reset()
+		t.Equal(BreakerClosed, b.State())
+	})
+
+	t.Run("Breakers", func(t *zlsgo.TestUtil) {
+		s := NewBreakers(func(c *BreakerConf) {
+			c.ConsecutiveFailures = 1
+		})
+		t.EqualTrue(s.Get("a") == s.Get("a"))
+		_ = s.Get("b").Do(func() error { return fail })
+		t.Equal(map[string]BreakerState{"a": BreakerClosed, "b": BreakerOpen}, s.States())
+	})
+
+	t.Run("Log", func(t *zlsgo.TestUtil) {
+		log := &breakerLog{}
+		var next []BreakerState
+		fn := LogBreakerStateChange(log, func(name string, from, to BreakerState) {
+			next = append(next, to)
+		})
+		fn("db", BreakerClosed, BreakerOpen)
+		fn("db", BreakerOpen, BreakerHalfOpen)
+		t.Equal([]string{"warn:circuit breaker db: closed -> open", "info:circuit breaker db: open -> half-open"}, log.lines)
+		t.Equal([]BreakerState{BreakerOpen, BreakerHalfOpen}, next)
+		LogBreakerStateChange(nil, nil)("db", BreakerOpen, BreakerClosed)
+	})
+}
+
+type breakerLog struct {
+	lines []string
+}
+
+func (l *breakerLog) Infof(format string, v ...interface{}) {
+	l.lines = append(l.lines, "info:"+fmt.Sprintf(format, v...))
+}
+
+func (l *breakerLog) Warnf(format string, v ...interface{}) {
+	l.lines = append(l.lines, "warn:"+fmt.Sprintf(format, v...))
+}
@@ -15,6 +15,7 @@ import (
 
 // Regex regular expression match
 func (v Engine) Regex(pattern string, customError ...string) Engine {
+	describe(&v, "regex", pattern)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -28,6 +29,7 @@ func (v Engine) Regex(pattern string, customError ...string) Engine {
 
 // IsBool boolean value
 func (v Engine) IsBool(customError ...string) Engine {
+	describe(&v, "bool")
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -89,6 +91,7 @@ func (v Engine) IsLetter(customError ...string) Engine {
 
 // IsNumber is number
 func (v Engine) IsNumber(customError ...string) Engine {
+	describe(&v, "number")
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -102,6 +105,7 @@ func (v Engine) IsNumber(customError ...string) Engine {
 
 // IsInteger is integer
 func (v Engine) IsInteger(customError ...string) Engine {
+	describe(&v, "integer")
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -194,6 +198,7 @@ func (v Engine) IsMobile(customError ...string) Engine {
 
 // IsMail email address
 func (v Engine) IsMail(customError ...string) Engine {
+	describe(&v, "mail")
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -246,6 +251,7 @@ func (v Engine) IsMail(customError ...string) Engine {
 
 // IsURL links
 func (v Engine) IsURL(customError ...string) Engine {
+	describe(&v, "url")
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -260,6 +266,7 @@ func (v Engine) IsURL(customError ...string) Engine {
 
 // IsIP ipv4 v6 address
 func (v Engine) IsIP(customError ...string) Engine {
+	describe(&v, "ip")
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -343,6 +350,7 @@ func (v Engine) IsChineseIDNumber(customError ...string) Engine {
 
 // MinLength minimum length
 func (v Engine) MinLength(min int, customError ...string) Engine {
+	describe(&v, "minLength", min)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -356,6 +364,7 @@ func (v Engine) MinLength(min int, customError ...string) Engine {
 
 // MinUTF8Length utf8 encoding minimum length
 func (v Engine) MinUTF8Length(min int, customError ...string) Engine {
+	describe(&v, "minLength", min)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if !ignore(v) && zstring.Len(v.value) < min {
 			v.err = setError(v, "长度不能小于"+strconv.Itoa(min)+"个字符", customError...)
@@ -366,6 +375,7 @@ func (v Engine) MinUTF8Length(min int, customError ...string) Engine {
 
 // MaxLength the maximum length
 func (v Engine) MaxLength(max int, customError ...string) Engine {
+	describe(&v, "maxLength", max)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if !ignore(v) && len(v.value) > max {
 			v.err = setError(v, "长度不能大于"+strconv.Itoa(max)+"个字符", customError...)
@@ -376,6 +386,7 @@ func (v Engine) MaxLength(max int, customError ...string) Engine {
 
 // MaxUTF8Length utf8 encoding maximum length
 func (v Engine) MaxUTF8Length(max int, customError ...string) Engine {
+	describe(&v, "maxLength", max)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if !ignore(v) && zstring.Len(v.value) > max {
 			v.err = setError(v, "长度不能大于"+strconv.Itoa(max)+"个字符", customError...)
@@ -386,6 +397,7 @@ func (v Engine) MaxUTF8Length(max int, customError ...string) Engine {
 
 // MinInt minimum integer value
 func (v Engine) MinInt(min int, customError ...string) Engine {
+	describe(&v, "min", min)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -408,6 +420,7 @@ func (v Engine) MinInt(min int, customError ...string) Engine {
 
 // MaxInt maximum integer value
 func (v Engine) MaxInt(max int, customError ...string) Engine {
+	describe(&v, "max", max)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -430,6 +443,7 @@ func (v Engine) MaxInt(max int, customError ...string) Engine {
 
 // MinFloat minimum floating point value
 func (v Engine) MinFloat(min float64, customError ...string) Engine {
+	describe(&v, "min", min)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -453,6 +467,7 @@ func (v Engine) MinFloat(min float64, customError ...string) Engine {
 
 // MaxFloat maximum floating point value
 func (v Engine) MaxFloat(max float64, customError ...string) Engine {
+	describe(&v, "max", max)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -475,6 +490,7 @@ func (v Engine) MaxFloat(max float64, customError ...string) Engine {
 
 // EnumString allow only values ​​in []string
 func (v Engine) EnumString(slice []string, customError ...string) Engine {
+	describe(&v, "enum", slice)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -491,6 +507,7 @@ func (v Engine) EnumString(slice []string, customError ...string) Engine {
 
 // EnumInt allow only values ​​in []int
 func (v Engine) EnumInt(i []int, customError ...string) Engine {
+	describe(&v, "enum", i)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -513,6 +530,7 @@ func (v Engine) EnumInt(i []int, customError ...string) Engine {
 
 // EnumFloat64 allow only values ​​in []float64
 func (v Engine) EnumFloat64(f []float64, customError ...string) Engine {
+	describe(&v, "enum", f)
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if ignore(v) {
 			return v
@@ -17,6 +17,7 @@ type (
 		value        string
 		sep          string
 		queue        []queueT
+		rules        []Rule
 		valueInt     int
 		valueFloat   float64
 		setRawValue  bool
@@ -24,11 +25,34 @@ type (
 		result       bool
 	}
 	queueT func(v *Engine) *Engine
+	// Rule describes a validation rule added to the engine, Name is one of
+	// required, regex, bool, number, integer, mail, url, ip, minLength,
+	// maxLength, min, max or enum and Args holds the rule arguments
+	Rule struct {
+		Name string
+		Args []interface{}
+	}
+	// FieldError is the validation error of a field
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+	// FieldErrors collects the validation errors of several fields,
+	// Error returns the message of the first one
+	FieldErrors []FieldError
 )
 
 // ErrNoValidationValueSet no verification value set
 var ErrNoValidationValueSet = errors.New("未设置验证值")
 
+// Error returns the message of the first field error
+func (e FieldErrors) Error() string {
+	if len(e) == 0 {
+		return ""
+	}
+	return e[0].Message
+}
+
 // New valid
 func New() Engine {
 	return Engine{
@@ -73,6 +97,7 @@ func JSON(json *zjson.Res, rules map[string]Engine) (err error) {
 
 // Required Must have a value (zero values ​​other than "" are allowed). If this rule is not used, when the parameter value is "", data validation does not take effect by default
 func (v Engine) Required(customError ...string) Engine {
+	describe(&v, "required")
 	return pushQueue(&v, func(v *Engine) *Engine {
 		if v.value == "" {
 			v.err = setError(v, "不能为空", customError...)
@@ -89,6 +114,16 @@ func (v Engine) Customize(fn func(rawValue string, err error) (newValue string,
 	}, true)
 }
 
+// Rules returns the descriptions of the rules added to the engine,
+// which can be used to document constraints such as in API schemas
+func (v Engine) Rules() []Rule {
+	return append([]Rule(nil), v.rules...)
+}
+
+func describe(v *Engine, name string, args ...interface{}) {
+	v.rules = append(v.rules[:len(v.rules):len(v.rules)], Rule{Name: name, Args: args})
+}
+
 func pushQueue(v *Engine, fn queueT, DisableCheckErr ...bool) Engine {
 	pFn := fn
 	if !(len(DisableCheckErr) > 0 && DisableCheckErr[0]) {
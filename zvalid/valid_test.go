@@ -257,3 +257,16 @@ func BenchmarkStr2(b *testing.B) {
 		}
 	}
 }
+
+func TestValidRules(tt *testing.T) {
+	t := zlsgo.NewTest(tt)
+
+	base := New().Required()
+	a := base.MinLength(3).MaxLength(10)
+	b := base.EnumString([]string{"x", "y"})
+
+	t.Equal([]Rule{{Name: "required"}}, base.Rules())
+	t.Equal([]Rule{{Name: "required"}, {Name: "minLength", Args: []interface{}{3}}, {Name: "maxLength", Args: []interface{}{10}}}, a.Rules())
+	t.Equal([]Rule{{Name: "required"}, {Name: "enum", Args: []interface{}{[]string{"x", "y"}}}}, b.Rules())
+	t.Equal(0, len(New().Trim().Rules()))
+}